@@ -0,0 +1,140 @@
+package elogrus
+
+import (
+	"fmt"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Data fields of the summary documents of aggregated entries.
+const (
+	CountKey     = "count"
+	FirstSeenKey = "first_seen"
+	LastSeenKey  = "last_seen"
+)
+
+// DefaultAggregatedLevels are the levels aggregated when Aggregation.Levels is empty.
+var DefaultAggregatedLevels = []logrus.Level{logrus.InfoLevel, logrus.DebugLevel, logrus.TraceLevel}
+
+// maxAggregates bounds the number of distinct messages aggregated per interval,
+// the entries above it are shipped right away.
+const maxAggregates = 10000
+
+// Aggregation batches identical low-severity entries, i.e. with the same level
+// and message, e.g. from chatty polling loops: the entries logged during an
+// Interval are shipped at its end as a single document, the first entry with the
+// CountKey, FirstSeenKey and LastSeenKey data fields. Entries without a repeat are
+// shipped unchanged. The zero value turns aggregation off.
+type Aggregation struct {
+	Interval time.Duration
+	// Levels aggregated, DefaultAggregatedLevels if empty.
+	Levels []logrus.Level
+}
+
+func (a Aggregation) aggregated(level logrus.Level) bool {
+	levels := a.Levels
+	if len(levels) == 0 {
+		levels = DefaultAggregatedLevels
+	}
+	for _, l := range levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+type aggregateKey struct {
+	level   logrus.Level
+	message string
+}
+
+type aggregate struct {
+	entry logrus.Entry
+	count int
+	last  time.Time
+}
+
+// aggregator holds the entries aggregated in the current interval.
+type aggregator struct {
+	mu      sync.Mutex
+	entries map[aggregateKey]*aggregate
+	start   sync.Once
+}
+
+// aggregate absorbs an entry if the configuration aggregates it, and reports whether it did.
+func (hook *ElasticHook) aggregate(cfg *Config, entry *logrus.Entry) bool {
+	if cfg.Aggregation.Interval <= 0 || !cfg.Aggregation.aggregated(entry.Level) {
+		return false
+	}
+	a := &hook.aggregator
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := aggregateKey{entry.Level, entry.Message}
+	if agg, ok := a.entries[key]; ok {
+		agg.count++
+		agg.last = entry.Time
+		return true
+	}
+	if len(a.entries) >= maxAggregates {
+		return false
+	}
+	if a.entries == nil {
+		a.entries = make(map[aggregateKey]*aggregate)
+	}
+	// The entry is reused by the logger once Fire returns, keep a copy.
+	agg := &aggregate{entry: *entry, count: 1, last: entry.Time}
+	agg.entry.Data = copyFields(entry.Data)
+	a.entries[key] = agg
+	return true
+}
+
+// startAggregation starts the goroutine shipping the aggregated entries at the
+// end of every interval, once.
+func (hook *ElasticHook) startAggregation() {
+	hook.aggregator.start.Do(func() {
+		go func() {
+			pprof.SetGoroutineLabels(hook.labels)
+			for {
+				interval := hook.config.Load().Aggregation.Interval
+				if interval <= 0 {
+					// aggregation turned off, ship what is left
+					interval = DefaultFlushInterval
+				}
+				select {
+				case <-hook.ctx.Done():
+					return
+				case <-time.After(interval):
+				}
+				hook.flushAggregates()
+			}
+		}()
+	})
+}
+
+// flushAggregates ships the aggregated entries.
+func (hook *ElasticHook) flushAggregates() {
+	a := &hook.aggregator
+	a.mu.Lock()
+	entries := a.entries
+	a.entries = nil
+	a.mu.Unlock()
+
+	for _, agg := range entries {
+		entry := &agg.entry
+		if agg.count > 1 {
+			if entry.Data == nil {
+				entry.Data = make(logrus.Fields, 3)
+			}
+			entry.Data[CountKey] = agg.count
+			entry.Data[FirstSeenKey] = hook.formatTime(entry.Time)
+			entry.Data[LastSeenKey] = hook.formatTime(agg.last)
+		}
+		if err := hook.fireFunc(entry, hook); err != nil {
+			hook.handleError(fmt.Errorf("cannot ship aggregated entry: %w", err), nil)
+		}
+	}
+}
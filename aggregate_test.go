@@ -0,0 +1,56 @@
+package elogrus
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAggregation(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "aggregation-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	cfg := hook.Config()
+	cfg.Aggregation = Aggregation{Interval: time.Hour}
+	if err := hook.Reconfigure(cfg); err != nil {
+		t.Fatalf("Error reconfiguring the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+
+	start := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		logger.WithTime(start.Add(time.Duration(i) * time.Second)).Info("polling")
+	}
+	logger.Info("once")
+	logger.Error("failed")
+	logger.Error("failed")
+	if docs := f.Requests(http.MethodPost, "/aggregation-log/_doc"); len(docs) != 2 {
+		t.Fatalf("Expected only the errors to be shipped, got %d documents", len(docs))
+	}
+
+	hook.flushAggregates()
+	var polling, once string
+	for _, doc := range f.Requests(http.MethodPost, "/aggregation-log/_doc") {
+		switch {
+		case strings.Contains(doc.Body, "polling"):
+			polling = doc.Body
+		case strings.Contains(doc.Body, "once"):
+			once = doc.Body
+		}
+	}
+	expected := `"data":{"count":5,"first_seen":"2022-03-04T05:06:07Z","last_seen":"2022-03-04T05:06:11Z"}`
+	if !strings.Contains(polling, expected) {
+		t.Errorf("Unexpected summary document: %s", polling)
+	}
+	if once == "" || strings.Contains(once, CountKey) {
+		t.Errorf("Unexpected single document: %s", once)
+	}
+}
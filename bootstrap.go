@@ -0,0 +1,151 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// RolloverConditions describes when a write index is rolled over.
+type RolloverConditions = core.RolloverConditions
+
+// DefaultRolloverConditions are used by Bootstrap when no rollover conditions are configured.
+var DefaultRolloverConditions = RolloverConditions{
+	MaxAge:              "30d",
+	MaxPrimaryShardSize: "50gb",
+}
+
+// BootstrapConfig configures Bootstrap.
+type BootstrapConfig struct {
+	// Alias is the write alias the hook writes to (required).
+	Alias string
+	// IndexPatterns matched by the index template, defaults to Alias + "-*".
+	IndexPatterns []string
+	// TemplateName defaults to Alias.
+	TemplateName string
+	// PolicyName is the ILM policy name, defaults to Alias.
+	PolicyName string
+	// Rollover conditions of the ILM policy hot phase, defaults to DefaultRolloverConditions.
+	Rollover RolloverConditions
+	// DeleteAfter is the minimum age of a rolled over index before it is deleted
+	// (e.g. "90d"). If empty, indices are never deleted.
+	DeleteAfter string
+	// Settings and Mappings are put into the index template,
+	// Mappings default to DefaultMappings.
+	Settings map[string]interface{}
+	Mappings map[string]interface{}
+	// FlattenedData maps the data object to the flattened type when
+	// Mappings are not set, see MappingOptions.
+	FlattenedData bool
+	// RuntimeFields are added to the mappings of the template. They are computed
+	// at query time, so they can be added or changed without reindexing.
+	// Requires Elasticsearch 7.11+.
+	RuntimeFields map[string]RuntimeField
+}
+
+// RuntimeField is a field computed at query time by a Painless script, e.g.
+//
+//	RuntimeField{
+//		Type:   "long",
+//		Script: `String m = params._source.message; int i = m.indexOf("took ");
+//			if (i >= 0) emit(Long.parseLong(m.substring(i + 5, m.indexOf("ms", i))));`,
+//	}
+type RuntimeField struct {
+	// Type of the emitted values: boolean, date, double, geo_point, ip, keyword or long.
+	Type string
+	// Script emitting the values, the field has no values if empty.
+	Script string
+}
+
+// runtimeMappings returns the runtime section of the mappings.
+func runtimeMappings(fields map[string]RuntimeField) map[string]interface{} {
+	runtime := make(map[string]interface{}, len(fields))
+	for name, field := range fields {
+		mapping := map[string]interface{}{"type": field.Type}
+		if field.Script != "" {
+			mapping["script"] = map[string]interface{}{"source": field.Script}
+		}
+		runtime[name] = mapping
+	}
+	return runtime
+}
+
+// Bootstrap idempotently creates the ILM policy, the index template, the initial
+// index and the write alias described by cfg. Existing policies and templates are
+// updated, an existing alias is left as is, so it is safe to call on every start.
+func Bootstrap(ctx context.Context, client core.Client, cfg BootstrapConfig) error {
+	if cfg.Alias == "" {
+		return fmt.Errorf("bootstrap: alias is required")
+	}
+	if len(cfg.IndexPatterns) == 0 {
+		cfg.IndexPatterns = []string{cfg.Alias + "-*"}
+	}
+	if cfg.TemplateName == "" {
+		cfg.TemplateName = cfg.Alias
+	}
+	if cfg.PolicyName == "" {
+		cfg.PolicyName = cfg.Alias
+	}
+	if cfg.Rollover == (RolloverConditions{}) {
+		cfg.Rollover = DefaultRolloverConditions
+	}
+	if cfg.Mappings == nil {
+		cfg.Mappings = Mappings(MappingOptions{FlattenedData: cfg.FlattenedData})
+	}
+	if len(cfg.RuntimeFields) > 0 {
+		mappings := make(map[string]interface{}, len(cfg.Mappings)+1)
+		for k, v := range cfg.Mappings {
+			mappings[k] = v
+		}
+		mappings["runtime"] = runtimeMappings(cfg.RuntimeFields)
+		cfg.Mappings = mappings
+	}
+
+	err := client.PutLifecyclePolicy(ctx, cfg.PolicyName, core.LifecyclePolicy{
+		IndexPatterns: cfg.IndexPatterns,
+		Rollover:      cfg.Rollover,
+		DeleteAfter:   cfg.DeleteAfter,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot put lifecycle policy %s: %w", cfg.PolicyName, err)
+	}
+	err = client.PutIndexTemplate(ctx, cfg.TemplateName, core.IndexTemplate{
+		IndexPatterns:   cfg.IndexPatterns,
+		Settings:        cfg.Settings,
+		Mappings:        cfg.Mappings,
+		LifecyclePolicy: cfg.PolicyName,
+		RolloverAlias:   cfg.Alias,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot put index template %s: %w", cfg.TemplateName, err)
+	}
+	return createWriteIndex(ctx, client, cfg.Alias)
+}
+
+func createWriteIndex(ctx context.Context, client core.Client, alias string) error {
+	exists, err := client.AliasExists(ctx, alias)
+	if err != nil || exists {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"aliases": map[string]interface{}{
+			alias: map[string]interface{}{"is_write_index": true},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	index := alias + "-000001"
+	if err := client.CreateIndex(ctx, index, body); err != nil {
+		var e *ResponseError
+		if !errors.As(err, &e) || e.Type != "resource_already_exists_exception" {
+			return fmt.Errorf("cannot create index %s: %w", index, err)
+		}
+		// another instance has bootstrapped the alias concurrently
+	}
+	return nil
+}
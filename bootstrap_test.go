@@ -0,0 +1,161 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestBootstrap(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		switch r.URL.Path {
+		case "/_ilm/policy/app-logs", "/_index_template/app-logs":
+			_, _ = io.WriteString(w, `{"acknowledged":true}`)
+			return true
+		case "/_alias/app-logs":
+			w.WriteHeader(http.StatusNotFound)
+			return true
+		}
+		return false
+	}
+
+	err := Bootstrap(context.Background(), es8.New(client), BootstrapConfig{
+		Alias:       "app-logs",
+		DeleteAfter: "90d",
+		Mappings:    map[string]interface{}{"dynamic": false},
+		RuntimeFields: map[string]RuntimeField{
+			"took_ms": {Type: "long", Script: "emit(1)"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error bootstrapping: %s", err)
+	}
+
+	policies := f.Requests(http.MethodPut, "/_ilm/policy/app-logs")
+	if len(policies) != 1 {
+		t.Fatalf("Expected the policy to be created")
+	}
+	var policy struct {
+		Policy struct {
+			Phases map[string]struct {
+				MinAge  string                     `json:"min_age"`
+				Actions map[string]json.RawMessage `json:"actions"`
+			} `json:"phases"`
+		} `json:"policy"`
+	}
+	if err := json.Unmarshal([]byte(policies[0].Body), &policy); err != nil {
+		t.Fatalf("Error parsing the policy: %s", err)
+	}
+	if string(policy.Policy.Phases["hot"].Actions["rollover"]) != `{"max_age":"30d","max_primary_shard_size":"50gb"}` {
+		t.Errorf("Unexpected rollover action: %s", policy.Policy.Phases["hot"].Actions["rollover"])
+	}
+	if policy.Policy.Phases["delete"].MinAge != "90d" {
+		t.Errorf("Unexpected delete phase: %+v", policy.Policy.Phases["delete"])
+	}
+
+	templates := f.Requests(http.MethodPut, "/_index_template/app-logs")
+	if len(templates) != 1 {
+		t.Fatalf("Expected the template to be created")
+	}
+	var template struct {
+		IndexPatterns []string `json:"index_patterns"`
+		Template      struct {
+			Settings map[string]string      `json:"settings"`
+			Mappings map[string]interface{} `json:"mappings"`
+		} `json:"template"`
+	}
+	if err := json.Unmarshal([]byte(templates[0].Body), &template); err != nil {
+		t.Fatalf("Error parsing the template: %s", err)
+	}
+	if len(template.IndexPatterns) != 1 || template.IndexPatterns[0] != "app-logs-*" {
+		t.Errorf("Unexpected index patterns: %v", template.IndexPatterns)
+	}
+	if template.Template.Settings["index.lifecycle.rollover_alias"] != "app-logs" {
+		t.Errorf("Unexpected template settings: %v", template.Template.Settings)
+	}
+	if template.Template.Mappings["dynamic"] != false {
+		t.Errorf("Unexpected template mappings: %v", template.Template.Mappings)
+	}
+	runtime, _ := json.Marshal(template.Template.Mappings["runtime"])
+	if string(runtime) != `{"took_ms":{"script":{"source":"emit(1)"},"type":"long"}}` {
+		t.Errorf("Unexpected runtime fields: %s", runtime)
+	}
+
+	indices := f.Requests(http.MethodPut, "/app-logs-000001")
+	if len(indices) != 1 || indices[0].Body != `{"aliases":{"app-logs":{"is_write_index":true}}}` {
+		t.Errorf("Unexpected initial index creation: %+v", indices)
+	}
+}
+
+func TestBootstrapExistingAlias(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.URL.Path == "/_alias/app-logs" {
+			return true
+		}
+		_, _ = io.WriteString(w, `{"acknowledged":true}`)
+		return true
+	}
+
+	if err := Bootstrap(context.Background(), es8.New(client), BootstrapConfig{Alias: "app-logs"}); err != nil {
+		t.Fatalf("Error bootstrapping: %s", err)
+	}
+	if indices := f.Requests(http.MethodPut, "/app-logs-000001"); len(indices) != 0 {
+		t.Error("Unexpected initial index creation")
+	}
+}
+
+func TestWithBootstrap(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		switch r.URL.Path {
+		case "/_ilm/policy/app-logs", "/_index_template/app-logs":
+			_, _ = io.WriteString(w, `{"acknowledged":true}`)
+			return true
+		case "/_alias/app-logs":
+			w.WriteHeader(http.StatusNotFound)
+			return true
+		case "/app-logs":
+			// the alias of the bootstrapped index
+			if r.Method == http.MethodHead {
+				return true
+			}
+		}
+		return false
+	}
+
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("app-logs"),
+		WithBootstrap(BootstrapConfig{DeleteAfter: "90d"}),
+		WithECSFormat(),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+
+	if len(f.Requests(http.MethodPut, "/_ilm/policy/app-logs")) != 1 {
+		t.Error("Expected the policy to be created")
+	}
+	templates := f.Requests(http.MethodPut, "/_index_template/app-logs")
+	if len(templates) != 1 || !strings.Contains(templates[0].Body, "labels_strings") {
+		t.Errorf("Expected the template to be created with the ECS mappings: %+v", templates)
+	}
+	if len(f.Requests(http.MethodPut, "/app-logs-000001")) != 1 {
+		t.Error("Expected the write index to be created")
+	}
+	if len(f.Requests(http.MethodPut, "/app-logs")) != 0 {
+		t.Error("Expected the hook not to create an index named after the alias")
+	}
+
+	_, err = NewElasticHookWithOptions(es8.New(client), WithDataStream("logs-app-default"), WithBootstrap(BootstrapConfig{}))
+	if err == nil {
+		t.Error("Expected an error for a data stream")
+	}
+}
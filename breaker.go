@@ -0,0 +1,46 @@
+package elogrus
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// ErrCircuitOpen is reported for the entries that are not sent because the
+// circuit breaker of the hook is open, see SetCircuitBreaker.
+var ErrCircuitOpen = core.ErrCircuitOpen
+
+// SetCircuitBreaker makes the hook stop sending requests after failures
+// consecutive requests failed with a transient error (connection problems,
+// timeouts, throttling and server errors), so logging does not wait for an
+// unhealthy cluster. While the breaker is open, the entries fail at once with
+// ErrCircuitOpen and go to the DeadLetterHandler and the Errors channel; the
+// batches of a bulk processor hook are spilled if it spills, see SetSpill.
+// Every probeInterval, a single request is let through as a probe, the breaker
+// closes once one succeeds.
+func (hook *ElasticHook) SetCircuitBreaker(failures int, probeInterval time.Duration) error {
+	if failures <= 0 {
+		return errors.New("circuit breaker failures must be positive")
+	}
+	if probeInterval <= 0 {
+		return errors.New("circuit breaker probe interval must be positive")
+	}
+	hook.breaker = core.NewBreaker(failures, probeInterval, func(open bool) {
+		if open {
+			hook.debugf("circuit breaker opened after %d failures", failures)
+		} else {
+			hook.debugf("circuit breaker closed")
+		}
+	})
+	if hook.batcher != nil {
+		hook.batcher.SetBreaker(hook.breaker)
+	}
+	return nil
+}
+
+// CircuitOpen reports whether the circuit breaker of the hook is open, see
+// SetCircuitBreaker.
+func (hook *ElasticHook) CircuitOpen() bool {
+	return hook.breaker != nil && hook.breaker.Open()
+}
@@ -0,0 +1,66 @@
+package elogrus
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSetCircuitBreaker(t *testing.T) {
+	f, client := newFakeElastic(t)
+	var down atomic.Bool
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/_doc") && down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		return false
+	}
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "breaker-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetCircuitBreaker(0, time.Second); err == nil {
+		t.Error("Expected an error for no failures")
+	}
+	if err := hook.SetCircuitBreaker(2, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	var letters []error
+	hook.DeadLetterHandler = func(entry *logrus.Entry, doc []byte, err error) {
+		letters = append(letters, err)
+	}
+	log := logrus.New()
+	log.Out = io.Discard
+	log.Hooks.Add(hook)
+
+	down.Store(true)
+	for i := 0; i < 4; i++ {
+		log.Info("unavailable")
+	}
+	if docs := f.Requests(http.MethodPost, "/breaker-log/_doc"); len(docs) != 2 {
+		t.Errorf("Expected 2 requests before the breaker opens, got %d", len(docs))
+	}
+	if !hook.CircuitOpen() {
+		t.Error("Expected the breaker to be open")
+	}
+	if len(letters) != 4 || !errors.Is(letters[3], ErrCircuitOpen) {
+		t.Errorf("Expected 4 dead letters, the last ones with ErrCircuitOpen, got %v", letters)
+	}
+
+	down.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	log.Info("available")
+	if hook.CircuitOpen() {
+		t.Error("Expected the breaker to close after a successful probe")
+	}
+	if docs := f.Requests(http.MethodPost, "/breaker-log/_doc"); len(docs) != 3 {
+		t.Errorf("Expected the probe to be sent, got %d requests", len(docs))
+	}
+}
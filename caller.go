@@ -0,0 +1,41 @@
+package elogrus
+
+import "github.com/sirupsen/logrus"
+
+// CallerFields are the names of the data fields holding the caller of the
+// entries, see SetCallerFields. The fields with an empty name are omitted.
+type CallerFields struct {
+	File     string
+	Line     string
+	Function string
+}
+
+// DefaultCallerFields are the usual names of the caller fields.
+var DefaultCallerFields = CallerFields{File: "caller.file", Line: "caller.line", Function: "caller.function"}
+
+// SetCallerFields makes the hook add the file, line and function of the caller
+// of the entries logged with logrus.SetReportCaller(true) to their documents as
+// data fields with the given names, e.g. DefaultCallerFields, so they can be
+// searched separately instead of as the combined file and func values of the
+// DefaultFormat. The fields are redacted, renamed, expanded and de-dotted like
+// the fields of the entry and replace them. A zero names removes them.
+func (hook *ElasticHook) SetCallerFields(names CallerFields) {
+	hook.callerFields = names
+}
+
+// addCaller adds the caller fields of the entry to data.
+func (hook *ElasticHook) addCaller(entry *logrus.Entry, data logrus.Fields) {
+	names := hook.callerFields
+	if names == (CallerFields{}) || !entry.HasCaller() {
+		return
+	}
+	if names.File != "" {
+		data[names.File] = entry.Caller.File
+	}
+	if names.Line != "" {
+		data[names.Line] = entry.Caller.Line
+	}
+	if names.Function != "" {
+		data[names.Function] = entry.Caller.Function
+	}
+}
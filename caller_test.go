@@ -0,0 +1,56 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestSetCallerFields(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("caller-log"),
+		WithCallerFields(CallerFields{File: "caller.file", Line: "caller.line", Function: "fn"}),
+		WithExpandKeys(),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	logger.Info("without caller")
+	logger.SetReportCaller(true)
+	logger.Info("with caller")
+
+	docs := f.Requests(http.MethodPost, "/caller-log/_doc")
+	if len(docs) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(docs))
+	}
+	if strings.Contains(docs[0].Body, `"data"`) {
+		t.Errorf("Unexpected caller fields: %s", docs[0].Body)
+	}
+	var msg struct {
+		Data struct {
+			Caller struct {
+				File string `json:"file"`
+				Line int    `json:"line"`
+			} `json:"caller"`
+			Function string `json:"fn"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(docs[1].Body), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(msg.Data.Caller.File, "caller_test.go") || msg.Data.Caller.Line == 0 ||
+		!strings.HasSuffix(msg.Data.Function, "TestSetCallerFields") {
+		t.Errorf("Unexpected caller fields: %s", docs[1].Body)
+	}
+}
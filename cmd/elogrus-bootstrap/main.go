@@ -0,0 +1,88 @@
+// Command elogrus-bootstrap provisions the ILM policy, index template,
+// initial index and write alias used by the hook (see elogrus.Bootstrap).
+//
+// Usage:
+//
+//	elogrus-bootstrap -alias app-logs [flags]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8"
+
+	"gopkg.in/go-extras/elogrus.v8"
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func main() {
+	var (
+		addresses   = flag.String("addr", "", "comma-separated list of Elasticsearch addresses (defaults to $ELASTICSEARCH_URL or http://localhost:9200)")
+		username    = flag.String("username", "", "username for HTTP basic authentication")
+		password    = flag.String("password", "", "password for HTTP basic authentication")
+		alias       = flag.String("alias", "", "write alias the hook writes to (required)")
+		policy      = flag.String("policy", "", "ILM policy name (defaults to the alias)")
+		maxAge      = flag.String("max-age", elogrus.DefaultRolloverConditions.MaxAge, "roll over after this age")
+		maxSize     = flag.String("max-size", elogrus.DefaultRolloverConditions.MaxPrimaryShardSize, "roll over when a primary shard reaches this size")
+		maxDocs     = flag.Int64("max-docs", 0, "roll over after this number of documents (0 means no limit)")
+		deleteAfter = flag.String("delete-after", "", "delete rolled over indices older than this (empty means never)")
+		template    = flag.String("template", "", `JSON file with index template "settings" and "mappings"`)
+	)
+	flag.Parse()
+	if *alias == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	cfg := elogrus.BootstrapConfig{
+		Alias:      *alias,
+		PolicyName: *policy,
+		Rollover: elogrus.RolloverConditions{
+			MaxAge:              *maxAge,
+			MaxPrimaryShardSize: *maxSize,
+			MaxDocs:             *maxDocs,
+		},
+		DeleteAfter: *deleteAfter,
+	}
+	if *template != "" {
+		data, err := os.ReadFile(*template)
+		if err != nil {
+			fatalf("%s", err)
+		}
+		var t struct {
+			Settings map[string]interface{} `json:"settings"`
+			Mappings map[string]interface{} `json:"mappings"`
+		}
+		if err := json.Unmarshal(data, &t); err != nil {
+			fatalf("%s: %s", *template, err)
+		}
+		cfg.Settings, cfg.Mappings = t.Settings, t.Mappings
+	}
+
+	esCfg := elasticsearch.Config{
+		Username: *username,
+		Password: *password,
+	}
+	if *addresses != "" {
+		esCfg.Addresses = strings.Split(*addresses, ",")
+	}
+	client, err := elasticsearch.NewClient(esCfg)
+	if err != nil {
+		fatalf("cannot create the client: %s", err)
+	}
+
+	if err := elogrus.Bootstrap(context.Background(), es8.New(client), cfg); err != nil {
+		fatalf("%s", err)
+	}
+	fmt.Fprintf(os.Stderr, "%s is ready\n", *alias)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "elogrus-bootstrap: "+format+"\n", args...)
+	os.Exit(1)
+}
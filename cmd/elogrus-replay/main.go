@@ -0,0 +1,107 @@
+// Command elogrus-replay bulk-ingests NDJSON files, such as the segment files
+// of a spill directory or the output of a fallback writer, back into
+// Elasticsearch.
+//
+// Usage:
+//
+//	elogrus-replay [flags] [file ...]
+//
+// With no files (or with "-") the data is read from the standard input.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8"
+
+	"gopkg.in/go-extras/elogrus.v8"
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func main() {
+	var (
+		addresses = flag.String("addr", "", "comma-separated list of Elasticsearch addresses (defaults to $ELASTICSEARCH_URL or http://localhost:9200)")
+		username  = flag.String("username", "", "username for HTTP basic authentication")
+		password  = flag.String("password", "", "password for HTTP basic authentication")
+		index     = flag.String("index", "", "index for documents that do not carry their own bulk action line")
+		batchSize = flag.Int("batch-size", elogrus.DefaultReplayBatchSize, "documents per bulk request")
+		rate      = flag.Float64("rate", 0, "maximum documents per second (0 means unlimited)")
+		quiet     = flag.Bool("quiet", false, "do not print progress")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg := elasticsearch.Config{
+		Username: *username,
+		Password: *password,
+	}
+	if *addresses != "" {
+		cfg.Addresses = strings.Split(*addresses, ",")
+	}
+	client, err := elasticsearch.NewClient(cfg)
+	if err != nil {
+		fatalf("cannot create the client: %s", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var total elogrus.ReplayStats
+	for _, name := range files {
+		stats, err := replayFile(ctx, client, name, elogrus.ReplayOptions{
+			Index:     *index,
+			BatchSize: *batchSize,
+			Rate:      *rate,
+			Progress: func(stats elogrus.ReplayStats) {
+				if !*quiet {
+					fmt.Fprintf(os.Stderr, "%s: %d documents (%d failed) in %d batches, %d bytes\n",
+						name, stats.Documents, stats.Failed, stats.Batches, stats.Bytes)
+				}
+			},
+		})
+		total.Documents += stats.Documents
+		total.Failed += stats.Failed
+		total.Batches += stats.Batches
+		total.Bytes += stats.Bytes
+		if err != nil {
+			fatalf("%s: %s", name, err)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "done: %d documents (%d failed) in %d batches, %d bytes\n",
+		total.Documents, total.Failed, total.Batches, total.Bytes)
+	if total.Failed > 0 {
+		os.Exit(2)
+	}
+}
+
+func replayFile(ctx context.Context, client *elasticsearch.Client, name string, opts elogrus.ReplayOptions) (elogrus.ReplayStats, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return elogrus.ReplayStats{}, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return elogrus.Replay(ctx, es8.New(client), r, opts)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "elogrus-replay: "+format+"\n", args...)
+	os.Exit(1)
+}
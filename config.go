@@ -0,0 +1,308 @@
+package elogrus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultFlushInterval is the flush interval of bulk processor hooks.
+const DefaultFlushInterval = time.Second
+
+// Config holds the hook settings that can be changed at runtime with Reconfigure.
+type Config struct {
+	// Level is the least severe level shipped to Elasticsearch.
+	Level logrus.Level
+	// Levels, if not empty, are the only levels shipped and Level is ignored,
+	// e.g. LevelRange(logrus.ErrorLevel, logrus.InfoLevel) to leave out the
+	// panics handled elsewhere as well as the debug and trace entries.
+	Levels []logrus.Level
+	// SampleRate is the fraction of entries shipped, 0 and 1 ship everything.
+	SampleRate float64
+	// LevelSampleRates, if set, are the fractions of the entries of some levels
+	// shipped instead of the SampleRate, e.g. 1 for the errors and 0.05 for the
+	// debug entries. A rate of 0 ships none of the entries of the level.
+	LevelSampleRates map[logrus.Level]float64
+	// Sampler decides which entries are shipped at the sample rates,
+	// RandomSampler if nil, see HashSampler for a deterministic one.
+	Sampler Sampler
+	// ErrorSampling thins out storms of identical errors, off by default.
+	ErrorSampling ErrorSampling
+	// Aggregation batches identical low-severity entries, off by default.
+	Aggregation Aggregation
+	// FlushInterval of the bulk processor, only used by bulk processor hooks.
+	FlushInterval time.Duration
+	// FlushJitter, in [0, 1), varies every flush interval randomly by up to
+	// this fraction of the FlushInterval, e.g. 0.2 for intervals between 0.8s
+	// and 1.2s, so the instances of a service started together do not flush
+	// at the same time. Only used by bulk processor hooks.
+	FlushJitter float64
+	// Fields are added to every document, fields of the entry take precedence.
+	Fields logrus.Fields
+	// MaxDocumentSize is the maximum size of a serialized document in bytes,
+	// larger documents are rejected before they are queued. 0 means no limit.
+	MaxDocumentSize int
+	// SummarizeOversized ships a truncated summary of a rejected oversized
+	// document instead of nothing.
+	SummarizeOversized bool
+	// MaxMessageLength and MaxFieldLength, if positive, are the maximum sizes
+	// in bytes of the message and of the string and error values of the fields,
+	// longer ones are truncated and the TruncatedKey field is set.
+	MaxMessageLength int
+	MaxFieldLength   int
+
+	// levelMask has the bits of Levels set.
+	levelMask uint32
+}
+
+// LevelRange returns the levels from the most severe level to the least severe
+// one, both inclusive.
+func LevelRange(mostSevere, leastSevere logrus.Level) []logrus.Level {
+	var levels []logrus.Level
+	for level := mostSevere; level <= leastSevere; level++ {
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// Config returns a copy of the current hook configuration.
+func (hook *ElasticHook) Config() Config {
+	cfg := *hook.config.Load()
+	cfg.Fields = copyFields(cfg.Fields)
+	cfg.Levels = append([]logrus.Level(nil), cfg.Levels...)
+	cfg.LevelSampleRates = copySampleRates(cfg.LevelSampleRates)
+	cfg.Aggregation.Levels = append([]logrus.Level(nil), cfg.Aggregation.Levels...)
+	return cfg
+}
+
+// Reconfigure atomically replaces the hook configuration. Entries fired
+// concurrently see either the old or the new configuration, buffered entries
+// are kept.
+func (hook *ElasticHook) Reconfigure(cfg Config) error {
+	if cfg.Level > logrus.TraceLevel {
+		return fmt.Errorf("invalid level: %d", cfg.Level)
+	}
+	cfg.levelMask = 0
+	for _, level := range cfg.Levels {
+		if level > logrus.TraceLevel {
+			return fmt.Errorf("invalid level: %d", level)
+		}
+		cfg.levelMask |= 1 << level
+	}
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return fmt.Errorf("invalid sample rate: %v", cfg.SampleRate)
+	}
+	for level, rate := range cfg.LevelSampleRates {
+		if level > logrus.TraceLevel || rate < 0 || rate > 1 {
+			return fmt.Errorf("invalid sample rate for level %d: %v", level, rate)
+		}
+	}
+	if cfg.ErrorSampling.Window < 0 || cfg.ErrorSampling.First < 0 || cfg.ErrorSampling.Every < 0 {
+		return fmt.Errorf("invalid error sampling: %+v", cfg.ErrorSampling)
+	}
+	if cfg.Aggregation.Interval < 0 {
+		return fmt.Errorf("invalid aggregation interval: %s", cfg.Aggregation.Interval)
+	}
+	if cfg.MaxDocumentSize < 0 {
+		return fmt.Errorf("invalid max document size: %d", cfg.MaxDocumentSize)
+	}
+	if cfg.MaxMessageLength < 0 || cfg.MaxFieldLength < 0 {
+		return fmt.Errorf("invalid max lengths: %d, %d", cfg.MaxMessageLength, cfg.MaxFieldLength)
+	}
+	if hook.batcher != nil {
+		if cfg.FlushInterval <= 0 {
+			return fmt.Errorf("invalid flush interval: %s", cfg.FlushInterval)
+		}
+		if cfg.FlushJitter < 0 || cfg.FlushJitter >= 1 {
+			return fmt.Errorf("invalid flush jitter: %g", cfg.FlushJitter)
+		}
+		if cfg.FlushInterval != hook.config.Load().FlushInterval {
+			if err := hook.batcher.SetFlushInterval(cfg.FlushInterval); err != nil {
+				return err
+			}
+		}
+		if cfg.FlushJitter != hook.config.Load().FlushJitter {
+			if err := hook.batcher.SetFlushJitter(cfg.FlushJitter); err != nil {
+				return err
+			}
+		}
+	}
+	cfg.Fields = copyFields(cfg.Fields)
+	cfg.Levels = append([]logrus.Level(nil), cfg.Levels...)
+	cfg.LevelSampleRates = copySampleRates(cfg.LevelSampleRates)
+	cfg.Aggregation.Levels = append([]logrus.Level(nil), cfg.Aggregation.Levels...)
+	hook.config.Store(&cfg)
+	if cfg.Aggregation.Interval > 0 {
+		hook.startAggregation()
+	}
+	return nil
+}
+
+// configFile is the on-disk format read by WatchConfig, absent keys keep their current values.
+type configFile struct {
+	Level              *logrus.Level            `json:"level"`
+	Levels             []logrus.Level           `json:"levels"`
+	SampleRate         *float64                 `json:"sample_rate"`
+	LevelSampleRates   map[logrus.Level]float64 `json:"level_sample_rates"`
+	ErrorSampling      *errorSamplingFile       `json:"error_sampling"`
+	Aggregation        *aggregationFile         `json:"aggregation"`
+	FlushInterval      *string                  `json:"flush_interval"`
+	FlushJitter        *float64                 `json:"flush_jitter"`
+	Fields             logrus.Fields            `json:"fields"`
+	MaxDocumentSize    *int                     `json:"max_document_size"`
+	SummarizeOversized *bool                    `json:"summarize_oversized"`
+	MaxMessageLength   *int                     `json:"max_message_length"`
+	MaxFieldLength     *int                     `json:"max_field_length"`
+}
+
+// errorSamplingFile is the on-disk format of ErrorSampling.
+type errorSamplingFile struct {
+	Window string `json:"window"`
+	First  int    `json:"first"`
+	Every  int    `json:"every"`
+}
+
+// aggregationFile is the on-disk format of Aggregation.
+type aggregationFile struct {
+	Interval string         `json:"interval"`
+	Levels   []logrus.Level `json:"levels"`
+}
+
+// WatchConfig loads the configuration from a JSON file, e.g.
+//
+//	{"level": "info", "sample_rate": 0.5, "flush_interval": "2s", "fields": {"env": "prod"},
+//	 "level_sample_rates": {"debug": 0.05, "error": 1},
+//	 "error_sampling": {"window": "1m", "first": 10, "every": 100},
+//	 "aggregation": {"interval": "10s", "levels": ["info", "debug"]}}
+//
+// ("levels": ["error", "warning", "info"] ships a set of levels instead of "level")
+// and then checks the file for changes every interval until ctx is done or the hook is cancelled.
+// Keys absent from the file keep their current values. Errors after the initial load
+// are reported to the ErrorHandler.
+func (hook *ElasticHook) WatchConfig(ctx context.Context, path string, interval time.Duration) error {
+	data, err := hook.loadConfig(path)
+	if err != nil {
+		return err
+	}
+	go func() {
+		pprof.SetGoroutineLabels(hook.labels)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hook.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current, err := os.ReadFile(path)
+			if err != nil {
+				hook.handleError(err, nil)
+				continue
+			}
+			if bytes.Equal(current, data) {
+				continue
+			}
+			if current, err = hook.loadConfig(path); err != nil {
+				hook.handleError(fmt.Errorf("cannot reload %s: %w", path, err), current)
+			}
+			data = current
+		}
+	}()
+	return nil
+}
+
+func (hook *ElasticHook) loadConfig(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f configFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return data, err
+	}
+
+	cfg := hook.Config()
+	if f.Level != nil {
+		cfg.Level = *f.Level
+	}
+	if f.Levels != nil {
+		cfg.Levels = f.Levels
+	}
+	if f.SampleRate != nil {
+		cfg.SampleRate = *f.SampleRate
+	}
+	if f.LevelSampleRates != nil {
+		cfg.LevelSampleRates = f.LevelSampleRates
+	}
+	if f.ErrorSampling != nil {
+		cfg.ErrorSampling = ErrorSampling{First: f.ErrorSampling.First, Every: f.ErrorSampling.Every}
+		if f.ErrorSampling.Window != "" {
+			if cfg.ErrorSampling.Window, err = time.ParseDuration(f.ErrorSampling.Window); err != nil {
+				return data, err
+			}
+		}
+	}
+	if f.Aggregation != nil {
+		cfg.Aggregation = Aggregation{Levels: f.Aggregation.Levels}
+		if f.Aggregation.Interval != "" {
+			if cfg.Aggregation.Interval, err = time.ParseDuration(f.Aggregation.Interval); err != nil {
+				return data, err
+			}
+		}
+	}
+	if f.FlushInterval != nil {
+		if cfg.FlushInterval, err = time.ParseDuration(*f.FlushInterval); err != nil {
+			return data, err
+		}
+	}
+	if f.FlushJitter != nil {
+		cfg.FlushJitter = *f.FlushJitter
+	}
+	if f.Fields != nil {
+		cfg.Fields = f.Fields
+	}
+	if f.MaxDocumentSize != nil {
+		cfg.MaxDocumentSize = *f.MaxDocumentSize
+	}
+	if f.SummarizeOversized != nil {
+		cfg.SummarizeOversized = *f.SummarizeOversized
+	}
+	if f.MaxMessageLength != nil {
+		cfg.MaxMessageLength = *f.MaxMessageLength
+	}
+	if f.MaxFieldLength != nil {
+		cfg.MaxFieldLength = *f.MaxFieldLength
+	}
+	return data, hook.Reconfigure(cfg)
+}
+
+// enabled reports whether the entry should be shipped with the current configuration.
+func (cfg *Config) enabled(entry *logrus.Entry) bool {
+	if cfg.levelMask != 0 {
+		if entry.Level > logrus.TraceLevel || cfg.levelMask&(1<<entry.Level) == 0 {
+			return false
+		}
+	} else if entry.Level > cfg.Level {
+		return false
+	}
+	return cfg.sample(entry)
+}
+
+func copyFields(fields logrus.Fields) logrus.Fields {
+	if fields == nil {
+		return nil
+	}
+	c := make(logrus.Fields, len(fields))
+	for k, v := range fields {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,182 @@
+package elogrus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestReconfigure(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "config-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.SetLevel(logrus.TraceLevel)
+	logger.AddHook(hook)
+
+	logger.Debug("dropped")
+	if docs := f.Requests(http.MethodPost, "/config-log/_doc"); len(docs) != 0 {
+		t.Fatalf("Unexpected documents: %+v", docs)
+	}
+
+	cfg := hook.Config()
+	cfg.Level = logrus.DebugLevel
+	cfg.Fields = logrus.Fields{"env": "test", "overridden": false}
+	if err := hook.Reconfigure(cfg); err != nil {
+		t.Fatalf("Error reconfiguring the hook: %s", err)
+	}
+	cfg.Fields["env"] = "mutated"
+
+	logger.WithField("overridden", true).Debug("shipped")
+	docs := f.Requests(http.MethodPost, "/config-log/_doc")
+	if len(docs) != 1 {
+		t.Fatalf("Expected one document, got %d", len(docs))
+	}
+	if !strings.Contains(docs[0].Body, `"data":{"env":"test","overridden":true}`) {
+		t.Errorf("Unexpected document: %s", docs[0].Body)
+	}
+
+	if err := hook.Reconfigure(Config{Level: logrus.InfoLevel, SampleRate: 2}); err == nil {
+		t.Error("Expected an error for an invalid sample rate")
+	}
+}
+
+func TestLevels(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "levels-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.SetLevel(logrus.TraceLevel)
+	logger.AddHook(hook)
+
+	// The hook registers for every level and drops the others in Fire.
+	if !reflect.DeepEqual(hook.Levels(), logrus.AllLevels) {
+		t.Errorf("Expected the hook to register for all levels, got %v", hook.Levels())
+	}
+	entry := logrus.NewEntry(logger).WithTime(time.Now())
+	entry.Level = logrus.DebugLevel
+	if err := hook.Fire(entry); err != nil {
+		t.Errorf("Error firing an entry of a level that is not shipped: %s", err)
+	}
+	if docs := f.Requests(http.MethodPost, "/levels-log/_doc"); len(docs) != 0 {
+		t.Errorf("Unexpected documents: %+v", docs)
+	}
+
+	cfg := hook.Config()
+	cfg.Levels = LevelRange(logrus.WarnLevel, logrus.InfoLevel)
+	if err := hook.Reconfigure(cfg); err != nil {
+		t.Fatalf("Error reconfiguring the hook: %s", err)
+	}
+	logger.Error("dropped")
+	logger.Warn("shipped")
+	logger.Info("shipped")
+	logger.Debug("dropped")
+	if docs := f.Requests(http.MethodPost, "/levels-log/_doc"); len(docs) != 2 {
+		t.Errorf("Expected 2 documents, got %d", len(docs))
+	}
+
+	cfg.Levels = []logrus.Level{logrus.ErrorLevel, logrus.TraceLevel}
+	if err := hook.Reconfigure(cfg); err != nil {
+		t.Fatalf("Error reconfiguring the hook: %s", err)
+	}
+	logger.Error("shipped")
+	logger.Info("dropped")
+	logger.Trace("shipped")
+	if docs := f.Requests(http.MethodPost, "/levels-log/_doc"); len(docs) != 4 {
+		t.Errorf("Expected 4 documents, got %d", len(docs))
+	}
+
+	cfg.Levels = []logrus.Level{logrus.TraceLevel + 1}
+	if err := hook.Reconfigure(cfg); err == nil {
+		t.Error("Expected an error for an invalid level")
+	}
+}
+
+func TestReconfigureBulk(t *testing.T) {
+	_, client := newFakeElastic(t)
+	hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "config-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+
+	cfg := hook.Config()
+	if cfg.FlushInterval != DefaultFlushInterval {
+		t.Errorf("Unexpected flush interval: %s", cfg.FlushInterval)
+	}
+	cfg.FlushInterval = 0
+	if err := hook.Reconfigure(cfg); err == nil {
+		t.Error("Expected an error for a zero flush interval")
+	}
+	cfg.FlushInterval = time.Millisecond
+	if err := hook.Reconfigure(cfg); err != nil {
+		t.Errorf("Error reconfiguring the hook: %s", err)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	_, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "config-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "elogrus.json")
+	if err := os.WriteFile(path, []byte(`{"level":"warning","fields":{"env":"prod"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := hook.WatchConfig(ctx, path, time.Millisecond); err != nil {
+		t.Fatalf("Error loading the configuration: %s", err)
+	}
+	if cfg := hook.Config(); cfg.Level != logrus.WarnLevel || cfg.Fields["env"] != "prod" {
+		t.Errorf("Unexpected configuration: %+v", cfg)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"level":"debug","levels":["error","info"],"sample_rate":0.5}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for hook.Config().Level != logrus.DebugLevel && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if cfg := hook.Config(); cfg.Level != logrus.DebugLevel || cfg.SampleRate != 0.5 || cfg.Fields["env"] != "prod" ||
+		len(cfg.Levels) != 2 || cfg.Levels[1] != logrus.InfoLevel {
+		t.Errorf("Unexpected configuration after reload: %+v", cfg)
+	}
+}
+
+func TestFlushJitter(t *testing.T) {
+	_, client := newFakeElastic(t)
+	hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "jitter-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+	cfg := hook.Config()
+	cfg.FlushJitter = 0.2
+	if err := hook.Reconfigure(cfg); err != nil {
+		t.Fatalf("Error reconfiguring the hook: %s", err)
+	}
+	if hook.Config().FlushJitter != 0.2 {
+		t.Errorf("Unexpected flush jitter: %g", hook.Config().FlushJitter)
+	}
+	cfg.FlushJitter = 1
+	if err := hook.Reconfigure(cfg); err == nil {
+		t.Error("Expected an error for an invalid flush jitter")
+	}
+}
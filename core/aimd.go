@@ -0,0 +1,101 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultMaxConcurrentFlushes is the default maximum of bulk requests a Batcher
+// sends at the same time.
+const DefaultMaxConcurrentFlushes = 4
+
+// DefaultFlushLatencyTarget is the default latency of a bulk request above
+// which a Batcher considers the cluster overloaded.
+const DefaultFlushLatencyTarget = 2 * time.Second
+
+// aimd limits the number of requests in flight. The limit is adjusted with
+// additive increase/multiplicative decrease: every request completed within
+// the latency target raises it by 1/limit (i.e. by one per round of requests),
+// every throttled or slow one halves it. Without a latency target, the limit
+// is fixed to the maximum.
+type aimd struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    float64
+	max      int
+	target   time.Duration
+	inFlight int
+}
+
+func newAIMD(max int, target time.Duration) *aimd {
+	if max < 1 {
+		max = 1
+	}
+	c := &aimd{limit: 1, max: max, target: target}
+	if target <= 0 {
+		c.limit = float64(max)
+	}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// acquire waits until another request may be sent.
+func (c *aimd) acquire() {
+	c.mu.Lock()
+	for c.inFlight >= int(c.limit) {
+		c.cond.Wait()
+	}
+	c.inFlight++
+	c.mu.Unlock()
+}
+
+// release records the outcome of a request.
+func (c *aimd) release(latency time.Duration, throttled bool) {
+	c.mu.Lock()
+	c.inFlight--
+	switch {
+	case c.target <= 0:
+		// fixed limit
+	case throttled || latency > c.target:
+		c.limit /= 2
+		if c.limit < 1 {
+			c.limit = 1
+		}
+	default:
+		c.limit += 1 / c.limit
+		if c.limit > float64(c.max) {
+			c.limit = float64(c.max)
+		}
+	}
+	c.mu.Unlock()
+	c.cond.Broadcast()
+}
+
+// cancel releases a request that was not sent, leaving the limit unchanged.
+func (c *aimd) cancel() {
+	c.mu.Lock()
+	c.inFlight--
+	c.mu.Unlock()
+	c.cond.Broadcast()
+}
+
+// current returns the current limit.
+func (c *aimd) current() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.limit)
+}
+
+// set changes the maximum and the latency target.
+func (c *aimd) set(max int, target time.Duration) {
+	if max < 1 {
+		max = 1
+	}
+	c.mu.Lock()
+	c.max, c.target = max, target
+	if target <= 0 || c.limit > float64(max) {
+		c.limit = float64(max)
+	}
+	c.mu.Unlock()
+	c.cond.Broadcast()
+}
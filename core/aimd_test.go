@@ -0,0 +1,89 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAIMD(t *testing.T) {
+	c := newAIMD(4, time.Second)
+	if c.current() != 1 {
+		t.Fatalf("Unexpected initial limit: %d", c.current())
+	}
+
+	// Fast requests raise the limit by one per round.
+	for i := 0; i < 20; i++ {
+		c.acquire()
+		c.release(time.Millisecond, false)
+	}
+	if c.current() != 4 {
+		t.Errorf("Expected the limit to reach the maximum, got %d", c.current())
+	}
+
+	c.acquire()
+	c.release(time.Millisecond, true)
+	if c.current() != 2 {
+		t.Errorf("Expected a throttled request to halve the limit, got %d", c.current())
+	}
+	c.acquire()
+	c.release(2*time.Second, false)
+	c.acquire()
+	c.release(2*time.Second, false)
+	if c.current() != 1 {
+		t.Errorf("Expected slow requests to lower the limit to 1, got %d", c.current())
+	}
+
+	// The limit is enforced.
+	c.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		c.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Expected acquire to wait for a release")
+	case <-time.After(10 * time.Millisecond):
+	}
+	c.release(time.Millisecond, false)
+	<-acquired
+}
+
+func TestAIMDFixed(t *testing.T) {
+	c := newAIMD(3, 0)
+	if c.current() != 3 {
+		t.Fatalf("Unexpected initial limit: %d", c.current())
+	}
+	c.acquire()
+	c.release(time.Minute, true)
+	if c.current() != 3 {
+		t.Errorf("Expected a fixed limit, got %d", c.current())
+	}
+
+	c.set(5, time.Second)
+	c.acquire()
+	c.release(2*time.Second, false)
+	if c.current() != 1 {
+		t.Errorf("Expected the limit to adapt with a latency target, got %d", c.current())
+	}
+	c.set(5, 0)
+	if c.current() != 5 {
+		t.Errorf("Expected the limit to be fixed to the maximum, got %d", c.current())
+	}
+}
+
+func TestThrottled(t *testing.T) {
+	res := &BulkResponse{Errors: true, Items: []map[string]BulkResponseItem{
+		{"index": {Status: 201}},
+		{"index": {Status: 429}},
+	}}
+	if !throttled(res, nil) {
+		t.Error("Expected a rejected item to throttle")
+	}
+	if throttled(&BulkResponse{}, nil) || throttled(nil, &ResponseError{StatusCode: 500}) {
+		t.Error("Unexpected throttling")
+	}
+	if !throttled(nil, &ResponseError{StatusCode: 429}) {
+		t.Error("Expected a rejected request to throttle")
+	}
+}
@@ -0,0 +1,675 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"gopkg.in/go-extras/elogrus.v8/internal/bulk"
+)
+
+// DefaultBulkRetries is the number of times a Batcher resends a batch
+// the cluster could not accept.
+const DefaultBulkRetries = 3
+
+// bulkRetryDelay is the pause before a batch is resent.
+var bulkRetryDelay = 100 * time.Millisecond
+
+// indexAction is the action line added in front of every queued document.
+var indexAction = []byte(`{"index":{}}` + "\n")
+
+// Batcher queues encoded documents and sends them to a BulkExecutor in batches,
+// either every flush interval or when Flush is called.
+//
+// Several batches may be sent at the same time. Their number adapts to what the
+// cluster can absorb: it grows while the bulk requests complete within the latency
+// target and halves whenever a request is throttled (HTTP 429) or slow.
+type Batcher struct {
+	executor BulkExecutor
+	index    func() string
+	retries  int
+	writer   *bulk.Writer
+	limiter  *Limiter
+	account  *BudgetAccount
+	flights  *aimd
+	onError  func(err error, data []byte)
+	// recreate, if set, is called when the documents of a batch were rejected
+	// because the index is missing
+	recreate func(index string) error
+	// prepare, if set, is called with the indices named by the documents of a
+	// batch before it is sent, see SetIndexHandler
+	prepare func(index string)
+	// onFlush, if set, is called with the summary of every batch
+	onFlush  func(summary FlushSummary)
+	interval time.Duration
+	// timeout bounds every bulk request if positive
+	timeout time.Duration
+	// pending counts the documents queued but not sent or given up on yet
+	pending InFlight
+	// onReject, if set, is called with every document that was not written
+	onReject func(doc []byte, err error)
+	// spill, if set, keeps the batches that failed with a transient error
+	spill func(batch []byte) error
+	// buffers holds the buffers of sent batches for reuse.
+	buffers chan []byte
+	// metrics receives the measurements of the Batcher, see SetMetrics
+	metrics Metrics
+	// tracer, if set, traces the bulk requests, see SetTracer
+	tracer Tracer
+	// requeue adds the documents rejected with a transient status back to the
+	// queue, see SetRequeue
+	requeue requeuer
+	// breaker, if set, fails the batches fast while the cluster is unhealthy,
+	// see SetBreaker
+	breaker *Breaker
+}
+
+// NewBatcher creates a new Batcher.
+// executor - client sending the batches
+// index - function providing the default index of a batch
+// flushInterval - how often the queue is flushed, a nonpositive value turns automatic flushing off
+// retries - how many times a batch failing with a transient error is resent
+// onError - called with the error and the data of a batch that could not be sent, may be nil;
+// the data are reused once it returns
+func NewBatcher(executor BulkExecutor, index func() string, flushInterval time.Duration, retries int, onError func(err error, data []byte)) *Batcher {
+	b := &Batcher{
+		executor: executor,
+		index:    index,
+		retries:  retries,
+		flights:  newAIMD(DefaultMaxConcurrentFlushes, DefaultFlushLatencyTarget),
+		onError:  onError,
+		interval: flushInterval,
+		buffers:  make(chan []byte, DefaultMaxConcurrentFlushes),
+		metrics:  NopMetrics{},
+	}
+	b.writer = bulk.NewBulkWriter(flushInterval, b.flush)
+	return b
+}
+
+// SetConcurrency changes the maximum number of bulk requests sent at the same
+// time and the latency above which a request is considered slow. Without a
+// latency target, max requests are sent at the same time whatever their latency.
+func (b *Batcher) SetConcurrency(max int, latencyTarget time.Duration) {
+	b.flights.set(max, latencyTarget)
+}
+
+// Concurrency returns the current number of bulk requests that may be sent at the same time.
+func (b *Batcher) Concurrency() int {
+	return b.flights.current()
+}
+
+// SetLimiter bounds the number of documents queued by the Batcher, a document
+// stays queued until the batch it belongs to is sent or given up on.
+// It must be called before the first Add.
+func (b *Batcher) SetLimiter(l *Limiter) {
+	b.limiter = l
+}
+
+// SetBudgetAccount makes the Batcher charge the queued data to a memory budget,
+// the data is charged until the batch it belongs to is sent or given up on.
+// It must be called before the first Add.
+func (b *Batcher) SetBudgetAccount(a *BudgetAccount) {
+	b.account = a
+}
+
+// SetMissingIndexHandler makes the Batcher call recreate when the documents of
+// a batch are rejected because the index does not exist (i.e. it was deleted and
+// automatic index creation is disabled). If recreate succeeds, the batch is resent.
+// It must be called before the first Add.
+func (b *Batcher) SetMissingIndexHandler(recreate func(index string) error) {
+	b.recreate = recreate
+}
+
+// SetIndexHandler makes the Batcher call prepare with every index named by the
+// documents of a batch (see AddWithMeta) before the batch is sent, e.g. to create
+// the indices that do not exist yet. prepare is called by the goroutine sending
+// the batch, so adding a document never waits for the cluster.
+// It must be called before the first Add.
+func (b *Batcher) SetIndexHandler(prepare func(index string)) {
+	b.prepare = prepare
+}
+
+// SetFlushHandler makes the Batcher call onFlush with the summary of every
+// batch once it is sent or given up on.
+// It must be called before the first Add.
+func (b *Batcher) SetFlushHandler(onFlush func(summary FlushSummary)) {
+	b.onFlush = onFlush
+}
+
+// OverflowPolicy defines what happens to the documents that do not fit in the
+// buffer limit of a Batcher, see SetBufferLimit.
+type OverflowPolicy = bulk.OverflowPolicy
+
+const (
+	// OverflowBlock makes Add wait until a flush makes room for the document.
+	OverflowBlock = bulk.Block
+	// OverflowDropOldest drops the oldest buffered documents to make room.
+	OverflowDropOldest = bulk.DropOldest
+	// OverflowDropNewest drops the document, Add returns ErrBufferFull.
+	OverflowDropNewest = bulk.DropNewest
+)
+
+// ErrBufferFull is returned by Add when a document does not fit in the buffer limit.
+var ErrBufferFull = bulk.ErrBufferFull
+
+// ErrBatcherClosed is returned by Add and Flush once the Batcher is closed.
+var ErrBatcherClosed = bulk.ErrWriterClosed
+
+// SetBufferLimit bounds the documents buffered between two flushes to maxBytes
+// (action lines included), policy defines what happens to the documents above
+// it. By default the buffer grows without limit while the cluster is slow.
+// It must be called before the first Add.
+func (b *Batcher) SetBufferLimit(maxBytes int, policy OverflowPolicy) {
+	b.writer.SetMaxSize(maxBytes, policy)
+	b.writer.SetDropHandler(func(data []byte) {
+		// every write is a single document
+		b.release(1, len(data))
+		b.pending.Done(1)
+		b.metrics.DocumentsDropped(1)
+	})
+}
+
+// BufferDropped returns the number of documents dropped because the buffer
+// limit was reached, see SetBufferLimit.
+func (b *Batcher) BufferDropped() uint64 {
+	return b.writer.Dropped()
+}
+
+// SetMetrics makes the Batcher report the documents it sends, fails and drops,
+// its retries and the number of queued documents to m. The entries are not
+// reported, the Batcher only sees documents.
+// It must be called before the first Add.
+func (b *Batcher) SetMetrics(m Metrics) {
+	b.metrics = m
+}
+
+// SetTracer makes the Batcher trace every bulk request with t.
+// It must be called before the first Add.
+func (b *Batcher) SetTracer(t Tracer) {
+	b.tracer = t
+}
+
+// SetBreaker makes the Batcher ask breaker before sending every bulk request
+// and record its outcome. The batches are not sent while breaker is open, they
+// fail with ErrCircuitOpen, which is a transient error: they are spilled if a
+// spill handler is set.
+// It must be called before the first Add.
+func (b *Batcher) SetBreaker(breaker *Breaker) {
+	b.breaker = breaker
+}
+
+// SetRejectHandler makes the Batcher call onReject with every document that
+// was not written, once its batch is given up on, together with the error of
+// the request or the error reported by the cluster for the document. The
+// document is reused once onReject returns.
+// It must be called before the first Add.
+func (b *Batcher) SetRejectHandler(onReject func(doc []byte, err error)) {
+	b.onReject = onReject
+}
+
+// SetSpillHandler makes the Batcher pass the batches it gives up on because of
+// a transient error (e.g. the cluster is unreachable) to spill, which keeps them
+// to be sent later. The batches spill accepts are not reported as failed; if it
+// returns an error, they are handled like other failed batches. The action
+// lines of the batch name the index it was sent to, so it can be sent as is
+// whatever the default index is by then. The batch is reused once spill returns.
+// It must be called before the first Add.
+func (b *Batcher) SetSpillHandler(spill func(batch []byte) error) {
+	b.spill = spill
+}
+
+// SetRequestTimeout bounds the duration of every bulk request, a timed out
+// request is retried like other transient errors. By default there is no timeout.
+// It must be called before the first Add.
+func (b *Batcher) SetRequestTimeout(timeout time.Duration) {
+	b.timeout = timeout
+}
+
+// Add queues a document for indexing into the default index. The document must
+// not contain newlines.
+func (b *Batcher) Add(doc []byte) error {
+	data := make([]byte, 0, len(indexAction)+len(doc)+1)
+	data = append(data, indexAction...)
+	return b.add(data, doc)
+}
+
+// AddTo queues a document for indexing into the given index. The document must
+// not contain newlines.
+func (b *Batcher) AddTo(index string, doc []byte) error {
+	return b.AddWithMeta(index, DocumentMeta{}, doc)
+}
+
+// AddWithMeta queues a document for indexing with the given metadata into the
+// given index, or the default index if index is empty. A document with the same
+// _id is replaced, so a document sent twice is indexed once, unless meta.Create
+// is set. The document must not contain newlines.
+func (b *Batcher) AddWithMeta(index string, meta DocumentMeta, doc []byte) error {
+	if index == "" && meta == (DocumentMeta{}) {
+		return b.Add(doc)
+	}
+	action := struct {
+		Index string `json:"_index,omitempty"`
+		DocumentMeta
+	}{index, meta}
+	line, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, 0, len(line)+len(doc)+13)
+	if meta.Create {
+		data = append(data, `{"create":`...)
+	} else {
+		data = append(data, `{"index":`...)
+	}
+	data = append(data, line...)
+	data = append(data, "}\n"...)
+	return b.add(data, doc)
+}
+
+// add queues a document after its action line.
+func (b *Batcher) add(data, doc []byte) error {
+	data = append(data, doc...)
+	data = append(data, '\n')
+
+	if b.limiter != nil {
+		if err := b.limiter.Acquire(); err != nil {
+			return err
+		}
+	}
+	if b.account != nil {
+		if err := b.account.Reserve(len(data)); err != nil {
+			b.release(1, 0)
+			return err
+		}
+	}
+	b.pending.Add(1)
+	if _, err := b.writer.Write(data); err != nil {
+		b.pending.Done(1)
+		b.release(1, len(data))
+		b.metrics.DocumentsDropped(1)
+		return err
+	}
+	b.metrics.QueueDepth(b.pending.Len())
+	return nil
+}
+
+// release frees the room of docs documents of size bytes in the limiter and the budget.
+func (b *Batcher) release(docs, size int) {
+	if b.limiter != nil {
+		b.limiter.Release(docs)
+	}
+	if b.account != nil {
+		b.account.Release(size)
+	}
+}
+
+// Pending returns the number of documents added and not delivered yet.
+func (b *Batcher) Pending() int {
+	return b.pending.Len()
+}
+
+// Flush sends the queued documents.
+func (b *Batcher) Flush() error {
+	return b.writer.Flush()
+}
+
+// Wait waits until the queued documents are sent or given up on, or the
+// context is done. Documents queued meanwhile are waited for too. Call Flush
+// first to send the queued documents without waiting for the flush interval.
+func (b *Batcher) Wait(ctx context.Context) error {
+	return b.pending.Wait(ctx)
+}
+
+// SetFlushInterval changes how often the queue is flushed.
+// It does not resize the buffers allocated by Preallocate.
+func (b *Batcher) SetFlushInterval(d time.Duration) error {
+	return b.writer.SetFlushInterval(d)
+}
+
+// SetFlushJitter makes every flush interval vary randomly by up to the jitter
+// fraction of the flush interval, so the Batchers started at the same time do
+// not send their batches at the same time. The jitter must be in [0, 1).
+func (b *Batcher) SetFlushJitter(jitter float64) error {
+	return b.writer.SetFlushJitter(jitter)
+}
+
+// Preallocate allocates the buffers for the expected throughput up front, so
+// they do not need to grow during the first traffic spike. It must be called
+// before the first Add.
+// docsPerSecond - expected number of documents per second
+// avgDocSize - expected average size of a serialized document in bytes
+func (b *Batcher) Preallocate(docsPerSecond, avgDocSize int) {
+	interval := b.interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	size := int(float64(docsPerSecond) * interval.Seconds() * float64(len(indexAction)+avgDocSize+1))
+	b.writer.Preallocate(size)
+	for len(b.buffers) < cap(b.buffers) {
+		b.buffers <- make([]byte, 0, size)
+	}
+}
+
+// Close sends the queued documents and stops the Batcher.
+func (b *Batcher) Close() error {
+	return b.writer.Close()
+}
+
+// flush sends the data of the writer in the background, as soon as the
+// concurrency limit allows.
+func (b *Batcher) flush(data []byte) error {
+	var batch []byte
+	select {
+	case batch = <-b.buffers:
+	default:
+	}
+	batch = append(batch[:0], data...)
+	var attempts []int
+	if b.requeue.max > 0 {
+		batch, attempts = stripRequeues(batch)
+	}
+	b.flights.acquire()
+	go b.send(batch, attempts)
+	return nil
+}
+
+// send sends a batch, retrying transient errors. attempts holds the number of
+// times its items were requeued, see SetRequeue. The first attempt must have
+// been acquired from the concurrency limit by the caller.
+func (b *Batcher) send(batch []byte, attempts []int) {
+	// Every document takes an action line and a document line.
+	docs := bytes.Count(batch, []byte{'\n'}) / 2
+	defer func() {
+		b.pending.Done(docs)
+		b.metrics.QueueDepth(b.pending.Len())
+	}()
+	// the requeued documents keep their room
+	requeued, requeuedSize := 0, 0
+	defer func() {
+		b.release(docs-requeued, len(batch)-requeuedSize)
+	}()
+	summary := FlushSummary{Documents: docs, Bytes: len(batch)}
+	var err error
+	var res *BulkResponse
+	body := batch
+	if b.prepare != nil {
+		b.prepareIndices(batch)
+	}
+	// target is the index the batch was last sent to
+	var target string
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(bulkRetryDelay)
+			b.flights.acquire()
+			b.metrics.RequestRetried()
+		}
+		if b.breaker != nil {
+			if err = b.breaker.Allow(); err != nil {
+				b.flights.cancel()
+				res = nil
+				break
+			}
+		}
+		start := time.Now()
+		// A successful response might still contain errors for particular documents...
+		index := b.index()
+		target = index
+		ctx, cancel := b.requestContext()
+		n := bytes.Count(body, []byte{'\n'}) / 2
+		end := func(error) {}
+		if b.tracer != nil {
+			ctx, end = b.tracer.Start(ctx, OpBulk, index, n, len(body))
+		}
+		res, err = b.executor.Bulk(ctx, index, body)
+		end(err)
+		cancel()
+		if b.breaker != nil {
+			b.breaker.Record(err)
+		}
+		took := time.Since(start)
+		b.metrics.RequestSent(n, len(body), took)
+		b.flights.release(took, throttled(res, err))
+		if err == nil && b.recreate != nil && attempt < b.retries {
+			rejected, missing, rejectedAttempts := missingIndices(res, index, body, attempts)
+			if len(missing) > 0 {
+				summary.count(res, 0, true)
+				body, attempts = rejected, rejectedAttempts
+				// resend the rejected documents once their indices exist again
+				for _, index := range missing {
+					if err = b.recreate(index); err != nil {
+						break
+					}
+				}
+				if err == nil {
+					continue
+				}
+			}
+		}
+		if err == nil || attempt >= b.retries || !IsRetryable(err) {
+			break
+		}
+	}
+	if err == nil {
+		res, body, requeued, requeuedSize = b.requeueItems(res, body, attempts)
+		summary.Requeued = requeued
+	}
+	switch {
+	case err != nil && b.spill != nil && IsRetryable(err) && b.spill(withIndex(body, target, b.index)) == nil:
+		summary.Spilled = bytes.Count(body, []byte{'\n'}) / 2
+	case err != nil:
+		summary.failed(err, bytes.Count(body, []byte{'\n'})/2)
+		if b.onError != nil {
+			b.onError(err, body)
+		}
+		if b.onReject != nil {
+			reject(res, err, body, b.onReject)
+		}
+	default:
+		summary.count(res, bytes.Count(body, []byte{'\n'})/2, false)
+		if b.onReject != nil {
+			reject(res, err, body, b.onReject)
+		}
+	}
+	if summary.Succeeded > 0 {
+		b.metrics.DocumentsSent(summary.Succeeded)
+	}
+	if failed := summary.failures(); failed > 0 {
+		b.metrics.DocumentsFailed(failed)
+	}
+	if b.onFlush != nil {
+		b.onFlush(summary)
+	}
+	select {
+	case b.buffers <- batch:
+	default:
+	}
+}
+
+// requestContext returns the context of a bulk request, see SetRequestTimeout.
+func (b *Batcher) requestContext() (context.Context, context.CancelFunc) {
+	if b.timeout > 0 {
+		return context.WithTimeout(context.Background(), b.timeout)
+	}
+	return context.Background(), func() {}
+}
+
+// throttled reports whether the cluster rejected a bulk request or any of
+// its documents because it is overloaded.
+func throttled(res *BulkResponse, err error) bool {
+	var e *ResponseError
+	if errors.As(err, &e) {
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	if res == nil || !res.Errors {
+		return false
+	}
+	for _, item := range res.Items {
+		for _, result := range item {
+			if result.Status == http.StatusTooManyRequests {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// missingIndices returns the indices that do not exist according to a bulk response,
+// together with the actions and documents of body rejected because of them and
+// the requeue attempts of these (see SetRequeue). index is the default index of
+// the request.
+func missingIndices(res *BulkResponse, index string, body []byte, attempts []int) ([]byte, []string, []int) {
+	if !res.Errors {
+		return body, nil, attempts
+	}
+	var rejected []byte
+	var missing []string
+	var rejectedAttempts []int
+	rest := body
+	for i, item := range res.Items {
+		var doc []byte
+		doc, rest = nextBulkItem(rest)
+		for _, result := range item {
+			if result.Error == nil || result.Error.Type != indexNotFound {
+				continue
+			}
+			name := result.Index
+			if name == "" {
+				name = index
+			}
+			if !contains(missing, name) {
+				missing = append(missing, name)
+			}
+			rejected = append(rejected, doc...)
+			if i < len(attempts) {
+				rejectedAttempts = append(rejectedAttempts, attempts[i])
+			} else if attempts != nil {
+				rejectedAttempts = append(rejectedAttempts, 0)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return body, nil, attempts
+	}
+	return rejected, missing, rejectedAttempts
+}
+
+// reject calls onReject with the documents of body that were not written,
+// according to the error of the request or its response.
+func reject(res *BulkResponse, err error, body []byte, onReject func(doc []byte, err error)) {
+	if err == nil && !res.Errors {
+		return
+	}
+	for rest, i := body, 0; len(rest) > 0; i++ {
+		var item []byte
+		item, rest = nextBulkItem(rest)
+		if err != nil {
+			onReject(bulkDocument(item), err)
+			continue
+		}
+		if i >= len(res.Items) {
+			return
+		}
+		for _, result := range res.Items[i] {
+			if result.Error == nil && result.Status < http.StatusMultipleChoices {
+				continue
+			}
+			e := &ResponseError{StatusCode: result.Status}
+			if result.Error != nil {
+				e.Type, e.Reason = result.Error.Type, result.Error.Reason
+			}
+			onReject(bulkDocument(item), e)
+		}
+	}
+}
+
+// bulkDocument returns the document line of a bulk item, without its action
+// line and the trailing newline.
+func bulkDocument(item []byte) []byte {
+	if i := bytes.IndexByte(item, '\n'); i >= 0 {
+		item = item[i+1:]
+	}
+	return bytes.TrimSuffix(item, []byte{'\n'})
+}
+
+// withIndex returns body with index named in the action lines that do not name
+// one, or the index returned by defaultIndex if index is empty.
+func withIndex(body []byte, index string, defaultIndex func() string) []byte {
+	if index == "" {
+		index = defaultIndex()
+	}
+	name, err := json.Marshal(index)
+	if err != nil {
+		return body
+	}
+	field := append(append([]byte(`"_index":`), name...), ',')
+	out := make([]byte, 0, len(body)+bytes.Count(body, []byte{'\n'})/2*len(field))
+	for rest := body; len(rest) > 0; {
+		var item []byte
+		item, rest = nextBulkItem(rest)
+		action := item
+		if nl := bytes.IndexByte(item, '\n'); nl >= 0 {
+			action = item[:nl]
+		}
+		// the action lines are {"index":{...}} or {"create":{...}}
+		i := bytes.Index(action, []byte(`:{`))
+		if i < 0 || bytes.Contains(action, []byte(`"_index"`)) {
+			out = append(out, item...)
+			continue
+		}
+		i += 2
+		out = append(out, item[:i]...)
+		if item[i] == '}' {
+			out = append(out, field[:len(field)-1]...)
+		} else {
+			out = append(out, field...)
+		}
+		out = append(out, item[i:]...)
+	}
+	return out
+}
+
+// nextBulkItem splits the action and the document lines of the first item off a bulk body.
+func nextBulkItem(body []byte) (item, rest []byte) {
+	end := 0
+	for n := 0; n < 2 && end < len(body); n++ {
+		if i := bytes.IndexByte(body[end:], '\n'); i >= 0 {
+			end += i + 1
+		} else {
+			end = len(body)
+		}
+	}
+	return body[:end], body[end:]
+}
+
+// prepareIndices calls the index handler with every index named by the action
+// lines of body, once per index.
+func (b *Batcher) prepareIndices(body []byte) {
+	var seen []string
+	for rest := body; len(rest) > 0; {
+		var item []byte
+		item, rest = nextBulkItem(rest)
+		action := item
+		if nl := bytes.IndexByte(item, '\n'); nl >= 0 {
+			action = item[:nl]
+		}
+		if !bytes.Contains(action, []byte(`"_index"`)) {
+			continue
+		}
+		if _, name := bulkAction(item, ""); name != "" && !contains(seen, name) {
+			seen = append(seen, name)
+			b.prepare(name)
+		}
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,390 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeExecutor struct {
+	mu      sync.Mutex
+	bodies  []string
+	indices []string
+	errs    []error
+}
+
+func (e *fakeExecutor) Bulk(_ context.Context, index string, body []byte) (*BulkResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.bodies = append(e.bodies, string(body))
+	e.indices = append(e.indices, index)
+	if len(e.errs) > 0 {
+		err := e.errs[0]
+		e.errs = e.errs[1:]
+		return nil, err
+	}
+	return &BulkResponse{}, nil
+}
+
+func TestBatcher(t *testing.T) {
+	bulkRetryDelay = 0
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		failed   bool
+	}{
+		{"success", nil, 1, false},
+		{"retried", []error{errors.New("connection refused"), &ResponseError{StatusCode: http.StatusTooManyRequests}}, 3, false},
+		{"exhausted", []error{&ResponseError{StatusCode: 503}, &ResponseError{StatusCode: 503}, &ResponseError{StatusCode: 503}}, 3, true},
+		{"rejected", []error{&ResponseError{StatusCode: http.StatusBadRequest}}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &fakeExecutor{errs: tt.errs}
+			failed := make(chan error, 1)
+			b := NewBatcher(executor, func() string { return "logs" }, 0, 2, func(err error, data []byte) {
+				failed <- err
+			})
+			if err := b.Add([]byte(`{"a":1}`)); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.Add([]byte(`{"a":2}`)); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.Close(); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			executor.mu.Lock()
+			defer executor.mu.Unlock()
+			if len(executor.bodies) != tt.attempts {
+				t.Errorf("Expected %d attempts, got %d", tt.attempts, len(executor.bodies))
+			}
+			expected := "{\"index\":{}}\n{\"a\":1}\n{\"index\":{}}\n{\"a\":2}\n"
+			for i, body := range executor.bodies {
+				if body != expected || executor.indices[i] != "logs" {
+					t.Errorf("Unexpected batch %d for %s: %q", i, executor.indices[i], body)
+				}
+			}
+			if (len(failed) > 0) != tt.failed {
+				t.Errorf("Unexpected error handler call, expected: %v", tt.failed)
+			}
+		})
+	}
+}
+
+type bulkFunc func(ctx context.Context, index string, body []byte) (*BulkResponse, error)
+
+func (f bulkFunc) Bulk(ctx context.Context, index string, body []byte) (*BulkResponse, error) {
+	return f(ctx, index, body)
+}
+
+func TestBatcherMissingIndex(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	var recreated []string
+	executor := bulkFunc(func(_ context.Context, index string, body []byte) (*BulkResponse, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(body))
+		if len(recreated) == 0 {
+			return &BulkResponse{Errors: true, Items: []map[string]BulkResponseItem{
+				{"index": {Index: "logs-1", Status: http.StatusCreated}},
+				{"index": {Index: "logs-2", Status: http.StatusNotFound, Error: &ErrorCause{Type: "index_not_found_exception"}}},
+			}}, nil
+		}
+		return &BulkResponse{}, nil
+	})
+	b := NewBatcher(executor, func() string { return "logs" }, 0, 2, nil)
+	b.SetMissingIndexHandler(func(index string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		recreated = append(recreated, index)
+		return nil
+	})
+	if err := b.AddTo("logs-1", []byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddTo("logs-2", []byte(`{"a":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{
+		"{\"index\":{\"_index\":\"logs-1\"}}\n{\"a\":1}\n{\"index\":{\"_index\":\"logs-2\"}}\n{\"a\":2}\n",
+		"{\"index\":{\"_index\":\"logs-2\"}}\n{\"a\":2}\n",
+	}
+	if !reflect.DeepEqual(bodies, expected) {
+		t.Errorf("Unexpected batches: %q", bodies)
+	}
+	if !reflect.DeepEqual(recreated, []string{"logs-2"}) {
+		t.Errorf("Unexpected recreated indices: %v", recreated)
+	}
+}
+
+func TestBatcherIndexHandler(t *testing.T) {
+	executor := &fakeExecutor{}
+	b := NewBatcher(executor, func() string { return "logs" }, 0, 0, nil)
+	var mu sync.Mutex
+	var prepared []string
+	b.SetIndexHandler(func(index string) {
+		mu.Lock()
+		defer mu.Unlock()
+		prepared = append(prepared, index)
+	})
+	for _, index := range []string{"logs-1", "", "logs-2", "logs-1"} {
+		if err := b.AddTo(index, []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(prepared, []string{"logs-1", "logs-2"}) {
+		t.Errorf("Unexpected prepared indices: %v", prepared)
+	}
+	executor.mu.Lock()
+	defer executor.mu.Unlock()
+	if len(executor.bodies) != 1 {
+		t.Errorf("Expected one batch, got %q", executor.bodies)
+	}
+}
+
+func TestBatcherFlushSummary(t *testing.T) {
+	bulkRetryDelay = 0
+	for _, fail := range []bool{false, true} {
+		executor := bulkFunc(func(context.Context, string, []byte) (*BulkResponse, error) {
+			if fail {
+				return nil, &ResponseError{StatusCode: http.StatusForbidden, Type: "security_exception"}
+			}
+			return &BulkResponse{Took: 5, Errors: true, Items: []map[string]BulkResponseItem{
+				{"index": {Status: http.StatusCreated}},
+				{"index": {Status: http.StatusBadRequest, Error: &ErrorCause{Type: "mapper_parsing_exception"}}},
+				{"index": {Status: http.StatusCreated}},
+			}}, nil
+		})
+		summaries := make(chan FlushSummary, 1)
+		b := NewBatcher(executor, func() string { return "logs" }, 0, 2, nil)
+		b.SetFlushHandler(func(summary FlushSummary) { summaries <- summary })
+		for i := 0; i < 3; i++ {
+			if err := b.Add([]byte(`{"a":1}`)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := b.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		summary := <-summaries
+		if summary.Documents != 3 || summary.Bytes != 63 {
+			t.Errorf("Unexpected batch size: %+v", summary)
+		}
+		if fail {
+			if summary.Succeeded != 0 || summary.Failed["security_exception"] != 3 || summary.Err == nil {
+				t.Errorf("Unexpected summary of a failed request: %+v", summary)
+			}
+			continue
+		}
+		if summary.Took != 5*time.Millisecond || summary.Succeeded != 2 || summary.Failed["mapper_parsing_exception"] != 1 || summary.Err != nil {
+			t.Errorf("Unexpected summary: %+v", summary)
+		}
+	}
+}
+
+func TestBatcherRejectHandler(t *testing.T) {
+	bulkRetryDelay = 0
+	for _, fail := range []bool{false, true} {
+		executor := bulkFunc(func(context.Context, string, []byte) (*BulkResponse, error) {
+			if fail {
+				return nil, &ResponseError{StatusCode: http.StatusForbidden, Type: "security_exception"}
+			}
+			return &BulkResponse{Errors: true, Items: []map[string]BulkResponseItem{
+				{"index": {Status: http.StatusCreated}},
+				{"index": {Status: http.StatusBadRequest, Error: &ErrorCause{Type: "mapper_parsing_exception", Reason: "bad"}}},
+			}}, nil
+		})
+		var mu sync.Mutex
+		var docs []string
+		var errs []error
+		done := make(chan struct{})
+		b := NewBatcher(executor, func() string { return "logs" }, 0, 2, nil)
+		b.SetRejectHandler(func(doc []byte, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			docs = append(docs, string(doc))
+			errs = append(errs, err)
+		})
+		b.SetFlushHandler(func(FlushSummary) { close(done) })
+		if err := b.Add([]byte(`{"a":1}`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Add([]byte(`{"a":2}`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Close(); err != nil {
+			t.Fatal(err)
+		}
+		<-done
+
+		mu.Lock()
+		var e *ResponseError
+		if fail {
+			if !reflect.DeepEqual(docs, []string{`{"a":1}`, `{"a":2}`}) || !errors.As(errs[0], &e) || e.Type != "security_exception" {
+				t.Errorf("Unexpected rejected documents of a failed request: %q %v", docs, errs)
+			}
+		} else if !reflect.DeepEqual(docs, []string{`{"a":2}`}) || !errors.As(errs[0], &e) ||
+			e.StatusCode != http.StatusBadRequest || e.Type != "mapper_parsing_exception" {
+			t.Errorf("Unexpected rejected documents: %q %v", docs, errs)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestBatcherSpillHandler(t *testing.T) {
+	bulkRetryDelay = 0
+	for _, status := range []int{http.StatusServiceUnavailable, http.StatusBadRequest} {
+		executor := &fakeExecutor{errs: []error{
+			&ResponseError{StatusCode: status}, &ResponseError{StatusCode: status}, &ResponseError{StatusCode: status},
+		}}
+		var spilled []string
+		failed := make(chan error, 1)
+		summaries := make(chan FlushSummary, 1)
+		b := NewBatcher(executor, func() string { return "logs" }, 0, 2, func(err error, data []byte) { failed <- err })
+		b.SetSpillHandler(func(batch []byte) error {
+			spilled = append(spilled, string(batch))
+			return nil
+		})
+		b.SetFlushHandler(func(summary FlushSummary) { summaries <- summary })
+		if err := b.Add([]byte(`{"a":1}`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.AddWithMeta("", DocumentMeta{ID: "2"}, []byte(`{"a":2}`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.AddTo("other", []byte(`{"a":3}`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		summary := <-summaries
+		if status == http.StatusBadRequest {
+			if len(spilled) != 0 || summary.Spilled != 0 || len(failed) != 1 {
+				t.Errorf("Expected a rejected batch not to be spilled: %q %+v", spilled, summary)
+			}
+			continue
+		}
+		// the action lines name the index the batch was sent to
+		expected := "{\"index\":{\"_index\":\"logs\"}}\n{\"a\":1}\n" +
+			"{\"index\":{\"_index\":\"logs\",\"_id\":\"2\"}}\n{\"a\":2}\n" +
+			"{\"index\":{\"_index\":\"other\"}}\n{\"a\":3}\n"
+		if !reflect.DeepEqual(spilled, []string{expected}) || summary.Spilled != 3 || summary.Err != nil || len(failed) != 0 {
+			t.Errorf("Unexpected spill: %q %+v", spilled, summary)
+		}
+	}
+}
+
+func TestBatcherRequestTimeout(t *testing.T) {
+	executor := bulkFunc(func(ctx context.Context, _ string, _ []byte) (*BulkResponse, error) {
+		// a hung node
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	failed := make(chan error, 1)
+	b := NewBatcher(executor, func() string { return "logs" }, 0, 0, func(err error, data []byte) {
+		failed <- err
+	})
+	b.SetRequestTimeout(10 * time.Millisecond)
+	if err := b.Add([]byte(`{"a":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-failed:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the request to time out, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The request was not aborted")
+	}
+}
+
+func TestBatcherRequeue(t *testing.T) {
+	executor := bulkFunc(func(_ context.Context, _ string, body []byte) (*BulkResponse, error) {
+		res := &BulkResponse{}
+		for rest := body; len(rest) > 0; {
+			var item []byte
+			item, rest = nextBulkItem(rest)
+			result := BulkResponseItem{Status: http.StatusCreated}
+			if strings.Contains(string(item), "throttled") {
+				res.Errors = true
+				result = BulkResponseItem{Status: http.StatusTooManyRequests, Error: &ErrorCause{Type: "es_rejected_execution_exception"}}
+			}
+			res.Items = append(res.Items, map[string]BulkResponseItem{"index": result})
+		}
+		return res, nil
+	})
+	b := NewBatcher(executor, func() string { return "logs" }, 0, 0, nil)
+	summaries := make(chan FlushSummary, 1)
+	b.SetFlushHandler(func(summary FlushSummary) {
+		summaries <- summary
+	})
+	limiter := NewLimiter(3, false)
+	b.SetLimiter(limiter)
+	b.SetRequeue(2)
+	// identical documents are requeued as many times each
+	for _, doc := range []string{`{"a":"written"}`, `{"a":"throttled"}`, `{"a":"throttled"}`} {
+		if err := b.Add([]byte(doc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, want := range []FlushSummary{
+		{Documents: 3, Succeeded: 1, Requeued: 2},
+		{Documents: 2, Requeued: 2},
+		{Documents: 2, Failed: map[string]int{"es_rejected_execution_exception": 2}},
+	} {
+		if err := b.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		got := <-summaries
+		if got.Documents != want.Documents || got.Succeeded != want.Succeeded || got.Requeued != want.Requeued ||
+			!reflect.DeepEqual(got.Failed, want.Failed) {
+			t.Errorf("Flush %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Wait(ctx); err != nil {
+		t.Fatalf("Expected no pending documents, got %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := limiter.Acquire(); err != nil {
+			t.Errorf("Expected the room of the documents to be released, got %s", err)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
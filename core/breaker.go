@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of sending a request while a Breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Breaker is a circuit breaker: it opens after a number of consecutive requests
+// failing with a transient error, so the following requests fail fast instead of
+// waiting for an unhealthy cluster. While it is open, a single probe request is
+// let through every interval, the Breaker closes again once one succeeds.
+// The responses of the cluster that are not transient errors (e.g. a mapping
+// conflict) mean the cluster is reachable and close the Breaker as well, the
+// canceled requests are not counted.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	interval  time.Duration
+	failures  int
+	// openUntil is when the next probe may be sent
+	openUntil time.Time
+	probing   bool
+	onChange  func(open bool)
+}
+
+// NewBreaker creates a new Breaker opening after threshold consecutive failures
+// and probing the cluster every interval while it is open. onChange, if not nil,
+// is called when the Breaker opens or closes.
+func NewBreaker(threshold int, interval time.Duration, onChange func(open bool)) *Breaker {
+	return &Breaker{threshold: threshold, interval: interval, onChange: onChange}
+}
+
+// Allow returns ErrCircuitOpen if the Breaker is open and no probe is due,
+// nil otherwise. The outcome of every allowed request must be passed to Record.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// Record records the outcome of an allowed request.
+func (b *Breaker) Record(err error) {
+	if errors.Is(err, context.Canceled) {
+		// abandoned by the caller, it tells nothing about the cluster
+		b.mu.Lock()
+		b.probing = false
+		b.mu.Unlock()
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	wasOpen := b.failures >= b.threshold
+	if err != nil && IsRetryable(err) {
+		b.failures++
+		b.openUntil = time.Now().Add(b.interval)
+	} else {
+		b.failures = 0
+	}
+	open := b.failures >= b.threshold
+	b.mu.Unlock()
+	if open != wasOpen && b.onChange != nil {
+		b.onChange(open)
+	}
+}
+
+// Open reports whether the Breaker is open.
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold
+}
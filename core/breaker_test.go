@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	var changes []bool
+	b := NewBreaker(2, 20*time.Millisecond, func(open bool) { changes = append(changes, open) })
+	unavailable := &ResponseError{StatusCode: http.StatusInternalServerError}
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Expected a closed breaker, got %v", err)
+		}
+		b.Record(unavailable)
+	}
+	if !b.Open() {
+		t.Fatal("Expected the breaker to open after 2 failures")
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected a probe, got %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected a single probe, got %v", err)
+	}
+	b.Record(unavailable)
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected the breaker to stay open after a failed probe, got %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected a probe, got %v", err)
+	}
+	b.Record(nil)
+	if b.Open() {
+		t.Error("Expected the breaker to close after a successful probe")
+	}
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("Expected the breaker to open and close, got %v", changes)
+	}
+
+	// rejected documents and canceled requests do not open it
+	b.Record(unavailable)
+	b.Record(&ResponseError{StatusCode: http.StatusBadRequest})
+	b.Record(unavailable)
+	b.Record(context.Canceled)
+	if b.Open() {
+		t.Error("Expected the breaker to count consecutive transient failures only")
+	}
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrMemoryBudgetExceeded is returned when an entry does not fit in the memory budget.
+var ErrMemoryBudgetExceeded = errors.New("memory budget exceeded")
+
+// MemoryBudget limits the memory taken by queued documents across several hooks
+// (or any other users with their own BudgetAccount).
+//
+// As long as the total stays within the budget, all reservations succeed. Once it
+// is exceeded, only the accounts using less than their fair share (the budget divided
+// by the number of accounts) can reserve more memory, so the accounts taking the most
+// memory shed their entries first while the others keep working. The total can thus
+// exceed the budget, but never more than twice.
+type MemoryBudget struct {
+	mu       sync.Mutex
+	size     int64
+	used     int64
+	accounts int
+}
+
+// NewMemoryBudget creates a new MemoryBudget of size bytes.
+func NewMemoryBudget(size int64) *MemoryBudget {
+	return &MemoryBudget{size: size}
+}
+
+// Used returns the number of reserved bytes.
+func (b *MemoryBudget) Used() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// NewAccount creates an account taking its memory from the budget.
+func (b *MemoryBudget) NewAccount() *BudgetAccount {
+	b.mu.Lock()
+	b.accounts++
+	b.mu.Unlock()
+	return &BudgetAccount{budget: b}
+}
+
+// BudgetAccount is the share of a single user of a MemoryBudget.
+type BudgetAccount struct {
+	budget  *MemoryBudget
+	used    int64
+	dropped uint64
+	closed  bool
+}
+
+// Reserve reserves n bytes, it fails with ErrMemoryBudgetExceeded
+// (and counts the entry as dropped) if they do not fit or the account is closed.
+func (a *BudgetAccount) Reserve(n int) error {
+	b := a.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if a.closed || b.used+int64(n) > b.size && a.used+int64(n) > b.size/int64(b.accounts) {
+		a.dropped++
+		return ErrMemoryBudgetExceeded
+	}
+	a.used += int64(n)
+	b.used += int64(n)
+	return nil
+}
+
+// Release frees n reserved bytes.
+func (a *BudgetAccount) Release(n int) {
+	b := a.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if int64(n) > a.used {
+		n = int(a.used)
+	}
+	a.used -= int64(n)
+	b.used -= int64(n)
+}
+
+// Close removes the account from the budget and frees its reserved bytes, so
+// the fair share of the other accounts grows back. Releasing memory afterwards
+// does nothing. Close can be called several times.
+func (a *BudgetAccount) Close() {
+	b := a.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if a.closed {
+		return
+	}
+	a.closed = true
+	b.accounts--
+	b.used -= a.used
+	a.used = 0
+}
+
+// Used returns the number of bytes reserved by the account.
+func (a *BudgetAccount) Used() int64 {
+	a.budget.mu.Lock()
+	defer a.budget.mu.Unlock()
+	return a.used
+}
+
+// Dropped returns the number of rejected reservations.
+func (a *BudgetAccount) Dropped() uint64 {
+	a.budget.mu.Lock()
+	defer a.budget.mu.Unlock()
+	return a.dropped
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	budget := NewMemoryBudget(100)
+	noisy := budget.NewAccount()
+	quiet := budget.NewAccount()
+
+	// Below the budget, any account can take the memory.
+	for i := 0; i < 9; i++ {
+		if err := noisy.Reserve(10); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if err := noisy.Reserve(20); !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Fatalf("Expected the noisy account to be shed, got %v", err)
+	}
+	// The quiet account still gets its fair share...
+	for i := 0; i < 5; i++ {
+		if err := quiet.Reserve(10); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	// ... but not more.
+	if err := quiet.Reserve(10); !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Fatalf("Expected the quiet account to be shed, got %v", err)
+	}
+	if budget.Used() != 140 || noisy.Used() != 90 || quiet.Used() != 50 {
+		t.Errorf("Unexpected usage: %d, %d, %d", budget.Used(), noisy.Used(), quiet.Used())
+	}
+	if noisy.Dropped() != 1 || quiet.Dropped() != 1 {
+		t.Errorf("Unexpected drops: %d, %d", noisy.Dropped(), quiet.Dropped())
+	}
+
+	noisy.Release(90)
+	if err := noisy.Reserve(40); err != nil {
+		t.Errorf("Unexpected error after a release: %s", err)
+	}
+	if budget.Used() != 90 {
+		t.Errorf("Unexpected usage: %d", budget.Used())
+	}
+
+	// Once the quiet account is closed, the noisy one has the whole budget.
+	quiet.Close()
+	quiet.Close()
+	if budget.Used() != 40 {
+		t.Errorf("Expected the memory of the closed account to be freed, got %d", budget.Used())
+	}
+	if err := noisy.Reserve(60); err != nil {
+		t.Errorf("Unexpected error with a single account: %s", err)
+	}
+	if err := quiet.Reserve(1); !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Errorf("Expected a closed account not to reserve memory, got %v", err)
+	}
+	quiet.Release(50)
+	if budget.Used() != 100 {
+		t.Errorf("Unexpected usage: %d", budget.Used())
+	}
+}
@@ -0,0 +1,170 @@
+// Package core contains the parts of elogrus that do not depend on a particular
+// Elasticsearch (or OpenSearch) client version. Version-specific behaviour lives
+// in the es6, es7, es8 and opensearch packages, which provide Client implementations.
+//
+// The package does not import any official client: the document model (Message,
+// Document), the queue limits (Limiter, MemoryBudget) and the bulk batching
+// (Batcher) only need a Transport, e.g. an HTTPTransport or a bespoke HTTP stack,
+// so they can be used without the dependencies of go-elasticsearch:
+//
+//	transport, err := core.NewHTTPTransport("http://127.0.0.1:9200", nil)
+//	...
+//	batcher := core.NewBatcher(es8.New(transport), func() string { return "mylog" }, time.Second, core.DefaultBulkRetries, nil)
+//	data, err := core.Encode(&core.Message{Timestamp: time.Now().UTC().Format(time.RFC3339Nano), Message: "hello"})
+//	...
+//	err = batcher.Add(data)
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Transport performs HTTP requests against a cluster. It is implemented by the
+// clients of all major versions of go-elasticsearch and by opensearch-go, which
+// take care of node selection, authentication and retries.
+type Transport interface {
+	Perform(*http.Request) (*http.Response, error)
+}
+
+// BulkExecutor sends NDJSON bulk data to a cluster, index is the default index
+// of the actions. It is all a Batcher needs from a client.
+type BulkExecutor interface {
+	Bulk(ctx context.Context, index string, body []byte) (*BulkResponse, error)
+}
+
+// Client is the set of cluster operations used by the hook.
+type Client interface {
+	BulkExecutor
+
+	// IndexExists reports whether an index (or an alias) exists.
+	IndexExists(ctx context.Context, index string) (bool, error)
+	// CreateIndex creates an index, body holds its settings, mappings and aliases.
+	CreateIndex(ctx context.Context, index string, body []byte) error
+	// Index indexes a single JSON document.
+	Index(ctx context.Context, index string, doc []byte) (*IndexResponse, error)
+	// IndexAs indexes a single document of a content type supported by the
+	// cluster, e.g. application/cbor.
+	IndexAs(ctx context.Context, index string, doc []byte, contentType string) (*IndexResponse, error)
+	// IndexWithMeta indexes a single document like IndexAs, with the given metadata.
+	IndexWithMeta(ctx context.Context, index string, doc []byte, contentType string, meta DocumentMeta) (*IndexResponse, error)
+	// Search runs a search request (query DSL) against an index.
+	Search(ctx context.Context, index string, body []byte) (*SearchResponse, error)
+	// ListIndices returns the names of the indices matching a pattern, e.g. "logs-*".
+	ListIndices(ctx context.Context, pattern string) ([]string, error)
+	// DeleteIndex deletes an index.
+	DeleteIndex(ctx context.Context, index string) error
+	// CloseIndex closes an index, it keeps its data but cannot be searched or written.
+	CloseIndex(ctx context.Context, index string) error
+	// Rollover rolls an alias or a data stream over to a new index if one of the
+	// conditions is met, or unconditionally if none is set.
+	Rollover(ctx context.Context, alias string, conditions RolloverConditions) (*RolloverResponse, error)
+	// GetMapping returns the mappings of an index.
+	GetMapping(ctx context.Context, index string) (json.RawMessage, error)
+	// PutMapping updates the mappings of an index.
+	PutMapping(ctx context.Context, index string, body []byte) error
+	// AliasExists reports whether an alias exists.
+	AliasExists(ctx context.Context, alias string) (bool, error)
+	// PutLifecyclePolicy creates or updates an index lifecycle policy.
+	PutLifecyclePolicy(ctx context.Context, name string, policy LifecyclePolicy) error
+	// PutIndexTemplate creates or updates an index template.
+	PutIndexTemplate(ctx context.Context, name string, template IndexTemplate) error
+}
+
+// DocumentMeta holds the metadata of a document sent to a cluster.
+type DocumentMeta struct {
+	// ID is the _id of the document, generated by the cluster if empty. A
+	// document replaces the document with the same _id.
+	ID string `json:"_id,omitempty"`
+	// Routing selects the shard of the document, the _id is used if empty.
+	Routing string `json:"routing,omitempty"`
+	// Create makes the request fail if a document with the same _id exists,
+	// data streams only accept such requests.
+	Create bool `json:"-"`
+}
+
+// BulkResponse is the response of a bulk request.
+type BulkResponse struct {
+	Took   int                           `json:"took"`
+	Errors bool                          `json:"errors"`
+	Items  []map[string]BulkResponseItem `json:"items"`
+}
+
+// BulkResponseItem is the result of a single bulk action.
+type BulkResponseItem struct {
+	Index  string      `json:"_index"`
+	ID     string      `json:"_id"`
+	Status int         `json:"status"`
+	Error  *ErrorCause `json:"error,omitempty"`
+}
+
+// IndexResponse is the response of an index request, e.g. a proof of write.
+type IndexResponse struct {
+	Index       string `json:"_index"`
+	ID          string `json:"_id"`
+	Version     int64  `json:"_version"`
+	Result      string `json:"result"`
+	SeqNo       int64  `json:"_seq_no"`
+	PrimaryTerm int64  `json:"_primary_term"`
+}
+
+// SearchResponse is the response of a search request.
+type SearchResponse struct {
+	Took int `json:"took"`
+	Hits struct {
+		Hits []SearchHit `json:"hits"`
+	} `json:"hits"`
+}
+
+// SearchHit is a document found by a search request.
+type SearchHit struct {
+	Index  string          `json:"_index"`
+	ID     string          `json:"_id"`
+	Source json.RawMessage `json:"_source"`
+}
+
+// RolloverResponse is the response of a rollover request.
+type RolloverResponse struct {
+	OldIndex   string          `json:"old_index"`
+	NewIndex   string          `json:"new_index"`
+	RolledOver bool            `json:"rolled_over"`
+	Conditions map[string]bool `json:"conditions"`
+}
+
+// ErrorCause describes an error reported by the cluster.
+type ErrorCause struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// RolloverConditions describes when a write index is rolled over.
+// Empty values are ignored.
+type RolloverConditions struct {
+	MaxAge              string `json:"max_age,omitempty"`                // e.g. "30d"
+	MaxPrimaryShardSize string `json:"max_primary_shard_size,omitempty"` // e.g. "50gb"
+	MaxDocs             int64  `json:"max_docs,omitempty"`
+}
+
+// LifecyclePolicy is a version-independent description of an index lifecycle
+// policy, translated to ILM or ISM by the client.
+type LifecyclePolicy struct {
+	// IndexPatterns the policy applies to, needed by ISM only.
+	IndexPatterns []string
+	// Rollover conditions of the hot phase.
+	Rollover RolloverConditions
+	// DeleteAfter is the minimum age of an index before it is deleted,
+	// empty means never.
+	DeleteAfter string
+}
+
+// IndexTemplate is a version-independent description of an index template.
+type IndexTemplate struct {
+	IndexPatterns []string
+	Settings      map[string]interface{}
+	Mappings      map[string]interface{}
+	// LifecyclePolicy and RolloverAlias attach a lifecycle policy to the
+	// matching indices, if set.
+	LifecyclePolicy string
+	RolloverAlias   string
+}
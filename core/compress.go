@@ -0,0 +1,81 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// minCompressSize is the size of the smallest request body worth compressing.
+const minCompressSize = 1024
+
+// Compression states of a RESTClient.
+const (
+	compressionUnknown int32 = iota
+	compressionSupported
+	compressionUnsupported
+)
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+func gzipBody(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(data) / 4)
+	w := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress unpacks a compressed response body and notes that the cluster
+// supports compression.
+func (c *RESTClient) decompress(res *http.Response) *http.Response {
+	if res.Uncompressed {
+		// Already done by the http.Transport.
+		atomic.CompareAndSwapInt32(&c.compression, compressionUnknown, compressionSupported)
+		return res
+	}
+	if res.Header.Get("Content-Encoding") != "gzip" {
+		return res
+	}
+	atomic.CompareAndSwapInt32(&c.compression, compressionUnknown, compressionSupported)
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Body = &gzipReadCloser{body: res.Body}
+	return res
+}
+
+// gzipReadCloser decompresses a response body. The gzip.Reader is created on
+// the first read, as responses without a body (e.g. to HEAD requests) do not
+// even have a gzip header.
+type gzipReadCloser struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+}
+
+func (r *gzipReadCloser) Read(p []byte) (int, error) {
+	if r.zr == nil {
+		zr, err := gzip.NewReader(r.body)
+		if err != nil {
+			return 0, err
+		}
+		r.zr = zr
+	}
+	return r.zr.Read(p)
+}
+
+func (r *gzipReadCloser) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,21 @@
+package core
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestNoClientDependency makes sure the package can be used without the
+// dependencies of the official clients.
+func TestNoClientDependency(t *testing.T) {
+	out, err := exec.Command("go", "list", "-deps", ".").Output()
+	if err != nil {
+		t.Skipf("Cannot list the dependencies: %s", err)
+	}
+	for _, dep := range strings.Fields(string(out)) {
+		if strings.Contains(dep, "go-elasticsearch") || strings.Contains(dep, "opensearch-go") {
+			t.Errorf("Unexpected dependency: %s", dep)
+		}
+	}
+}
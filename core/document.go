@@ -0,0 +1,105 @@
+package core
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Message is the document created for a log entry by default.
+type Message struct {
+	Host          string        `json:"host,omitempty"`
+	Timestamp     string        `json:"@timestamp"`
+	File          string        `json:"file,omitempty"`
+	Func          string        `json:"func,omitempty"`
+	Message       string        `json:"message,omitempty"`
+	Data          logrus.Fields `json:"data,omitempty"`
+	Level         string        `json:"level,omitempty"`
+	Trace         *TraceID      `json:"trace,omitempty"`
+	Span          *TraceID      `json:"span,omitempty"`
+	SchemaVersion int           `json:"schema_version"`
+}
+
+// Document is a log document that is not produced by a logrus logger, e.g. an
+// audit event. The Submit method of the hook sends it through the same pipeline
+// as the logged entries.
+//
+//	doc := core.NewDocument().
+//		SetMessage("user deleted").
+//		SetLevel(logrus.WarnLevel).
+//		AddField("user", "joe")
+//	err := hook.Submit(doc)
+type Document struct {
+	message   string
+	level     logrus.Level
+	timestamp time.Time
+	fields    logrus.Fields
+}
+
+// NewDocument creates a new document with the info level and the current time.
+func NewDocument() *Document {
+	return &Document{
+		level:     logrus.InfoLevel,
+		timestamp: time.Now(),
+		fields:    logrus.Fields{},
+	}
+}
+
+// SetMessage sets the message of the document.
+func (d *Document) SetMessage(message string) *Document {
+	d.message = message
+	return d
+}
+
+// SetLevel sets the level of the document.
+func (d *Document) SetLevel(level logrus.Level) *Document {
+	d.level = level
+	return d
+}
+
+// SetTimestamp sets the time of the document.
+func (d *Document) SetTimestamp(t time.Time) *Document {
+	d.timestamp = t
+	return d
+}
+
+// AddField adds a field to the data of the document.
+func (d *Document) AddField(key string, value interface{}) *Document {
+	d.fields[key] = value
+	return d
+}
+
+// Message returns the message of the document.
+func (d *Document) Message() string {
+	return d.message
+}
+
+// Level returns the level of the document.
+func (d *Document) Level() logrus.Level {
+	return d.level
+}
+
+// Timestamp returns the time of the document.
+func (d *Document) Timestamp() time.Time {
+	return d.timestamp
+}
+
+// Fields returns the data fields of the document.
+func (d *Document) Fields() logrus.Fields {
+	return d.fields
+}
+
+// Entry converts the document into a logrus entry. The fields are copied,
+// so the document can be reused once the entry is processed.
+func (d *Document) Entry() *logrus.Entry {
+	data := make(logrus.Fields, len(d.fields))
+	for k, v := range d.fields {
+		data[k] = v
+	}
+	return &logrus.Entry{
+		Data:    data,
+		Time:    d.timestamp,
+		Level:   d.level,
+		Message: d.message,
+	}
+}
@@ -0,0 +1,57 @@
+package core
+
+// ECSVersion is the version of the Elastic Common Schema the ECS documents follow.
+const ECSVersion = "8.6.0"
+
+// ECSMessage is the document created for a log entry in the Elastic Common
+// Schema format, see https://www.elastic.co/guide/en/ecs/current/index.html.
+type ECSMessage struct {
+	Timestamp string            `json:"@timestamp"`
+	Message   string            `json:"message,omitempty"`
+	Log       ECSLog            `json:"log"`
+	Host      *ECSHost          `json:"host,omitempty"`
+	Error     *ECSError         `json:"error,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Trace     *TraceID          `json:"trace,omitempty"`
+	Span      *TraceID          `json:"span,omitempty"`
+	ECS       ECSInfo           `json:"ecs"`
+}
+
+// ECSLog holds the log.* fields of an ECSMessage.
+type ECSLog struct {
+	Level  string     `json:"level,omitempty"`
+	Origin *ECSOrigin `json:"origin,omitempty"`
+}
+
+// ECSOrigin holds the log.origin.* fields of an ECSMessage.
+type ECSOrigin struct {
+	File     ECSFile `json:"file"`
+	Function string  `json:"function,omitempty"`
+}
+
+// ECSFile holds the log.origin.file.* fields of an ECSMessage.
+type ECSFile struct {
+	Name string `json:"name,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+// ECSHost holds the host.* fields of an ECSMessage.
+type ECSHost struct {
+	Name string `json:"name,omitempty"`
+}
+
+// ECSError holds the error.* fields of an ECSMessage.
+type ECSError struct {
+	Message string `json:"message,omitempty"`
+}
+
+// TraceID holds the trace.id or span.id field of a document, the ID of the
+// trace or the span the entry was logged in.
+type TraceID struct {
+	ID string `json:"id"`
+}
+
+// ECSInfo holds the ecs.* fields of an ECSMessage.
+type ECSInfo struct {
+	Version string `json:"version"`
+}
@@ -0,0 +1,260 @@
+package core
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxEncodeDepth mirrors the default index.mapping.depth.limit of Elasticsearch,
+// deeper objects would be rejected by the cluster anyway.
+const maxEncodeDepth = 20
+
+// EncodeError is returned by Encode when a document could not be encoded as is
+// and some of its values were replaced with placeholders.
+// The encoded document is still usable.
+type EncodeError struct {
+	// Err is the error (or recovered panic) of the plain encoding attempt.
+	Err error
+	// Replaced lists the paths of replaced values, e.g. "data.ratio".
+	Replaced []string
+}
+
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("document encoded with placeholders for %s: %s", strings.Join(e.Replaced, ", "), e.Err)
+}
+
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+// Encode serializes v as JSON. It never fails: if v cannot be marshaled
+// (NaN/Inf floats, cycles, channels, funcs, panicking marshalers, ...) the offending
+// values are substituted with placeholder strings and an *EncodeError describing
+// the substitutions is returned along with the data.
+func Encode(v interface{}) ([]byte, error) {
+	data, err := safeMarshal(v)
+	if err == nil {
+		return data, nil
+	}
+
+	s := &sanitizer{seen: make(map[uintptr]bool)}
+	data, err2 := safeMarshal(s.sanitize(reflect.ValueOf(v), "", 0))
+	if err2 != nil {
+		// should never happen, sanitized values are always encodable
+		data = []byte(fmt.Sprintf(`{"message":%q}`, "[unencodable document]"))
+		s.replaced = append(s.replaced, "")
+	}
+	sort.Strings(s.replaced)
+	return data, &EncodeError{Err: err, Replaced: s.replaced}
+}
+
+// safeMarshal is json.Marshal that converts panics of custom marshalers into errors.
+func safeMarshal(v interface{}) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			data, err = nil, fmt.Errorf("panic while encoding: %v", r)
+		}
+	}()
+	return json.Marshal(v)
+}
+
+type sanitizer struct {
+	seen     map[uintptr]bool // pointers, maps and slices on the current path
+	replaced []string
+}
+
+func (s *sanitizer) placeholder(path, text string) interface{} {
+	s.replaced = append(s.replaced, path)
+	return "[" + text + "]"
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (s *sanitizer) sanitize(v reflect.Value, path string, depth int) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > maxEncodeDepth {
+		return s.placeholder(path, "max depth exceeded")
+	}
+
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+	if v.CanInterface() && (v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType)) {
+		if data, err := safeMarshal(v.Interface()); err == nil {
+			return json.RawMessage(data)
+		}
+		return s.placeholder(path, "unencodable "+v.Type().String())
+	}
+
+	// values reached through unexported embedded structs cannot be converted
+	// with Interface(), so the basic kinds are read with the typed getters
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return s.placeholder(path, strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		if v.Kind() == reflect.Float32 {
+			return float32(f)
+		}
+		return f
+	case reflect.Interface:
+		return s.sanitize(v.Elem(), path, depth)
+	case reflect.Ptr:
+		if s.enter(v.Pointer()) {
+			return s.placeholder(path, "circular reference")
+		}
+		defer s.leave(v.Pointer())
+		return s.sanitize(v.Elem(), path, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		if s.enter(v.Pointer()) {
+			return s.placeholder(path, "circular reference")
+		}
+		defer s.leave(v.Pointer())
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := mapKey(iter.Key())
+			m[key] = s.sanitize(iter.Value(), joinPath(path, key), depth+1)
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes() // encoded as base64
+		}
+		if s.enter(v.Pointer()) {
+			return s.placeholder(path, "circular reference")
+		}
+		defer s.leave(v.Pointer())
+		return s.sanitizeList(v, path, depth)
+	case reflect.Array:
+		return s.sanitizeList(v, path, depth)
+	case reflect.Struct:
+		m := make(map[string]interface{}, v.NumField())
+		s.sanitizeStruct(v, path, depth, m)
+		return m
+	default: // chan, func, complex, unsafe pointer
+		return s.placeholder(path, "unsupported "+v.Type().String())
+	}
+}
+
+func (s *sanitizer) sanitizeList(v reflect.Value, path string, depth int) interface{} {
+	l := make([]interface{}, v.Len())
+	for i := range l {
+		l[i] = s.sanitize(v.Index(i), path, depth)
+	}
+	return l
+}
+
+// sanitizeStruct follows the encoding/json rules for field names, "-", omitempty
+// and embedded structs closely enough to produce the same document shape.
+func (s *sanitizer) sanitizeStruct(v reflect.Value, path string, depth int, m map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+		if field.Anonymous && name == "" {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				s.sanitizeStruct(fv, path, depth, m)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(opts, "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		m[name] = s.sanitize(fv, joinPath(path, name), depth+1)
+	}
+}
+
+func (s *sanitizer) enter(ptr uintptr) bool {
+	if s.seen[ptr] {
+		return true
+	}
+	s.seen[ptr] = true
+	return false
+}
+
+func (s *sanitizer) leave(ptr uintptr) {
+	delete(s.seen, ptr)
+}
+
+func mapKey(k reflect.Value) string {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+	if !k.CanInterface() {
+		return "[" + k.Type().String() + "]"
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if text, err := tm.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		return false
+	}
+	return v.IsZero()
+}
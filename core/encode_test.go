@@ -0,0 +1,94 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type panickingMarshaler struct{}
+
+func (panickingMarshaler) MarshalJSON() ([]byte, error) {
+	panic("boom")
+}
+
+type node struct {
+	Name string `json:"name"`
+	Next *node  `json:"next,omitempty"`
+}
+
+type embedded struct {
+	Ratio float64 `json:"ratio"`
+}
+
+type outer struct {
+	embedded
+	Skip  string `json:"-"`
+	Empty string `json:"empty,omitempty"`
+	When  time.Time
+}
+
+func TestEncodeDocument(t *testing.T) {
+	cyclic := &node{Name: "a"}
+	cyclic.Next = &node{Name: "b", Next: cyclic}
+
+	selfMap := map[string]interface{}{}
+	selfMap["self"] = selfMap
+
+	deep := map[string]interface{}{}
+	cur := deep
+	for i := 0; i < 30; i++ {
+		next := map[string]interface{}{}
+		cur["nested"] = next
+		cur = next
+	}
+
+	tests := []struct {
+		name     string
+		data     logrus.Fields
+		expected string
+		replaced []string
+	}{
+		{"plain", logrus.Fields{"a": 1}, `{"data":{"a":1}}`, nil},
+		{"nan", logrus.Fields{"a": math.NaN(), "b": math.Inf(-1)}, `{"data":{"a":"[NaN]","b":"[-Inf]"}}`, []string{"data.a", "data.b"}},
+		{"cycle", logrus.Fields{"n": cyclic}, `{"data":{"n":{"name":"a","next":{"name":"b","next":"[circular reference]"}}}}`, []string{"data.n.next.next"}},
+		{"self map", logrus.Fields{"m": selfMap}, `{"data":{"m":{"self":"[circular reference]"}}}`, []string{"data.m.self"}},
+		{"chan", logrus.Fields{"c": make(chan int), "ok": "yes"}, `{"data":{"c":"[unsupported chan int]","ok":"yes"}}`, []string{"data.c"}},
+		{"panic", logrus.Fields{"p": panickingMarshaler{}}, `{"data":{"p":"[unencodable core.panickingMarshaler]"}}`, []string{"data.p"}},
+		{"struct", logrus.Fields{"o": outer{embedded: embedded{math.Inf(1)}, Skip: "x"}, "f": func() {}},
+			`{"data":{"f":"[unsupported func()]","o":{"When":"0001-01-01T00:00:00Z","ratio":"[+Inf]"}}}`, []string{"data.f", "data.o.ratio"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Encode(struct {
+				Data logrus.Fields `json:"data"`
+			}{tt.data})
+			if string(data) != tt.expected {
+				t.Errorf("Unexpected document: %s", data)
+			}
+			if tt.replaced == nil {
+				if err != nil {
+					t.Errorf("Unexpected error: %s", err)
+				}
+				return
+			}
+			var e *EncodeError
+			if !errors.As(err, &e) {
+				t.Fatalf("Expected an EncodeError, got %v", err)
+			}
+			if strings.Join(e.Replaced, ",") != strings.Join(tt.replaced, ",") {
+				t.Errorf("Unexpected replaced paths: %v", e.Replaced)
+			}
+		})
+	}
+
+	data, err := Encode(map[string]interface{}{"deep": deep, "bad": math.NaN()})
+	if err == nil || !json.Valid(data) || !strings.Contains(string(data), "[max depth exceeded]") {
+		t.Errorf("Unexpected deep document: %s (%v)", data, err)
+	}
+}
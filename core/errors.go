@@ -0,0 +1,62 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ResponseError is an error response returned by the cluster.
+type ResponseError struct {
+	StatusCode int
+	Type       string
+	Reason     string
+}
+
+func (e *ResponseError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("error: [%d] %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("error: [%d] %s: %s", e.StatusCode, e.Type, e.Reason)
+}
+
+// NewResponseError reads an error response body into a *ResponseError.
+func NewResponseError(statusCode int, body io.Reader) *ResponseError {
+	var e struct {
+		Error json.RawMessage `json:"error"`
+	}
+	_ = json.NewDecoder(body).Decode(&e)
+
+	var cause ErrorCause
+	if err := json.Unmarshal(e.Error, &cause); err != nil {
+		// very old versions return the reason as a plain string
+		_ = json.Unmarshal(e.Error, &cause.Reason)
+	}
+	return &ResponseError{
+		StatusCode: statusCode,
+		Type:       cause.Type,
+		Reason:     cause.Reason,
+	}
+}
+
+// IsIndexNotFound reports whether err is the error returned by the cluster for
+// requests to a missing index.
+func IsIndexNotFound(err error) bool {
+	var e *ResponseError
+	return errors.As(err, &e) && e.Type == indexNotFound
+}
+
+const indexNotFound = "index_not_found_exception"
+
+// IsRetryable reports whether a request failing with err may succeed if resent:
+// connection problems, throttling and server errors are retried, other
+// responses of the cluster are not.
+func IsRetryable(err error) bool {
+	var e *ResponseError
+	if !errors.As(err, &e) {
+		return true
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+// FailoverClient is a Client sending the requests to a primary cluster and,
+// while it is unavailable, to a secondary one, e.g. in another region. A request
+// failing on the primary cluster with a transient error (connection problems,
+// throttling and server errors) is resent to the secondary cluster, which then
+// receives the following requests. Every retryInterval, a request is sent to the
+// primary cluster first, the client fails back once one succeeds.
+//
+// A request timing out on the primary cluster has no time left for the
+// secondary one, it fails but the following requests are sent to the secondary
+// cluster. A bulk request that failed on the primary cluster may have been
+// partially applied before it is resent, see the _id of the documents to avoid
+// duplicates. The indices and templates of the secondary cluster are not managed
+// by the primary one: they must exist there or be created automatically.
+type FailoverClient struct {
+	primary       Client
+	secondary     Client
+	retryInterval time.Duration
+	onChange      func(primary bool)
+
+	mu        sync.Mutex
+	onPrimary bool
+	// retryAt is when the primary cluster is tried again
+	retryAt time.Time
+}
+
+// NewFailoverClient creates a new FailoverClient, starting with the primary
+// cluster. onChange, if not nil, is called when the client fails over to the
+// secondary cluster (with false) or back to the primary one (with true).
+func NewFailoverClient(primary, secondary Client, retryInterval time.Duration, onChange func(primary bool)) *FailoverClient {
+	return &FailoverClient{
+		primary:       primary,
+		secondary:     secondary,
+		retryInterval: retryInterval,
+		onChange:      onChange,
+		onPrimary:     true,
+	}
+}
+
+// OnPrimary reports whether the requests are sent to the primary cluster.
+func (f *FailoverClient) OnPrimary() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.onPrimary
+}
+
+// tryPrimary reports whether the next request goes to the primary cluster: it
+// is active, or it is time to try it again.
+func (f *FailoverClient) tryPrimary() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.onPrimary {
+		return true
+	}
+	now := time.Now()
+	if now.Before(f.retryAt) {
+		return false
+	}
+	// a single request at a time tries the primary cluster
+	f.retryAt = now.Add(f.retryInterval)
+	return true
+}
+
+// setPrimary records whether the primary cluster is available.
+func (f *FailoverClient) setPrimary(available bool) {
+	f.mu.Lock()
+	changed := f.onPrimary != available
+	f.onPrimary = available
+	if !available {
+		f.retryAt = time.Now().Add(f.retryInterval)
+	}
+	f.mu.Unlock()
+	if changed && f.onChange != nil {
+		f.onChange(available)
+	}
+}
+
+// failover runs op against the primary cluster if it is tried, and against
+// the secondary cluster if it is not or fails with a transient error.
+func failover[T any](f *FailoverClient, op func(c Client) (T, error)) (T, error) {
+	if f.tryPrimary() {
+		res, err := op(f.primary)
+		switch {
+		case err == nil || !IsRetryable(err):
+			// the primary cluster responded
+			f.setPrimary(true)
+			return res, err
+		case errors.Is(err, context.Canceled):
+			return res, err
+		}
+		f.setPrimary(false)
+	}
+	return op(f.secondary)
+}
+
+// failoverErr is failover for the operations returning an error only.
+func failoverErr(f *FailoverClient, op func(c Client) error) error {
+	_, err := failover(f, func(c Client) (struct{}, error) {
+		return struct{}{}, op(c)
+	})
+	return err
+}
+
+// Bulk implements Client.
+func (f *FailoverClient) Bulk(ctx context.Context, index string, body []byte) (*BulkResponse, error) {
+	return failover(f, func(c Client) (*BulkResponse, error) {
+		return c.Bulk(ctx, index, body)
+	})
+}
+
+// IndexExists implements Client.
+func (f *FailoverClient) IndexExists(ctx context.Context, index string) (bool, error) {
+	return failover(f, func(c Client) (bool, error) {
+		return c.IndexExists(ctx, index)
+	})
+}
+
+// CreateIndex implements Client.
+func (f *FailoverClient) CreateIndex(ctx context.Context, index string, body []byte) error {
+	return failoverErr(f, func(c Client) error {
+		return c.CreateIndex(ctx, index, body)
+	})
+}
+
+// Index implements Client.
+func (f *FailoverClient) Index(ctx context.Context, index string, doc []byte) (*IndexResponse, error) {
+	return failover(f, func(c Client) (*IndexResponse, error) {
+		return c.Index(ctx, index, doc)
+	})
+}
+
+// IndexAs implements Client.
+func (f *FailoverClient) IndexAs(ctx context.Context, index string, doc []byte, contentType string) (*IndexResponse, error) {
+	return failover(f, func(c Client) (*IndexResponse, error) {
+		return c.IndexAs(ctx, index, doc, contentType)
+	})
+}
+
+// IndexWithMeta implements Client.
+func (f *FailoverClient) IndexWithMeta(ctx context.Context, index string, doc []byte, contentType string, meta DocumentMeta) (*IndexResponse, error) {
+	return failover(f, func(c Client) (*IndexResponse, error) {
+		return c.IndexWithMeta(ctx, index, doc, contentType, meta)
+	})
+}
+
+// Search implements Client.
+func (f *FailoverClient) Search(ctx context.Context, index string, body []byte) (*SearchResponse, error) {
+	return failover(f, func(c Client) (*SearchResponse, error) {
+		return c.Search(ctx, index, body)
+	})
+}
+
+// ListIndices implements Client.
+func (f *FailoverClient) ListIndices(ctx context.Context, pattern string) ([]string, error) {
+	return failover(f, func(c Client) ([]string, error) {
+		return c.ListIndices(ctx, pattern)
+	})
+}
+
+// DeleteIndex implements Client.
+func (f *FailoverClient) DeleteIndex(ctx context.Context, index string) error {
+	return failoverErr(f, func(c Client) error {
+		return c.DeleteIndex(ctx, index)
+	})
+}
+
+// CloseIndex implements Client.
+func (f *FailoverClient) CloseIndex(ctx context.Context, index string) error {
+	return failoverErr(f, func(c Client) error {
+		return c.CloseIndex(ctx, index)
+	})
+}
+
+// Rollover implements Client.
+func (f *FailoverClient) Rollover(ctx context.Context, alias string, conditions RolloverConditions) (*RolloverResponse, error) {
+	return failover(f, func(c Client) (*RolloverResponse, error) {
+		return c.Rollover(ctx, alias, conditions)
+	})
+}
+
+// GetMapping implements Client.
+func (f *FailoverClient) GetMapping(ctx context.Context, index string) (json.RawMessage, error) {
+	return failover(f, func(c Client) (json.RawMessage, error) {
+		return c.GetMapping(ctx, index)
+	})
+}
+
+// PutMapping implements Client.
+func (f *FailoverClient) PutMapping(ctx context.Context, index string, body []byte) error {
+	return failoverErr(f, func(c Client) error {
+		return c.PutMapping(ctx, index, body)
+	})
+}
+
+// AliasExists implements Client.
+func (f *FailoverClient) AliasExists(ctx context.Context, alias string) (bool, error) {
+	return failover(f, func(c Client) (bool, error) {
+		return c.AliasExists(ctx, alias)
+	})
+}
+
+// PutLifecyclePolicy implements Client.
+func (f *FailoverClient) PutLifecyclePolicy(ctx context.Context, name string, policy LifecyclePolicy) error {
+	return failoverErr(f, func(c Client) error {
+		return c.PutLifecyclePolicy(ctx, name, policy)
+	})
+}
+
+// PutIndexTemplate implements Client.
+func (f *FailoverClient) PutIndexTemplate(ctx context.Context, name string, template IndexTemplate) error {
+	return failoverErr(f, func(c Client) error {
+		return c.PutIndexTemplate(ctx, name, template)
+	})
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// InFlight counts the documents accepted but not delivered yet, so that their
+// delivery can be awaited. Unlike a sync.WaitGroup, it can be waited for with a
+// context and reused while documents are added. The zero value is ready to use.
+type InFlight struct {
+	mu sync.Mutex
+	n  int
+	// idle is closed once n drops to zero, nil while n is zero
+	idle chan struct{}
+}
+
+// Add counts n more documents.
+func (f *InFlight) Add(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.n == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.n += n
+}
+
+// Done marks n documents as delivered or given up on.
+func (f *InFlight) Done(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.n == 0 {
+		return
+	}
+	if f.n -= n; f.n <= 0 {
+		f.n = 0
+		close(f.idle)
+		f.idle = nil
+	}
+}
+
+// Len returns the number of documents in flight.
+func (f *InFlight) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.n
+}
+
+// Wait waits until no document is in flight or the context is done.
+func (f *InFlight) Wait(ctx context.Context) error {
+	f.mu.Lock()
+	idle := f.idle
+	f.mu.Unlock()
+	if idle == nil {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
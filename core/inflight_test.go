@@ -0,0 +1,42 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInFlight(t *testing.T) {
+	var f InFlight
+	if err := f.Wait(context.Background()); err != nil {
+		t.Fatalf("Expected no wait without documents, got %v", err)
+	}
+
+	f.Add(2)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := f.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- f.Wait(context.Background()) }()
+	f.Done(1)
+	select {
+	case err := <-done:
+		t.Fatalf("Expected Wait to block while a document is in flight, got %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	f.Done(1)
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	// reusable once idle
+	f.Add(1)
+	f.Done(1)
+	if err := f.Wait(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
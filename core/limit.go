@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrQueueFull is returned by a non-blocking Limiter when no more entries can be queued.
+var ErrQueueFull = errors.New("queue is full")
+
+// Limiter bounds the number of entries that are queued but not delivered yet.
+// When the limit is reached, a blocking Limiter makes Acquire wait for a Release
+// (applying backpressure to the caller), a non-blocking one fails with ErrQueueFull
+// and counts the entry as dropped.
+type Limiter struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	size    int
+	block   bool
+	pending int
+	dropped uint64
+}
+
+// NewLimiter creates a new Limiter for size entries.
+func NewLimiter(size int, block bool) *Limiter {
+	l := &Limiter{size: size, block: block}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// Acquire reserves room for an entry.
+func (l *Limiter) Acquire() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.pending >= l.size {
+		if !l.block {
+			l.dropped++
+			return ErrQueueFull
+		}
+		l.cond.Wait()
+	}
+	l.pending++
+	return nil
+}
+
+// Release frees the room of n entries, delivered or not.
+func (l *Limiter) Release(n int) {
+	l.mu.Lock()
+	l.pending -= n
+	if l.pending < 0 {
+		l.pending = 0
+	}
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// Pending returns the number of queued entries.
+func (l *Limiter) Pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pending
+}
+
+// Dropped returns the number of entries rejected with ErrQueueFull.
+func (l *Limiter) Dropped() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.dropped
+}
@@ -0,0 +1,52 @@
+package core
+
+import "time"
+
+// Metrics receives the measurements of a delivery pipeline, e.g. to export
+// them to a monitoring system. The methods are called concurrently from the
+// delivery goroutines, so they must be safe for concurrent use and not block.
+type Metrics interface {
+	// EntryFired is called for every entry accepted for delivery.
+	EntryFired()
+	// DocumentsSent is called with the number of documents written.
+	DocumentsSent(docs int)
+	// RequestSent is called for every request sending documents, retries
+	// included, with the number of documents and the size of its body and the
+	// time it took, whatever its outcome.
+	RequestSent(docs, bytes int, took time.Duration)
+	// DocumentsFailed is called with the number of documents that could not
+	// be written, once they are given up on.
+	DocumentsFailed(docs int)
+	// DocumentsDropped is called with the number of documents dropped before
+	// they were sent, e.g. because a queue was full.
+	DocumentsDropped(docs int)
+	// RequestRetried is called for every request sent again after a transient error.
+	RequestRetried()
+	// QueueDepth is called with the number of documents waiting for delivery
+	// whenever it changes.
+	QueueDepth(docs int)
+}
+
+// NopMetrics discards all the measurements, it is the default Metrics.
+type NopMetrics struct{}
+
+// EntryFired implements Metrics.
+func (NopMetrics) EntryFired() {}
+
+// DocumentsSent implements Metrics.
+func (NopMetrics) DocumentsSent(int) {}
+
+// RequestSent implements Metrics.
+func (NopMetrics) RequestSent(int, int, time.Duration) {}
+
+// DocumentsFailed implements Metrics.
+func (NopMetrics) DocumentsFailed(int) {}
+
+// DocumentsDropped implements Metrics.
+func (NopMetrics) DocumentsDropped(int) {}
+
+// RequestRetried implements Metrics.
+func (NopMetrics) RequestRetried() {}
+
+// QueueDepth implements Metrics.
+func (NopMetrics) QueueDepth(int) {}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by a non-blocking RateLimiter when an entry is
+// above the rate limit.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimiter is a token bucket bounding the rate of the entries: it holds up
+// to burst tokens, refilled at rate tokens per second, and every entry takes
+// one. Without a token, a blocking RateLimiter makes Wait wait for the next
+// one, a non-blocking one fails with ErrRateLimited and counts the entry as
+// dropped.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	block   bool
+	tokens  float64
+	last    time.Time
+	dropped uint64
+}
+
+// NewRateLimiter creates a new RateLimiter of rate entries per second with
+// bursts of burst entries, starting full.
+func NewRateLimiter(rate float64, burst int, block bool) *RateLimiter {
+	return &RateLimiter{rate: rate, burst: float64(burst), block: block, tokens: float64(burst), last: time.Now()}
+}
+
+// Wait takes a token for an entry. A blocking RateLimiter returns the error of
+// ctx if it is done before the token is available.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		l.mu.Unlock()
+		return nil
+	}
+	if !l.block {
+		l.dropped++
+		l.mu.Unlock()
+		return ErrRateLimited
+	}
+	// Take the token in advance, the entries waiting after this one wait longer.
+	l.tokens--
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Dropped returns the number of entries rejected with ErrRateLimited.
+func (l *RateLimiter) Dropped() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.dropped
+}
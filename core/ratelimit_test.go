@@ -0,0 +1,43 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(100, 2, false)
+	for i := 0; i < 2; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Expected the burst to pass, got %s", err)
+		}
+	}
+	if err := l.Wait(context.Background()); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+	if l.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped entry, got %d", l.Dropped())
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Errorf("Expected the bucket to be refilled, got %s", err)
+	}
+
+	l = NewRateLimiter(100, 1, true)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("Expected the entries above the burst to wait, took %s", elapsed)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the wait to be canceled, got %v", err)
+	}
+}
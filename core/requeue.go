@@ -0,0 +1,117 @@
+package core
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+)
+
+// requeueMark starts the line written to the queue in front of a requeued bulk
+// item, followed by the number of times the item was requeued. The action
+// lines start with '{' and the documents do not contain newlines, so the line
+// cannot be mistaken for a bulk line.
+const requeueMark = '#'
+
+// requeuer holds the settings of the documents a Batcher adds back to its
+// queue, see SetRequeue.
+type requeuer struct {
+	max int
+}
+
+// SetRequeue makes the Batcher add the documents rejected with a transient
+// status (429 Too Many Requests or a server error) back to the queue, to be
+// sent with the next batch, up to maxRequeues times per document. They are not
+// counted as failed meanwhile. A nonpositive maxRequeues turns it off.
+// It must be called before the first Add.
+func (b *Batcher) SetRequeue(maxRequeues int) {
+	b.requeue.max = maxRequeues
+}
+
+// requeueItems adds the documents of body rejected with a transient status
+// back to the queue and returns the response and the body without them, with
+// their number and size. attempts holds the number of times the items of body
+// were requeued already, see stripRequeues. The requeued documents keep their
+// room in the limiter and the memory budget.
+func (b *Batcher) requeueItems(res *BulkResponse, body []byte, attempts []int) (*BulkResponse, []byte, int, int) {
+	if b.requeue.max <= 0 || !res.Errors {
+		return res, body, 0, 0
+	}
+	kept := &BulkResponse{Took: res.Took, Errors: res.Errors}
+	var rest []byte
+	docs, size := 0, 0
+	for remaining, i := body, 0; len(remaining) > 0; i++ {
+		var item []byte
+		item, remaining = nextBulkItem(remaining)
+		n := 0
+		if i < len(attempts) {
+			n = attempts[i]
+		}
+		if i < len(res.Items) && transientItem(res.Items[i]) && n < b.requeue.max && b.readd(item, n+1) == nil {
+			docs++
+			size += len(item)
+			continue
+		}
+		rest = append(rest, item...)
+		if i < len(res.Items) {
+			kept.Items = append(kept.Items, res.Items[i])
+		}
+	}
+	if docs == 0 {
+		return res, body, 0, 0
+	}
+	return kept, rest, docs, size
+}
+
+// readd adds a bulk item back to the queue, requeued for the nth time.
+func (b *Batcher) readd(item []byte, n int) error {
+	data := make([]byte, 0, len(item)+8)
+	data = append(strconv.AppendInt(append(data, requeueMark), int64(n), 10), '\n')
+	data = append(data, item...)
+	b.pending.Add(1)
+	if _, err := b.writer.Write(data); err != nil {
+		b.pending.Done(1)
+		return err
+	}
+	return nil
+}
+
+// stripRequeues removes the lines written by readd from a batch, in place, and
+// returns the batch with the number of times each of its items was requeued,
+// nil if none was.
+func stripRequeues(batch []byte) ([]byte, []int) {
+	if len(batch) == 0 || (batch[0] != requeueMark && !bytes.Contains(batch, []byte{'\n', requeueMark})) {
+		return batch, nil
+	}
+	var attempts []int
+	out := batch[:0]
+	for rest, i := batch, 0; len(rest) > 0; i++ {
+		n := 0
+		if rest[0] == requeueMark {
+			end := bytes.IndexByte(rest, '\n')
+			if end < 0 {
+				break
+			}
+			n, _ = strconv.Atoi(string(rest[1:end]))
+			rest = rest[end+1:]
+		}
+		var item []byte
+		item, rest = nextBulkItem(rest)
+		out = append(out, item...)
+		for len(attempts) <= i {
+			attempts = append(attempts, 0)
+		}
+		attempts[i] = n
+	}
+	return out, attempts
+}
+
+// transientItem reports whether an item of a bulk response was rejected with a
+// transient status.
+func transientItem(item map[string]BulkResponseItem) bool {
+	for _, result := range item {
+		if result.Status == http.StatusTooManyRequests || result.Status >= http.StatusInternalServerError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,384 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync/atomic"
+)
+
+// Request is a request to the cluster REST API.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   []byte
+	// ContentType defaults to application/json.
+	ContentType string
+}
+
+// RESTClient implements Client on top of the REST API of Elasticsearch 7.8+ and 8.x.
+// Clients for other versions embed it and override the operations that differ.
+//
+// The client asks for compressed responses. Once the cluster is seen to compress
+// them (i.e. http.compression is enabled), larger request bodies are compressed
+// as well, unless a compressed request is rejected for its encoding (a 415, or a
+// 400 naming Content-Encoding) but its uncompressed retry succeeds, as with
+// proxies that do not accept compressed bodies.
+type RESTClient struct {
+	Transport Transport
+	// MappingQuery holds extra query parameters of the requests that take or return
+	// mappings (create index, get and put mapping).
+	MappingQuery url.Values
+	// DocumentType is the mapping type in the paths of the index requests,
+	// "_doc" if empty.
+	DocumentType string
+	// DisableCompression turns off the compression of requests and responses,
+	// e.g. if the Transport compresses the requests itself.
+	DisableCompression bool
+
+	compression int32
+}
+
+// NewRESTClient creates a new RESTClient.
+func NewRESTClient(t Transport) *RESTClient {
+	return &RESTClient{Transport: t}
+}
+
+// Do performs the request. Responses with a status code of 300 or above are
+// returned as a *ResponseError. If out is not nil, the response body is decoded into it.
+func (c *RESTClient) Do(ctx context.Context, r Request, out interface{}) error {
+	res, err := c.perform(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return NewResponseError(res.StatusCode, res.Body)
+	}
+	if out != nil {
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return fmt.Errorf("failure to parse response body: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// Exists performs a HEAD request and reports whether the resource exists.
+func (c *RESTClient) Exists(ctx context.Context, path string) (bool, error) {
+	res, err := c.perform(ctx, Request{Method: http.MethodHead, Path: path})
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return false, nil
+	case res.StatusCode >= http.StatusMultipleChoices:
+		return false, &ResponseError{StatusCode: res.StatusCode}
+	}
+	return true, nil
+}
+
+func (c *RESTClient) perform(ctx context.Context, r Request) (*http.Response, error) {
+	compress := !c.DisableCompression && len(r.Body) >= minCompressSize &&
+		atomic.LoadInt32(&c.compression) == compressionSupported
+	res, err := c.send(ctx, r, compress)
+	if err != nil || !compress || !rejectsEncoding(res) {
+		return res, err
+	}
+
+	// A proxy between us and the cluster might not accept compressed bodies,
+	// try again without compression.
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	res, err = c.send(ctx, r, false)
+	if err == nil && res.StatusCode < http.StatusMultipleChoices {
+		atomic.StoreInt32(&c.compression, compressionUnsupported)
+	}
+	return res, err
+}
+
+// maxRejectionBody is the size of the body of a 400 response read to find out
+// whether the compressed request was rejected for its encoding.
+const maxRejectionBody = 64 << 10
+
+// rejectsEncoding reports whether a response rejects the encoding of a compressed
+// request: a 415, or a 400 whose body names the Content-Encoding header. The
+// body of other 400 responses is kept for the caller.
+func rejectsEncoding(res *http.Response) bool {
+	switch res.StatusCode {
+	case http.StatusUnsupportedMediaType:
+		return true
+	case http.StatusBadRequest:
+		data, _ := io.ReadAll(io.LimitReader(res.Body, maxRejectionBody))
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), res.Body), res.Body}
+		return bytes.Contains(bytes.ToLower(data), []byte("content-encoding"))
+	}
+	return false
+}
+
+func (c *RESTClient) send(ctx context.Context, r Request, compress bool) (*http.Response, error) {
+	u := &url.URL{Path: r.Path, RawQuery: r.Query.Encode()}
+	var body io.Reader
+	if r.Body != nil {
+		body = bytes.NewReader(r.Body)
+		if compress {
+			compressed, err := gzipBody(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			body = bytes.NewReader(compressed)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if r.Body != nil {
+		contentType := r.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+		if compress {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+	}
+	if !c.DisableCompression {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	res, err := c.Transport.Perform(req)
+	if err != nil {
+		return nil, err
+	}
+	return c.decompress(res), nil
+}
+
+// IndexExists implements Client.
+func (c *RESTClient) IndexExists(ctx context.Context, index string) (bool, error) {
+	return c.Exists(ctx, "/"+url.PathEscape(index))
+}
+
+// CreateIndex implements Client.
+func (c *RESTClient) CreateIndex(ctx context.Context, index string, body []byte) error {
+	return c.Do(ctx, Request{Method: http.MethodPut, Path: "/" + url.PathEscape(index), Query: c.MappingQuery, Body: body}, nil)
+}
+
+// Index implements Client.
+func (c *RESTClient) Index(ctx context.Context, index string, doc []byte) (*IndexResponse, error) {
+	return c.IndexAs(ctx, index, doc, "")
+}
+
+// IndexAs implements Client, an empty content type means JSON.
+func (c *RESTClient) IndexAs(ctx context.Context, index string, doc []byte, contentType string) (*IndexResponse, error) {
+	return c.IndexWithMeta(ctx, index, doc, contentType, DocumentMeta{})
+}
+
+// IndexWithMeta implements Client, an empty content type means JSON.
+func (c *RESTClient) IndexWithMeta(ctx context.Context, index string, doc []byte, contentType string, meta DocumentMeta) (*IndexResponse, error) {
+	docType := c.DocumentType
+	if docType == "" {
+		docType = "_doc"
+	}
+	r := Request{Method: http.MethodPost, Path: "/" + url.PathEscape(index) + "/" + url.PathEscape(docType), Body: doc, ContentType: contentType}
+	if meta.ID != "" {
+		r.Method = http.MethodPut
+		r.Path += "/" + url.PathEscape(meta.ID)
+	}
+	if meta.Routing != "" || meta.Create {
+		r.Query = url.Values{}
+	}
+	if meta.Routing != "" {
+		r.Query.Set("routing", meta.Routing)
+	}
+	if meta.Create {
+		r.Query.Set("op_type", "create")
+	}
+	var res IndexResponse
+	err := c.Do(ctx, r, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Bulk implements Client.
+func (c *RESTClient) Bulk(ctx context.Context, index string, body []byte) (*BulkResponse, error) {
+	path := "/_bulk"
+	if index != "" {
+		path = "/" + url.PathEscape(index) + path
+	}
+	var res BulkResponse
+	err := c.Do(ctx, Request{Method: http.MethodPost, Path: path, Body: body, ContentType: "application/x-ndjson"}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Search implements Client.
+func (c *RESTClient) Search(ctx context.Context, index string, body []byte) (*SearchResponse, error) {
+	var res SearchResponse
+	err := c.Do(ctx, Request{Method: http.MethodPost, Path: "/" + url.PathEscape(index) + "/_search", Body: body}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListIndices implements Client, closed indices are included.
+func (c *RESTClient) ListIndices(ctx context.Context, pattern string) ([]string, error) {
+	var res []struct {
+		Index string `json:"index"`
+	}
+	err := c.Do(ctx, Request{
+		Method: http.MethodGet,
+		Path:   "/_cat/indices/" + pattern,
+		Query:  url.Values{"format": {"json"}, "h": {"index"}, "expand_wildcards": {"all"}},
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(res))
+	for i, index := range res {
+		names[i] = index.Index
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeleteIndex implements Client.
+func (c *RESTClient) DeleteIndex(ctx context.Context, index string) error {
+	return c.Do(ctx, Request{Method: http.MethodDelete, Path: "/" + url.PathEscape(index)}, nil)
+}
+
+// CloseIndex implements Client.
+func (c *RESTClient) CloseIndex(ctx context.Context, index string) error {
+	return c.Do(ctx, Request{Method: http.MethodPost, Path: "/" + url.PathEscape(index) + "/_close"}, nil)
+}
+
+// Rollover implements Client.
+func (c *RESTClient) Rollover(ctx context.Context, alias string, conditions RolloverConditions) (*RolloverResponse, error) {
+	return c.RolloverWith(ctx, alias, conditions)
+}
+
+// RolloverWith performs a rollover request, conditions is the (possibly
+// version-specific) conditions object.
+func (c *RESTClient) RolloverWith(ctx context.Context, alias string, conditions interface{}) (*RolloverResponse, error) {
+	body, err := json.Marshal(map[string]interface{}{"conditions": conditions})
+	if err != nil {
+		return nil, err
+	}
+	var res RolloverResponse
+	err = c.Do(ctx, Request{Method: http.MethodPost, Path: "/" + url.PathEscape(alias) + "/_rollover", Body: body}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetMapping implements Client. If index is an alias pointing to several indices,
+// the mapping of the last one (in lexicographical order) is returned.
+func (c *RESTClient) GetMapping(ctx context.Context, index string) (json.RawMessage, error) {
+	var res map[string]struct {
+		Mappings json.RawMessage `json:"mappings"`
+	}
+	err := c.Do(ctx, Request{Method: http.MethodGet, Path: "/" + url.PathEscape(index) + "/_mapping", Query: c.MappingQuery}, &res)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(res))
+	for name := range res {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sort.Strings(names)
+	return res[names[len(names)-1]].Mappings, nil
+}
+
+// PutMapping implements Client.
+func (c *RESTClient) PutMapping(ctx context.Context, index string, body []byte) error {
+	return c.Do(ctx, Request{Method: http.MethodPut, Path: "/" + url.PathEscape(index) + "/_mapping", Query: c.MappingQuery, Body: body}, nil)
+}
+
+// AliasExists implements Client.
+func (c *RESTClient) AliasExists(ctx context.Context, alias string) (bool, error) {
+	return c.Exists(ctx, "/_alias/"+url.PathEscape(alias))
+}
+
+// PutLifecyclePolicy implements Client, the policy is created as an ILM policy.
+func (c *RESTClient) PutLifecyclePolicy(ctx context.Context, name string, policy LifecyclePolicy) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"policy": map[string]interface{}{"phases": ILMPhases(policy, policy.Rollover)},
+	})
+	if err != nil {
+		return err
+	}
+	return c.Do(ctx, Request{Method: http.MethodPut, Path: "/_ilm/policy/" + url.PathEscape(name), Body: body}, nil)
+}
+
+// ILMPhases returns the ILM phases of the policy, rollover is the
+// (possibly version-specific) rollover action.
+func ILMPhases(policy LifecyclePolicy, rollover interface{}) map[string]interface{} {
+	phases := map[string]interface{}{
+		"hot": map[string]interface{}{
+			"actions": map[string]interface{}{
+				"rollover": rollover,
+			},
+		},
+	}
+	if policy.DeleteAfter != "" {
+		phases["delete"] = map[string]interface{}{
+			"min_age": policy.DeleteAfter,
+			"actions": map[string]interface{}{
+				"delete": map[string]interface{}{},
+			},
+		}
+	}
+	return phases
+}
+
+// PutIndexTemplate implements Client, the template is created as a composable index template.
+func (c *RESTClient) PutIndexTemplate(ctx context.Context, name string, template IndexTemplate) error {
+	settings := TemplateSettings(template, "index.lifecycle.name", "index.lifecycle.rollover_alias")
+	t := map[string]interface{}{"settings": settings}
+	if template.Mappings != nil {
+		t["mappings"] = template.Mappings
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"index_patterns": template.IndexPatterns,
+		"template":       t,
+	})
+	if err != nil {
+		return err
+	}
+	return c.Do(ctx, Request{Method: http.MethodPut, Path: "/_index_template/" + url.PathEscape(name), Body: body}, nil)
+}
+
+// TemplateSettings returns a copy of the template settings with the lifecycle
+// policy and rollover alias stored under the given (version-specific) setting names.
+// An empty setting name leaves the corresponding value out.
+func TemplateSettings(template IndexTemplate, policySetting, aliasSetting string) map[string]interface{} {
+	settings := make(map[string]interface{}, len(template.Settings)+2)
+	for k, v := range template.Settings {
+		settings[k] = v
+	}
+	if template.LifecyclePolicy != "" && policySetting != "" {
+		settings[policySetting] = template.LifecyclePolicy
+	}
+	if template.RolloverAlias != "" && aliasSetting != "" {
+		settings[aliasSetting] = template.RolloverAlias
+	}
+	return settings
+}
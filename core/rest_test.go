@@ -0,0 +1,172 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *RESTClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	transport, err := NewHTTPTransport(server.URL, nil)
+	if err != nil {
+		t.Fatalf("Error creating the transport: %s", err)
+	}
+	return NewRESTClient(transport)
+}
+
+func TestRESTClient_Bulk(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/logs/_bulk" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("Unexpected content type: %s", r.Header.Get("Content-Type"))
+		}
+		_, _ = io.WriteString(w, `{"took":3,"errors":true,"items":[`+
+			`{"index":{"_index":"logs","_id":"1","status":201}},`+
+			`{"index":{"_index":"logs","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}]}`)
+	})
+
+	res, err := c.Bulk(context.Background(), "logs", []byte("{\"index\":{}}\n{}\n{\"index\":{}}\n{}\n"))
+	if err != nil {
+		t.Fatalf("Error sending bulk data: %s", err)
+	}
+	if res.Took != 3 || !res.Errors || len(res.Items) != 2 {
+		t.Fatalf("Unexpected response: %+v", res)
+	}
+	if item := res.Items[1]["index"]; item.Status != 400 || item.Error == nil || item.Error.Type != "mapper_parsing_exception" {
+		t.Errorf("Unexpected item: %+v", item)
+	}
+}
+
+func TestRESTClient_Errors(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `{"error":{"type":"security_exception","reason":"action is unauthorized"},"status":403}`)
+		}
+	})
+
+	exists, err := c.IndexExists(context.Background(), "missing")
+	if err != nil || exists {
+		t.Errorf("Unexpected result: %v, %v", exists, err)
+	}
+
+	err = c.CreateIndex(context.Background(), "forbidden", []byte(`{}`))
+	var e *ResponseError
+	if !errors.As(err, &e) {
+		t.Fatalf("Expected a ResponseError, got %v", err)
+	}
+	if e.StatusCode != http.StatusForbidden || e.Type != "security_exception" || e.Reason != "action is unauthorized" {
+		t.Errorf("Unexpected error: %+v", e)
+	}
+}
+
+func TestRESTClient_GetMapping(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"logs-000002":{"mappings":{"_meta":{"v":2}}},"logs-000001":{"mappings":{"_meta":{"v":1}}}}`)
+	})
+
+	mapping, err := c.GetMapping(context.Background(), "logs")
+	if err != nil {
+		t.Fatalf("Error getting the mapping: %s", err)
+	}
+	if string(mapping) != `{"_meta":{"v":2}}` {
+		t.Errorf("Unexpected mapping: %s", mapping)
+	}
+}
+
+func TestRESTClient_Compression(t *testing.T) {
+	for _, rejection := range []int{0, http.StatusUnsupportedMediaType, http.StatusBadRequest} {
+		rejectCompressed := rejection != 0
+		var encodings []string
+		c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			encodings = append(encodings, r.Header.Get("Content-Encoding"))
+			body := io.Reader(r.Body)
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				if rejectCompressed {
+					w.WriteHeader(rejection)
+					_, _ = io.WriteString(w, "unsupported Content-Encoding: gzip")
+					return
+				}
+				zr, err := gzip.NewReader(r.Body)
+				if err != nil {
+					t.Fatalf("Invalid compressed body: %s", err)
+				}
+				body = zr
+			}
+			if data, _ := io.ReadAll(body); !bytes.HasPrefix(data, []byte(`{"index":{}}`)) {
+				t.Errorf("Unexpected body: %.40q", data)
+			}
+			if r.Header.Get("Accept-Encoding") != "gzip" {
+				t.Errorf("Compressed response not accepted")
+			}
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			_, _ = io.WriteString(zw, `{"took":1,"errors":false,"items":[]}`)
+			_ = zw.Close()
+		})
+
+		body := bytes.Repeat([]byte("{\"index\":{}}\n{\"message\":\"hello\"}\n"), 100)
+		for i := 0; i < 3; i++ {
+			res, err := c.Bulk(context.Background(), "logs", body)
+			if err != nil {
+				t.Fatalf("Error sending bulk data: %s", err)
+			}
+			if res.Took != 1 {
+				t.Errorf("Unexpected response: %+v", res)
+			}
+		}
+
+		expected := []string{"", "gzip", "gzip"}
+		if rejectCompressed {
+			expected = []string{"", "gzip", "", ""}
+		}
+		if strings.Join(encodings, ",") != strings.Join(expected, ",") {
+			t.Errorf("Unexpected request encodings (rejecting with %d): %q", rejection, encodings)
+		}
+	}
+}
+
+func TestRESTClient_CompressedBadRequest(t *testing.T) {
+	var encodings []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		if r.URL.Path == "/logs/_bulk" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":{"type":"illegal_argument_exception","reason":"bad bulk"},"status":400}`)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		_, _ = io.WriteString(zw, `{"took":1,"errors":false,"items":[]}`)
+		_ = zw.Close()
+	})
+
+	// A 400 that is not about the encoding is returned as it is, without
+	// sending the request again uncompressed.
+	body := bytes.Repeat([]byte("{\"index\":{}}\n{\"message\":\"hello\"}\n"), 100)
+	if _, err := c.Bulk(context.Background(), "other", body); err != nil {
+		t.Fatalf("Error sending bulk data: %s", err)
+	}
+	_, err := c.Bulk(context.Background(), "logs", body)
+	var resErr *ResponseError
+	if !errors.As(err, &resErr) || resErr.StatusCode != http.StatusBadRequest || !strings.Contains(err.Error(), "bad bulk") {
+		t.Errorf("Expected the bad request error, got %v", err)
+	}
+	if strings.Join(encodings, ",") != ",gzip" {
+		t.Errorf("Unexpected request encodings: %q", encodings)
+	}
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrNotSupported is returned by the operations of a SenderClient that need a cluster.
+var ErrNotSupported = errors.New("operation not supported by the backend")
+
+// Sender delivers documents to a backend other than a cluster, e.g. the HTTP
+// input of Logstash, a Kafka topic or a recorder in tests, see NewSenderClient.
+type Sender interface {
+	// Send delivers a single document, index is the index it would be written to.
+	// doc may be reused once Send returns, an asynchronous Sender must copy it.
+	Send(ctx context.Context, index string, doc []byte) error
+}
+
+// BulkSender is implemented by the Senders delivering a batch at once. body
+// holds the NDJSON bulk actions, index is their default index. body is reused
+// for the next batch once SendBulk returns, an asynchronous BulkSender (e.g. a
+// Kafka producer that does not wait for the acknowledgement) must copy it.
+type BulkSender interface {
+	SendBulk(ctx context.Context, index string, body []byte) error
+}
+
+// SenderClient is a Client delivering the documents with a Sender, so a hook
+// can ship its entries to another backend. The indices are reported to exist,
+// creating them does nothing, and the operations that need a cluster (search,
+// rollover, mappings, templates and policies) fail with ErrNotSupported.
+type SenderClient struct {
+	sender Sender
+}
+
+// NewSenderClient creates a new SenderClient delivering the documents with s.
+// The batches of a bulk processor hook are sent with SendBulk if s is a
+// BulkSender, one document at a time otherwise.
+func NewSenderClient(s Sender) *SenderClient {
+	return &SenderClient{sender: s}
+}
+
+// Bulk implements Client. A document failing without a ResponseError is
+// reported with a server error status, so it is retried like a transient error.
+func (c *SenderClient) Bulk(ctx context.Context, index string, body []byte) (*BulkResponse, error) {
+	res := &BulkResponse{}
+	if s, ok := c.sender.(BulkSender); ok {
+		if err := s.SendBulk(ctx, index, body); err != nil {
+			return nil, err
+		}
+		for rest := body; len(rest) > 0; {
+			var item []byte
+			item, rest = nextBulkItem(rest)
+			action, name := bulkAction(item, index)
+			res.Items = append(res.Items, map[string]BulkResponseItem{action: {Index: name, Status: http.StatusCreated}})
+		}
+		return res, nil
+	}
+	for rest := body; len(rest) > 0; {
+		var item []byte
+		item, rest = nextBulkItem(rest)
+		action, name := bulkAction(item, index)
+		result := BulkResponseItem{Index: name, Status: http.StatusCreated}
+		if err := c.sender.Send(ctx, name, bulkDocument(item)); err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			result.Status = http.StatusInternalServerError
+			var e *ResponseError
+			if errors.As(err, &e) {
+				result.Status = e.StatusCode
+			}
+			result.Error = &ErrorCause{Type: "send_exception", Reason: err.Error()}
+			res.Errors = true
+		}
+		res.Items = append(res.Items, map[string]BulkResponseItem{action: result})
+	}
+	return res, nil
+}
+
+// bulkAction returns the action of a bulk item and the index of its document,
+// index if the action does not name one.
+func bulkAction(item []byte, index string) (string, string) {
+	var action map[string]struct {
+		Index string `json:"_index"`
+	}
+	if i := bytes.IndexByte(item, '\n'); i >= 0 && json.Unmarshal(item[:i], &action) == nil {
+		for name, meta := range action {
+			if meta.Index != "" {
+				return name, meta.Index
+			}
+			return name, index
+		}
+	}
+	return "index", index
+}
+
+// IndexExists implements Client, every index exists.
+func (c *SenderClient) IndexExists(ctx context.Context, index string) (bool, error) {
+	return true, nil
+}
+
+// CreateIndex implements Client, it does nothing.
+func (c *SenderClient) CreateIndex(ctx context.Context, index string, body []byte) error {
+	return nil
+}
+
+// Index implements Client.
+func (c *SenderClient) Index(ctx context.Context, index string, doc []byte) (*IndexResponse, error) {
+	return c.IndexWithMeta(ctx, index, doc, "application/json", DocumentMeta{})
+}
+
+// IndexAs implements Client.
+func (c *SenderClient) IndexAs(ctx context.Context, index string, doc []byte, contentType string) (*IndexResponse, error) {
+	return c.IndexWithMeta(ctx, index, doc, contentType, DocumentMeta{})
+}
+
+// IndexWithMeta implements Client, the content type and the metadata are not
+// passed to the Sender.
+func (c *SenderClient) IndexWithMeta(ctx context.Context, index string, doc []byte, contentType string, meta DocumentMeta) (*IndexResponse, error) {
+	if err := c.sender.Send(ctx, index, doc); err != nil {
+		return nil, err
+	}
+	return &IndexResponse{Index: index, ID: meta.ID, Result: "created"}, nil
+}
+
+// Search implements Client, it is not supported.
+func (c *SenderClient) Search(ctx context.Context, index string, body []byte) (*SearchResponse, error) {
+	return nil, ErrNotSupported
+}
+
+// ListIndices implements Client, it is not supported.
+func (c *SenderClient) ListIndices(ctx context.Context, pattern string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+// DeleteIndex implements Client, it is not supported.
+func (c *SenderClient) DeleteIndex(ctx context.Context, index string) error {
+	return ErrNotSupported
+}
+
+// CloseIndex implements Client, it is not supported.
+func (c *SenderClient) CloseIndex(ctx context.Context, index string) error {
+	return ErrNotSupported
+}
+
+// Rollover implements Client, it is not supported.
+func (c *SenderClient) Rollover(ctx context.Context, alias string, conditions RolloverConditions) (*RolloverResponse, error) {
+	return nil, ErrNotSupported
+}
+
+// GetMapping implements Client, it is not supported.
+func (c *SenderClient) GetMapping(ctx context.Context, index string) (json.RawMessage, error) {
+	return nil, ErrNotSupported
+}
+
+// PutMapping implements Client, it is not supported.
+func (c *SenderClient) PutMapping(ctx context.Context, index string, body []byte) error {
+	return ErrNotSupported
+}
+
+// AliasExists implements Client, it is not supported.
+func (c *SenderClient) AliasExists(ctx context.Context, alias string) (bool, error) {
+	return false, ErrNotSupported
+}
+
+// PutLifecyclePolicy implements Client, it is not supported.
+func (c *SenderClient) PutLifecyclePolicy(ctx context.Context, name string, policy LifecyclePolicy) error {
+	return ErrNotSupported
+}
+
+// PutIndexTemplate implements Client, it is not supported.
+func (c *SenderClient) PutIndexTemplate(ctx context.Context, name string, template IndexTemplate) error {
+	return ErrNotSupported
+}
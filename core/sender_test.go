@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type recorder struct {
+	docs []string
+}
+
+func (r *recorder) Send(ctx context.Context, index string, doc []byte) error {
+	if strings.Contains(string(doc), "rejected") {
+		return errors.New("rejected")
+	}
+	r.docs = append(r.docs, index+" "+string(doc))
+	return nil
+}
+
+func TestSenderClient(t *testing.T) {
+	r := &recorder{}
+	c := NewSenderClient(r)
+	if exists, err := c.IndexExists(context.Background(), "sender-log"); !exists || err != nil {
+		t.Errorf("Expected every index to exist, got %v, %v", exists, err)
+	}
+	if _, err := c.Search(context.Background(), "sender-log", nil); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+	if _, err := c.Index(context.Background(), "sender-log", []byte(`{"message":"single"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	body := "{\"index\":{}}\n{\"message\":\"first\"}\n" +
+		"{\"create\":{\"_index\":\"other-log\"}}\n{\"message\":\"second\"}\n" +
+		"{\"index\":{}}\n{\"message\":\"rejected\"}\n"
+	res, err := c.Bulk(context.Background(), "sender-log", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`sender-log {"message":"single"}`,
+		`sender-log {"message":"first"}`,
+		`other-log {"message":"second"}`,
+	}
+	if strings.Join(r.docs, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, r.docs)
+	}
+	if !res.Errors || len(res.Items) != 3 {
+		t.Fatalf("Expected 3 items with errors, got %+v", res)
+	}
+	if item := res.Items[1]["create"]; item.Index != "other-log" || item.Status != http.StatusCreated {
+		t.Errorf("Unexpected item: %+v", item)
+	}
+	if item := res.Items[2]["index"]; item.Status != http.StatusInternalServerError || item.Error == nil {
+		t.Errorf("Expected a failed item, got %+v", item)
+	}
+}
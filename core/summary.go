@@ -0,0 +1,84 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// FlushSummary describes the outcome of a batch sent by a Batcher.
+type FlushSummary struct {
+	// Took is the processing time reported by the cluster, summed over the attempts.
+	Took time.Duration
+	// Documents and Bytes of the batch.
+	Documents int
+	Bytes     int
+	// Succeeded is the number of documents written.
+	Succeeded int
+	// Failed counts the documents not written by reason: the error type reported
+	// by the cluster (e.g. "mapper_parsing_exception"), the HTTP status if there
+	// is none, or "request_failed" if the request could not be sent.
+	Failed map[string]int
+	// Err is the error of the request, if it failed as a whole.
+	Err error
+	// Spilled is the number of documents of a failed request kept to be sent
+	// later, see Batcher.SetSpillHandler. They are not counted as failed.
+	Spilled int
+	// Requeued is the number of documents rejected with a transient status and
+	// added back to the queue, see Batcher.SetRequeue. They are not counted as failed.
+	Requeued int
+}
+
+// count adds the results of the items of a bulk response, for a body of docs
+// documents. Items rejected because of a missing index are skipped if skipMissing.
+func (s *FlushSummary) count(res *BulkResponse, docs int, skipMissing bool) {
+	s.Took += time.Duration(res.Took) * time.Millisecond
+	if len(res.Items) == 0 && !res.Errors {
+		s.Succeeded += docs
+		return
+	}
+	for _, item := range res.Items {
+		for _, result := range item {
+			switch {
+			case result.Error != nil && result.Error.Type == indexNotFound && skipMissing:
+			case result.Status < http.StatusMultipleChoices && result.Error == nil:
+				s.Succeeded++
+			case result.Error != nil && result.Error.Type != "":
+				s.fail(result.Error.Type, 1)
+			default:
+				s.fail(strconv.Itoa(result.Status), 1)
+			}
+		}
+	}
+}
+
+// failed records the documents of a failed request.
+func (s *FlushSummary) failed(err error, docs int) {
+	s.Err = err
+	reason := "request_failed"
+	var e *ResponseError
+	if errors.As(err, &e) {
+		reason = e.Type
+		if reason == "" {
+			reason = strconv.Itoa(e.StatusCode)
+		}
+	}
+	s.fail(reason, docs)
+}
+
+func (s *FlushSummary) fail(reason string, docs int) {
+	if s.Failed == nil {
+		s.Failed = make(map[string]int)
+	}
+	s.Failed[reason] += docs
+}
+
+// failures returns the number of documents not written.
+func (s *FlushSummary) failures() int {
+	n := 0
+	for _, docs := range s.Failed {
+		n += docs
+	}
+	return n
+}
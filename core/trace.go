@@ -0,0 +1,21 @@
+package core
+
+import "context"
+
+// Operations of the requests passed to a Tracer.
+const (
+	// OpIndex indexes a single document.
+	OpIndex = "index"
+	// OpBulk sends a batch of documents.
+	OpBulk = "bulk"
+)
+
+// Tracer traces the requests sending documents to the cluster, e.g. with
+// OpenTelemetry spans. It must be safe for concurrent use.
+type Tracer interface {
+	// Start is called before a request of the operation op sending docs
+	// documents of size bytes in total to index. It returns the context of
+	// the request and a function called with the error of the request, if
+	// any, once it is done.
+	Start(ctx context.Context, op, index string, docs, bytes int) (context.Context, func(err error))
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Defaults of ConnectionOptions.
+const (
+	DefaultKeepAlive           = 30 * time.Second
+	DefaultMaxIdleConnsPerHost = 16
+)
+
+// ConnectionOptions tune the connections of a transport for high log rates.
+// The transport of net/http keeps only 2 idle connections per host, so that
+// concurrent bulk requests keep opening and closing connections.
+type ConnectionOptions struct {
+	// KeepAlive is the interval of the TCP keep-alive probes, DefaultKeepAlive if 0.
+	KeepAlive time.Duration
+	// MaxIdleConnsPerHost is the number of idle connections kept per node,
+	// DefaultMaxIdleConnsPerHost if 0.
+	MaxIdleConnsPerHost int
+	// ResponseHeaderTimeout limits the time to wait for the response headers of
+	// a request, no limit if 0.
+	ResponseHeaderTimeout time.Duration
+	// TLSHandshakeTimeout limits the time to wait for a TLS handshake, the one
+	// of http.DefaultTransport if 0.
+	TLSHandshakeTimeout time.Duration
+}
+
+// NewDialer creates the dialer of the transports created by NewRoundTripper.
+func NewDialer(opts ConnectionOptions) *net.Dialer {
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = DefaultKeepAlive
+	}
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: opts.KeepAlive,
+	}
+}
+
+// NewRoundTripper creates an http.Transport with the connection options, for the
+// Transport of the official clients or the *http.Client of NewHTTPTransport.
+// The other settings are the ones of http.DefaultTransport.
+func NewRoundTripper(opts ConnectionOptions) *http.Transport {
+	if opts.MaxIdleConnsPerHost == 0 {
+		opts.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = NewDialer(opts).DialContext
+	t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	if t.MaxIdleConns < opts.MaxIdleConnsPerHost {
+		t.MaxIdleConns = opts.MaxIdleConnsPerHost
+	}
+	t.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	if opts.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+	}
+	return t
+}
+
+// HTTPTransport is a minimal Transport sending requests to a single node with
+// a plain *http.Client. The official clients provide a better Transport with
+// node discovery and retries, HTTPTransport is meant for simple setups and tests.
+type HTTPTransport struct {
+	URL    *url.URL
+	Client *http.Client
+}
+
+// NewHTTPTransport creates a new HTTPTransport, a nil client means http.DefaultClient.
+func NewHTTPTransport(rawURL string, client *http.Client) (*HTTPTransport, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPTransport{URL: u, Client: client}, nil
+}
+
+// Perform implements Transport.
+func (t *HTTPTransport) Perform(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = t.URL.Scheme
+	req.URL.Host = t.URL.Host
+	req.URL.Path = strings.TrimSuffix(t.URL.Path, "/") + req.URL.Path
+	if t.URL.User != nil && req.Header.Get("Authorization") == "" {
+		password, _ := t.URL.User.Password()
+		req.SetBasicAuth(t.URL.User.Username(), password)
+	}
+	return t.Client.Do(req)
+}
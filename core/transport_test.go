@@ -0,0 +1,25 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewRoundTripper(t *testing.T) {
+	rt := NewRoundTripper(ConnectionOptions{ResponseHeaderTimeout: 5 * time.Second})
+	if rt.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost || rt.ResponseHeaderTimeout != 5*time.Second {
+		t.Errorf("Unexpected transport settings: %d, %s", rt.MaxIdleConnsPerHost, rt.ResponseHeaderTimeout)
+	}
+
+	rt = NewRoundTripper(ConnectionOptions{MaxIdleConnsPerHost: 500, TLSHandshakeTimeout: time.Second})
+	if rt.MaxIdleConnsPerHost != 500 || rt.MaxIdleConns < 500 || rt.ResponseHeaderTimeout != 0 || rt.TLSHandshakeTimeout != time.Second {
+		t.Errorf("Unexpected transport settings: %d, %d, %s, %s", rt.MaxIdleConnsPerHost, rt.MaxIdleConns, rt.ResponseHeaderTimeout, rt.TLSHandshakeTimeout)
+	}
+
+	if d := NewDialer(ConnectionOptions{}); d.KeepAlive != DefaultKeepAlive {
+		t.Errorf("Expected the default keep-alive, got %s", d.KeepAlive)
+	}
+	if d := NewDialer(ConnectionOptions{KeepAlive: time.Minute}); d.KeepAlive != time.Minute {
+		t.Errorf("Expected a keep-alive of a minute, got %s", d.KeepAlive)
+	}
+}
@@ -0,0 +1,30 @@
+package elogrus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// ErrNoTimestamp is returned by Fire of a hook writing to a data stream when
+// the document of the entry has no @timestamp field, see WithDataStream.
+var ErrNoTimestamp = errors.New("data stream documents need an @timestamp field")
+
+// timestampKey is the name of the timestamp field, it is looked for in the
+// documents of the serializers other than JSON.
+var timestampKey = []byte(DefaultTimestampField)
+
+// hasTimestamp reports whether an encoded document has an @timestamp field at
+// its root. Only the top-level keys of JSON documents are decoded, the
+// documents of other serializers are searched for the name of the field.
+func (hook *ElasticHook) hasTimestamp(data []byte) bool {
+	if hook.serializer != nil {
+		return bytes.Contains(data, timestampKey)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	_, ok := fields[DefaultTimestampField]
+	return ok
+}
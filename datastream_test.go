@@ -0,0 +1,65 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestWithDataStream(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client), WithDataStream("logs-app-default"))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("sync")); err != nil {
+		t.Fatal(err)
+	}
+	if reqs := f.Requests(http.MethodPost, "/logs-app-default/_doc"); len(reqs) != 1 || reqs[0].Query != "op_type=create" {
+		t.Errorf("Expected the document to be created: %+v", reqs)
+	}
+	if reqs := f.Requests("", "/logs-app-default"); len(reqs) != 0 {
+		t.Errorf("Expected the data stream to be neither checked nor created: %+v", reqs)
+	}
+
+	hook.MessageModifierFunc = func(entry *logrus.Entry, message *Message) interface{} {
+		return map[string]string{"message": message.Message}
+	}
+	if err := hook.Submit(NewDocument().SetMessage("no timestamp")); !errors.Is(err, ErrNoTimestamp) {
+		t.Errorf("Expected ErrNoTimestamp, got %v", err)
+	}
+	// only a top-level field counts
+	hook.MessageModifierFunc = func(entry *logrus.Entry, message *Message) interface{} {
+		return map[string]interface{}{"message": "@timestamp", "event": map[string]string{"@timestamp": message.Timestamp}}
+	}
+	if err := hook.Submit(NewDocument().SetMessage("nested timestamp")); !errors.Is(err, ErrNoTimestamp) {
+		t.Errorf("Expected ErrNoTimestamp, got %v", err)
+	}
+	hook.MessageModifierFunc = func(entry *logrus.Entry, message *Message) interface{} {
+		return map[string]string{"@timestamp": message.Timestamp}
+	}
+	if err := hook.Submit(NewDocument().SetMessage("timestamp")); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	hook, err = NewElasticHookWithOptions(es8.New(client), WithDataStream("logs-app-default"), WithDeliveryMode(BulkDelivery))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("bulk")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reqs := f.Requests(http.MethodPost, "/logs-app-default/_bulk")
+	if len(reqs) != 1 || !strings.HasPrefix(reqs[0].Body, `{"create":{}}`+"\n") {
+		t.Errorf("Unexpected bulk requests: %+v", reqs)
+	}
+}
@@ -0,0 +1,153 @@
+package elogrus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestDeadLetterHandler(t *testing.T) {
+	constructors := map[string]func(*elasticsearch.Client, string, logrus.Level, string) (*ElasticHook, error){
+		"sync":  NewElasticHook,
+		"async": NewAsyncElasticHook,
+		"bulk":  NewBulkProcessorElasticHook,
+	}
+	for name, newHook := range constructors {
+		t.Run(name, func(t *testing.T) {
+			f, client := newFakeElastic(t)
+			f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/_doc") && strings.Contains(body, "rejected"):
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = io.WriteString(w, `{"error":{"type":"mapper_parsing_exception","reason":"failed"}}`)
+					return true
+				case strings.HasSuffix(r.URL.Path, "/_bulk"):
+					// the order of the documents in a batch is not guaranteed
+					var items []string
+					lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+					for i := 1; i < len(lines); i += 2 {
+						if strings.Contains(lines[i], "rejected") {
+							items = append(items, `{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed"}}}`)
+						} else {
+							items = append(items, `{"index":{"status":201}}`)
+						}
+					}
+					_, _ = io.WriteString(w, `{"errors":true,"items":[`+strings.Join(items, ",")+`]}`)
+					return true
+				}
+				return false
+			}
+			hook, err := newHook(client, "localhost", logrus.InfoLevel, "dead-letter-log")
+			if err != nil {
+				t.Fatalf("Error creating the hook: %s", err)
+			}
+			defer hook.Cancel()
+			var mu sync.Mutex
+			var entries []*logrus.Entry
+			var docs []string
+			hook.DeadLetterHandler = func(entry *logrus.Entry, doc []byte, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				entries = append(entries, entry)
+				docs = append(docs, string(doc))
+				if !strings.Contains(err.Error(), "mapper_parsing_exception") {
+					t.Errorf("Unexpected error: %s", err)
+				}
+			}
+			logger := logrus.New()
+			logger.Out = io.Discard
+			logger.Hooks.Add(hook)
+
+			logger.WithField("user", "joe").Info("rejected")
+			logger.Info("accepted")
+			_ = hook.Flush(context.Background())
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(docs) != 1 || !strings.Contains(docs[0], `"message":"rejected"`) || strings.Contains(docs[0], "\n") {
+				t.Fatalf("Unexpected dead letters: %q", docs)
+			}
+			if name == "bulk" {
+				if entries[0] != nil {
+					t.Errorf("Expected no entry for a bulk processor hook, got %+v", entries[0])
+				}
+			} else if entries[0] == nil || entries[0].Message != "rejected" || entries[0].Data["user"] != "joe" {
+				t.Errorf("Unexpected entry: %+v", entries[0])
+			}
+		})
+	}
+}
+
+func TestFallbackWriter(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_doc") && strings.Contains(body, "rejected") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":{"type":"mapper_parsing_exception","reason":"failed"}}`)
+			return true
+		}
+		return false
+	}
+	var fallback strings.Builder
+	hook, err := NewElasticHookWithOptions(es8.New(client), WithIndex("fallback-log"), WithFallbackWriter(&fallback))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+	logger.Info("accepted")
+	logger.Info("rejected one")
+	logger.Info("rejected two")
+
+	lines := strings.Split(strings.TrimSuffix(fallback.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"rejected one"`) || !strings.Contains(lines[1], `"rejected two"`) {
+		t.Errorf("Expected the rejected documents one per line, got %q", fallback.String())
+	}
+}
+
+func TestDeadLetterHandlerKeepsDocuments(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if !strings.HasSuffix(r.URL.Path, "/_bulk") {
+			return false
+		}
+		_, _ = io.WriteString(w, `{"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed"}}}]}`)
+		return true
+	}
+	hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "dead-letter-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+	var mu sync.Mutex
+	var docs [][]byte
+	hook.DeadLetterHandler = func(entry *logrus.Entry, doc []byte, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		docs = append(docs, doc)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	// The second flush reuses the buffer of the first one.
+	logger.Info("first")
+	_ = hook.Flush(context.Background())
+	logger.Info("second")
+	_ = hook.Flush(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(docs) != 2 || !strings.Contains(string(docs[0]), `"message":"first"`) || !strings.Contains(string(docs[1]), `"message":"second"`) {
+		t.Errorf("Unexpected dead letters: %q", docs)
+	}
+}
@@ -0,0 +1,40 @@
+package elogrus
+
+import "github.com/sirupsen/logrus"
+
+// DebugKey marks the entries of the diagnostics a hook writes to a logrus
+// DebugLogger. The hook drops the entries with this field, so a logger it is
+// added to can receive its diagnostics without recursing into it.
+const DebugKey = "elogrus.debug"
+
+// DebugLogger receives the diagnostics of a hook: the indices it creates, the
+// requests it retries, the batches it flushes and the errors it handles.
+// *log.Logger and *logrus.Logger implement it. See SetDebugLogger.
+type DebugLogger interface {
+	Printf(format string, v ...interface{})
+}
+
+// SetDebugLogger makes the hook write its diagnostics to l, nil turns them off,
+// the default. A logrus.FieldLogger gets them with the DebugKey field, which
+// the hook drops, any other logger must not write to a logrus logger the hook
+// is added to.
+func (hook *ElasticHook) SetDebugLogger(l DebugLogger) {
+	hook.debugLogger = l
+}
+
+// debugf writes a diagnostic to the DebugLogger of the hook.
+func (hook *ElasticHook) debugf(format string, v ...interface{}) {
+	switch l := hook.debugLogger.(type) {
+	case nil:
+	case logrus.FieldLogger:
+		l.WithField(DebugKey, true).Printf("elogrus: "+format, v...)
+	default:
+		l.Printf("elogrus: "+format, v...)
+	}
+}
+
+// isDebugEntry reports whether the entry is a diagnostic of a hook.
+func isDebugEntry(entry *logrus.Entry) bool {
+	_, ok := entry.Data[DebugKey]
+	return ok
+}
@@ -0,0 +1,56 @@
+package elogrus
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestDebugLogger(t *testing.T) {
+	f, client := newFakeElastic(t)
+	var attempts int32
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/_doc") && atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = io.WriteString(w, `{"error":{"type":"es_rejected_execution_exception","reason":"failed"}}`)
+			return true
+		}
+		return false
+	}
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &out
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("debug-log"),
+		WithDebugLogger(logger),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+	// the diagnostics go to the logger the hook is added to
+	logger.AddHook(hook)
+
+	logger.Info("shipped")
+	for _, want := range []string{"elogrus: created index debug-log", "elogrus: retrying document for index debug-log"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the diagnostic %q, got %s", want, out.String())
+		}
+	}
+	var docs []string
+	for _, r := range f.Requests(http.MethodPost, "/debug-log/_doc") {
+		docs = append(docs, r.Body)
+	}
+	if len(docs) != 2 || strings.Contains(strings.Join(docs, ""), "elogrus:") {
+		t.Errorf("Expected the entry to be sent twice and no diagnostics, got %v", docs)
+	}
+}
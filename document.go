@@ -0,0 +1,202 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// Document is a log document that is not produced by a logrus logger, e.g. an
+// audit event. Submit sends it through the same pipeline as the logged entries.
+//
+//	doc := elogrus.NewDocument().
+//		SetMessage("user deleted").
+//		SetLevel(logrus.WarnLevel).
+//		AddField("user", "joe")
+//	err := hook.Submit(doc)
+type Document = core.Document
+
+// NewDocument creates a new document with the info level and the current time.
+func NewDocument() *Document {
+	return core.NewDocument()
+}
+
+// Submit sends a document the same way as an entry of a logger the hook is
+// added to: it is subject to the level and the sampling of the hook, passed
+// to the MessageModifierFunc and delivered by the sync, async or bulk processor.
+func (hook *ElasticHook) Submit(doc *Document) error {
+	return hook.Fire(doc.Entry())
+}
+
+// SetDocumentType makes the hook send documents of type T instead of Message.
+// For every entry a new T is populated by the populate function, so its JSON
+// tags fully control the shape of the document, e.g.:
+//
+//	type AuditEvent struct {
+//		At     time.Time `json:"at"`
+//		Action string    `json:"action"`
+//		User   string    `json:"user,omitempty"`
+//	}
+//
+//	elogrus.SetDocumentType(hook, func(entry *logrus.Entry, doc *AuditEvent) {
+//		doc.At = entry.Time
+//		doc.Action = entry.Message
+//		doc.User, _ = entry.Data["user"].(string)
+//	})
+//
+// The MessageModifierFunc is not called for such documents, and their fields are
+// not redacted (see SetRedaction), populate must leave the secrets out.
+func SetDocumentType[T any](hook *ElasticHook, populate func(entry *logrus.Entry, doc *T)) {
+	hook.documentFunc = func(entry *logrus.Entry) interface{} {
+		doc := new(T)
+		populate(entry, doc)
+		return doc
+	}
+}
+
+// SetFieldRenames makes the hook rename the data fields of the entries in their
+// documents, from the keys of renames to their values, e.g. {"user_id": "user.id"}
+// to match the mappings of an existing index without changing the logging code.
+// The static fields of the configuration are renamed too, a renamed field
+// replaces a field with its new name. Documents of the types set with
+// SetDocumentType are created from the entries as they are.
+func (hook *ElasticHook) SetFieldRenames(renames map[string]string) {
+	hook.fieldRenames = make(map[string]string, len(renames))
+	for from, to := range renames {
+		hook.fieldRenames[from] = to
+	}
+}
+
+// SetExpandKeys makes the hook expand the dotted keys of the data fields into
+// nested objects, e.g. {"http.request.method": "GET"} into
+// {"http": {"request": {"method": "GET"}}}, after they are renamed. A dotted key
+// clashing with a field that is not expanded, like "http" and "http.method", or
+// with an empty part, is kept as is. ECS labels are always flat, so documents in
+// the ECS format and the types set with SetDocumentType are not expanded.
+func (hook *ElasticHook) SetExpandKeys(expand bool) {
+	hook.expandKeys = expand
+}
+
+// SetDedotKeys makes the hook replace the dots in the keys of the data fields
+// with separator, usually "_", after they are renamed, e.g. "http.method" with
+// "http_method", for the clusters where the dotted keys cause mapping conflicts
+// or explosions. A replaced key replaces a field with its new name. It applies
+// to every document format but the types set with SetDocumentType, and makes
+// SetExpandKeys pointless. An empty separator keeps the keys as they are.
+func (hook *ElasticHook) SetDedotKeys(separator string) error {
+	if strings.Contains(separator, ".") {
+		return errors.New("the separator must not contain a dot")
+	}
+	hook.dedotSeparator = separator
+	return nil
+}
+
+// dedotKeys replaces the dots in the keys of data with separator.
+func dedotKeys(data logrus.Fields, separator string) {
+	var dotted []string
+	for k := range data {
+		if strings.Contains(k, ".") {
+			dotted = append(dotted, k)
+		}
+	}
+	// sorted, so the clashing keys are replaced the same way every time
+	sort.Strings(dotted)
+	for _, k := range dotted {
+		data[strings.ReplaceAll(k, ".", separator)] = data[k]
+		delete(data, k)
+	}
+}
+
+// expandedFields is a nested object created by expandKeys, the fields logged
+// as maps are never merged with the dotted keys.
+type expandedFields map[string]interface{}
+
+// expandKeys moves the values of the dotted keys of data to nested objects.
+func expandKeys(data logrus.Fields) {
+	var dotted []string
+	for k := range data {
+		if strings.Contains(k, ".") {
+			dotted = append(dotted, k)
+		}
+	}
+	// sorted, so the clashing keys are kept the same way every time
+	sort.Strings(dotted)
+	for _, k := range dotted {
+		if expandKey(data, strings.Split(k, "."), data[k]) {
+			delete(data, k)
+		}
+	}
+}
+
+// expandKey sets the value of the nested key parts of data, creating the
+// missing objects. It reports whether it was set.
+func expandKey(data logrus.Fields, parts []string, v interface{}) bool {
+	for _, p := range parts {
+		if p == "" {
+			return false
+		}
+	}
+	// check the existing part of the path before creating any object
+	var obj map[string]interface{} = data
+	last := len(parts) - 1
+	i := 0
+	for ; i < last; i++ {
+		next, ok := obj[parts[i]]
+		if !ok {
+			break
+		}
+		nested, ok := next.(expandedFields)
+		if !ok {
+			return false
+		}
+		obj = nested
+	}
+	if _, ok := obj[parts[last]]; i == last && ok {
+		return false
+	}
+	for ; i < last; i++ {
+		nested := expandedFields{}
+		obj[parts[i]] = nested
+		obj = nested
+	}
+	obj[parts[last]] = v
+	return true
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// pointerMarshalers caches whether the values of a type only implement
+// json.Marshaler through a pointer.
+var pointerMarshalers sync.Map // reflect.Type -> bool
+
+// addressMarshalers replaces the values of data that implement json.Marshaler
+// with a pointer receiver by pointers to copies of them, so they are encoded
+// with their MarshalJSON method rather than field by field: encoding/json only
+// calls it for addressable values, which the values of a map are not. The
+// values implementing json.Marshaler themselves, e.g. json.RawMessage, are
+// embedded verbatim already.
+func addressMarshalers(data logrus.Fields) {
+	for k, v := range data {
+		if v == nil {
+			continue
+		}
+		t := reflect.TypeOf(v)
+		isPointer, ok := pointerMarshalers.Load(t)
+		if !ok {
+			isPointer = t.Kind() != reflect.Ptr && !t.Implements(jsonMarshalerType) && reflect.PtrTo(t).Implements(jsonMarshalerType)
+			pointerMarshalers.Store(t, isPointer)
+		}
+		if isPointer.(bool) {
+			p := reflect.New(t)
+			p.Elem().Set(reflect.ValueOf(v))
+			data[k] = p.Interface()
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestSubmit(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "audit-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+
+	ts := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	doc := NewDocument().
+		SetMessage("user deleted").
+		SetLevel(logrus.WarnLevel).
+		SetTimestamp(ts).
+		AddField("user", "joe")
+	if err := hook.Submit(doc); err != nil {
+		t.Fatalf("Error submitting the document: %s", err)
+	}
+	if err := hook.Submit(NewDocument().SetLevel(logrus.DebugLevel)); err != nil {
+		t.Fatalf("Error submitting the document: %s", err)
+	}
+
+	docs := f.Requests(http.MethodPost, "/audit-log/_doc")
+	if len(docs) != 1 {
+		t.Fatalf("Expected one document, got %d", len(docs))
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(docs[0].Body), &msg); err != nil {
+		t.Fatalf("Error parsing the document: %s", err)
+	}
+	if msg.Message != "user deleted" || msg.Level != "WARNING" || msg.Timestamp != "2022-03-04T05:06:07Z" ||
+		msg.Data["user"] != "joe" || msg.Host != "localhost" {
+		t.Errorf("Unexpected document: %s", docs[0].Body)
+	}
+}
+
+func TestSetDocumentType(t *testing.T) {
+	type auditEvent struct {
+		At     time.Time `json:"at"`
+		Action string    `json:"action"`
+		User   string    `json:"user,omitempty"`
+	}
+
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "audit-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	SetDocumentType(hook, func(entry *logrus.Entry, doc *auditEvent) {
+		doc.At = entry.Time
+		doc.Action = entry.Message
+		doc.User, _ = entry.Data["user"].(string)
+	})
+
+	ts := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := hook.Submit(NewDocument().SetMessage("delete").SetTimestamp(ts).AddField("user", "joe")); err != nil {
+		t.Fatalf("Error submitting the document: %s", err)
+	}
+
+	docs := f.Requests(http.MethodPost, "/audit-log/_doc")
+	if len(docs) != 1 {
+		t.Fatalf("Expected one document, got %d", len(docs))
+	}
+	if expected := `{"at":"2022-03-04T05:06:07Z","action":"delete","user":"joe"}`; docs[0].Body != expected {
+		t.Errorf("Unexpected document: %s", docs[0].Body)
+	}
+}
+
+func TestSetFieldRenames(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("renamed-log"),
+		WithFields(logrus.Fields{"svc": "billing"}),
+		WithFieldRenames(map[string]string{"user_id": "user.id", "svc": "service", "old": "user_id"}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("renamed").AddField("user_id", 42).AddField("old", "legacy")); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := f.Requests(http.MethodPost, "/renamed-log/_doc")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one document, got %d", len(reqs))
+	}
+	var msg struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(reqs[0].Body), &msg); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"user.id": float64(42), "service": "billing", "user_id": "legacy"}
+	if !reflect.DeepEqual(msg.Data, want) {
+		t.Errorf("Expected the fields %v, got %v", want, msg.Data)
+	}
+}
+
+func TestSetExpandKeys(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("expanded-log"),
+		WithFieldRenames(map[string]string{"user_id": "user.id"}),
+		WithExpandKeys(),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	doc := NewDocument().SetMessage("expanded").
+		AddField("http.request.method", "GET").
+		AddField("http.response.status_code", 200).
+		AddField("user_id", 42).
+		AddField("svc", "billing").
+		AddField("svc.name", "clash").
+		AddField("bad..key", 1)
+	if err := hook.Submit(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := f.Requests(http.MethodPost, "/expanded-log/_doc")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one document, got %d", len(reqs))
+	}
+	var msg struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(reqs[0].Body), &msg); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"http": map[string]interface{}{
+			"request":  map[string]interface{}{"method": "GET"},
+			"response": map[string]interface{}{"status_code": float64(200)},
+		},
+		"user":     map[string]interface{}{"id": float64(42)},
+		"svc":      "billing",
+		"svc.name": "clash",
+		"bad..key": float64(1),
+	}
+	if !reflect.DeepEqual(msg.Data, want) {
+		t.Errorf("Expected the fields %v, got %v", want, msg.Data)
+	}
+}
+
+func TestSetDedotKeys(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("dedot-log"),
+		WithECSFormat(),
+		WithDedotKeys("_"),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	doc := NewDocument().SetMessage("dedotted").
+		AddField("http.request.method", "GET").
+		AddField("svc_name", "replaced").
+		AddField("svc.name", "billing").
+		AddField("plain", "kept")
+	if err := hook.Submit(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := f.Requests(http.MethodPost, "/dedot-log/_doc")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one document, got %d", len(reqs))
+	}
+	var msg ECSMessage
+	if err := json.Unmarshal([]byte(reqs[0].Body), &msg); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"http_request_method": "GET", "svc_name": "billing", "plain": "kept"}
+	if !reflect.DeepEqual(msg.Labels, want) {
+		t.Errorf("Expected the labels %v, got %v", want, msg.Labels)
+	}
+
+	if err := hook.SetDedotKeys("."); err == nil {
+		t.Error("Expected an error for a dotted separator")
+	}
+}
+
+// pointerPayload implements json.Marshaler with a pointer receiver only.
+type pointerPayload struct {
+	ID int
+}
+
+func (p *pointerPayload) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"payload_id":%d}`, p.ID)), nil
+}
+
+func TestPreserializedFields(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client), WithIndex("raw-log"))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	doc := NewDocument().SetMessage("raw").
+		AddField("raw", json.RawMessage(`{ "a": [1, 2] }`)).
+		AddField("payload", pointerPayload{ID: 7})
+	if err := hook.Submit(doc); err != nil {
+		t.Fatal(err)
+	}
+	reqs := f.Requests(http.MethodPost, "/raw-log/_doc")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one document, got %d", len(reqs))
+	}
+	if !strings.Contains(reqs[0].Body, `"data":{"payload":{"payload_id":7},"raw":{"a":[1,2]}}`) {
+		t.Errorf("Expected the fields to be embedded verbatim: %s", reqs[0].Body)
+	}
+}
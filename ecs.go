@@ -0,0 +1,162 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// DocumentFormat is the layout of the documents created by a hook.
+type DocumentFormat int
+
+const (
+	// DefaultFormat is the layout of Message: host, level, file, func and the
+	// fields of the entries under data.
+	DefaultFormat DocumentFormat = iota
+	// ECSFormat is the layout of ECSMessage, following the Elastic Common Schema:
+	// log.level, host.name, log.origin.*, error.message and the fields of the
+	// entries as labels.*. The documents can be used by ECS based dashboards and
+	// Elastic Observability without an ingest pipeline.
+	ECSFormat
+)
+
+func (f DocumentFormat) String() string {
+	switch f {
+	case DefaultFormat:
+		return "default"
+	case ECSFormat:
+		return "ecs"
+	}
+	return fmt.Sprintf("DocumentFormat(%d)", int(f))
+}
+
+// mappings returns the index mappings for the documents of the format.
+func (f DocumentFormat) mappings() map[string]interface{} {
+	if f == ECSFormat {
+		return ECSMappings()
+	}
+	return DefaultMappings()
+}
+
+// ECSMessage is the document created for a log entry by hooks using the ECSFormat.
+type ECSMessage = core.ECSMessage
+
+// SetDocumentFormat sets the layout of the documents created by the hook.
+// The indices created by the hook from then on get the mappings of the format,
+// but the index created by the constructor has the DefaultMappings, so the
+// index must have been created with ECSMappings (e.g. by Bootstrap) when the
+// hook uses the ECSFormat, or use WithECSFormat instead. The MessageModifierFunc is not
+// called for ECS documents, and Search expects the default format.
+func (hook *ElasticHook) SetDocumentFormat(format DocumentFormat) {
+	hook.documentFormat = format
+}
+
+// ECSMappings returns the index mappings for the documents of the ECSFormat:
+//
+//   - @timestamp is a date
+//   - message and error.message are full-text searchable
+//   - log.level, host.name, log.origin.file.name, log.origin.function, trace.id
+//     and span.id are keywords
+//   - labels.* are keywords, as required by the Elastic Common Schema
+//
+// The result is a new map on every call, so it can be modified.
+func ECSMappings() map[string]interface{} {
+	keyword := map[string]interface{}{"type": "keyword", "ignore_above": 1024}
+	object := func(properties map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"properties": properties}
+	}
+	return map[string]interface{}{
+		"properties": map[string]interface{}{
+			"@timestamp": map[string]interface{}{"type": "date"},
+			"message":    map[string]interface{}{"type": "text"},
+			"log": object(map[string]interface{}{
+				"level": keyword,
+				"origin": object(map[string]interface{}{
+					"file": object(map[string]interface{}{
+						"name": keyword,
+						"line": map[string]interface{}{"type": "long"},
+					}),
+					"function": keyword,
+				}),
+			}),
+			"host":   object(map[string]interface{}{"name": keyword}),
+			"error":  object(map[string]interface{}{"message": map[string]interface{}{"type": "text"}}),
+			"labels": map[string]interface{}{"type": "object", "dynamic": true},
+			"trace":  object(map[string]interface{}{"id": keyword}),
+			"span":   object(map[string]interface{}{"id": keyword}),
+			"ecs":    object(map[string]interface{}{"version": keyword}),
+		},
+		"dynamic_templates": []interface{}{
+			map[string]interface{}{
+				"labels_strings": map[string]interface{}{
+					"path_match":         "labels.*",
+					"match_mapping_type": "string",
+					"mapping":            keyword,
+				},
+			},
+		},
+	}
+}
+
+// createECSMessage creates the ECS document for the entry.
+func (hook *ElasticHook) createECSMessage(entry *logrus.Entry) *ECSMessage {
+	data := hook.entryData(entry)
+	msg := &ECSMessage{
+		Timestamp: hook.formatTime(entry.Time),
+		Message:   hook.entryMessage(entry, data),
+		Log:       core.ECSLog{Level: hook.ecsLevel(entry.Level)},
+		Host:      hook.ecsHost(),
+		ECS:       core.ECSInfo{Version: core.ECSVersion},
+	}
+	msg.Trace, msg.Span = entryTrace(entry)
+	if entry.HasCaller() {
+		msg.Log.Origin = &core.ECSOrigin{
+			File:     core.ECSFile{Name: entry.Caller.File, Line: entry.Caller.Line},
+			Function: entry.Caller.Function,
+		}
+	}
+	if e, ok := data[logrus.ErrorKey]; ok && e != nil {
+		msg.Error = &core.ECSError{Message: ecsLabel(e)}
+		delete(data, logrus.ErrorKey)
+	}
+	if len(data) > 0 {
+		msg.Labels = make(map[string]string, len(data))
+		for k, v := range data {
+			msg.Labels[k] = ecsLabel(v)
+		}
+	}
+	return msg
+}
+
+// ecsLevel returns the log.level of the ECS documents, the lower-cased level label.
+func (hook *ElasticHook) ecsLevel(level logrus.Level) string {
+	return strings.ToLower(hook.levelLabel(level))
+}
+
+// ecsHost returns the host of the ECS documents, nil if the hook has no host.
+func (hook *ElasticHook) ecsHost() *core.ECSHost {
+	if hook.host == "" {
+		return nil
+	}
+	return &core.ECSHost{Name: hook.host}
+}
+
+// ecsLabel converts a field value to a label, labels are always keywords.
+func ecsLabel(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	if data, err := json.Marshal(v); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
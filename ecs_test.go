@@ -0,0 +1,78 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestECSFormat(t *testing.T) {
+	f, client := newFakeElastic(t)
+	var index atomic.Value
+	index.Store("ecs-log-1")
+	hook, err := NewElasticHookWithFunc(client, "localhost", logrus.InfoLevel, func() string { return index.Load().(string) })
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	hook.SetDocumentFormat(ECSFormat)
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.ReportCaller = true
+	logger.Hooks.Add(hook)
+
+	index.Store("ecs-log-2")
+	ts := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	logger.WithTime(ts).WithError(errors.New("timeout")).WithFields(logrus.Fields{"user": "joe", "attempt": 3}).Warn("retrying")
+
+	docs := f.Requests(http.MethodPost, "/ecs-log-2/_doc")
+	if len(docs) != 1 {
+		t.Fatalf("Expected one document, got %d", len(docs))
+	}
+	var msg ECSMessage
+	if err := json.Unmarshal([]byte(docs[0].Body), &msg); err != nil {
+		t.Fatalf("Error parsing the document: %s", err)
+	}
+	if msg.Timestamp != "2022-03-04T05:06:07Z" || msg.Message != "retrying" || msg.Log.Level != "warning" ||
+		msg.Host == nil || msg.Host.Name != "localhost" || msg.Error == nil || msg.Error.Message != "timeout" ||
+		msg.ECS.Version == "" {
+		t.Errorf("Unexpected document: %s", docs[0].Body)
+	}
+	if len(msg.Labels) != 2 || msg.Labels["user"] != "joe" || msg.Labels["attempt"] != "3" {
+		t.Errorf("Unexpected labels: %v", msg.Labels)
+	}
+	if msg.Log.Origin == nil || !strings.HasSuffix(msg.Log.Origin.File.Name, "ecs_test.go") || msg.Log.Origin.File.Line == 0 {
+		t.Errorf("Unexpected origin: %s", docs[0].Body)
+	}
+
+	created := f.Requests(http.MethodPut, "/ecs-log-2")
+	if len(created) != 1 || !strings.Contains(created[0].Body, "labels_strings") {
+		t.Errorf("Expected the new index to be created with the ECS mappings: %+v", created)
+	}
+}
+
+func TestECSMappings(t *testing.T) {
+	data, err := json.Marshal(ECSMappings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mappings struct {
+		Properties map[string]struct {
+			Type       string                     `json:"type"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		t.Fatal(err)
+	}
+	if mappings.Properties["@timestamp"].Type != "date" || mappings.Properties["host"].Properties["name"] == nil ||
+		mappings.Properties["log"].Properties["level"] == nil {
+		t.Errorf("Unexpected mappings: %s", data)
+	}
+}
@@ -0,0 +1,112 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+func TestEncodeErrorHandler(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.DebugLevel, "encode-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	var handled error
+	hook.ErrorHandler = func(err error, data []byte) { handled = err }
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+	logger.WithField("ratio", math.NaN()).Info("half-baked")
+
+	var e *EncodeError
+	if !errors.As(handled, &e) {
+		t.Errorf("Expected the error handler to be called with an EncodeError, got %v", handled)
+	}
+	docs := f.Requests(http.MethodPost, "/encode-log/_doc")
+	if len(docs) != 1 || !strings.Contains(docs[0].Body, `"ratio":"[NaN]"`) {
+		t.Errorf("Expected the entry to be delivered with a placeholder, got %+v", docs)
+	}
+}
+
+func FuzzEncodeDocument(f *testing.F) {
+	f.Add("key", 1.5, 3, false, "value")
+	f.Add("", math.NaN(), 0, true, "")
+	f.Add("a.b", math.Inf(1), 40, true, "\xff\xfe")
+	f.Fuzz(func(t *testing.T, key string, number float64, depth int, cycle bool, text string) {
+		value := map[string]interface{}{"n": number, "s": text}
+		cur := value
+		for i := 0; i < depth%64; i++ {
+			next := map[string]interface{}{key: text}
+			cur[key] = next
+			cur = next
+		}
+		if cycle {
+			cur["cycle"] = value
+		}
+
+		entry := logrus.NewEntry(logrus.New())
+		entry.Message = text
+		entry.Data = logrus.Fields{key: value, "float": number}
+		doc, _ := createMessage(entry, &ElasticHook{})
+		data, _ := core.Encode(doc)
+		if !json.Valid(data) {
+			t.Fatalf("Invalid JSON: %s", data)
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Cannot decode the document: %s", err)
+		}
+		var expected string
+		raw, _ := json.Marshal(text) // invalid UTF-8 is replaced the same way
+		_ = json.Unmarshal(raw, &expected)
+		if msg.Message != expected {
+			t.Fatalf("Message lost: %q", msg.Message)
+		}
+	})
+}
+
+func TestUseLoggerFormatter(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.DebugLevel, "formatted-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	hook.UseLoggerFormatter = true
+	var handled error
+	hook.ErrorHandler = func(err error, data []byte) { handled = err }
+
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Formatter = &logrus.JSONFormatter{
+		DisableTimestamp: true,
+		DataKey:          "fields",
+		FieldMap:         logrus.FieldMap{logrus.FieldKeyMsg: "text"},
+		PrettyPrint:      true,
+	}
+	logger.AddHook(hook)
+	logger.WithField("user", "joe").Info("hello")
+
+	logger.Formatter = &logrus.TextFormatter{DisableTimestamp: true}
+	logger.Info("plain")
+
+	docs := f.Requests(http.MethodPost, "/formatted-log/_doc")
+	if len(docs) != 2 {
+		t.Fatalf("Expected two documents, got %d", len(docs))
+	}
+	if expected := `{"fields":{"user":"joe"},"level":"info","text":"hello"}`; docs[0].Body != expected {
+		t.Errorf("Unexpected document: %s", docs[0].Body)
+	}
+	if !strings.Contains(docs[1].Body, `"message":"plain"`) || handled == nil {
+		t.Errorf("Expected the default document and an error, got %s (%v)", docs[1].Body, handled)
+	}
+}
@@ -0,0 +1,98 @@
+package elogrus
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OccurrencesKey is the data field holding the number of identical errors seen
+// in the current window, added to the errors shipped after the first ones.
+const OccurrencesKey = "occurrences"
+
+// maxSampledErrors bounds the number of distinct errors counted per window,
+// the errors above it are shipped unsampled.
+const maxSampledErrors = 10000
+
+// ErrorSampling thins out storms of identical errors, i.e. entries of the error
+// level or more severe with the same message and error field: the first First
+// occurrences in every Window are shipped in full, then only every Every-th,
+// with the number of occurrences so far in the OccurrencesKey data field.
+// The zero value turns sampling off.
+type ErrorSampling struct {
+	Window time.Duration
+	First  int
+	// Every defaults to 1, i.e. every error is shipped with its count.
+	Every int
+}
+
+func (s ErrorSampling) enabled() bool {
+	return s.Window > 0
+}
+
+// errorSampler counts the identical errors of the current window.
+type errorSampler struct {
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+// sample reports whether an entry should be shipped and how many identical
+// errors, including it, were seen in the current window.
+func (s *errorSampler) sample(cfg ErrorSampling, entry *logrus.Entry) (int, bool) {
+	if entry.Level > logrus.ErrorLevel {
+		return 0, true
+	}
+	key := entry.Message
+	if err, ok := entry.Data[logrus.ErrorKey]; ok {
+		key += "\x00" + fmt.Sprint(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.counts == nil || now.Sub(s.start) >= cfg.Window {
+		s.start = now
+		s.counts = make(map[string]int)
+	}
+	n, ok := s.counts[key]
+	if !ok && len(s.counts) >= maxSampledErrors {
+		return 0, true
+	}
+	n++
+	s.counts[key] = n
+	if n <= cfg.First {
+		return n, true
+	}
+	every := cfg.Every
+	if every <= 0 {
+		every = 1
+	}
+	return n, (n-cfg.First)%every == 0
+}
+
+// sampleErrors applies the error sampling of the configuration to an entry. It
+// returns nil if the entry is left out, or the entry to ship, a copy with the
+// number of occurrences once the first ones are shipped.
+func (hook *ElasticHook) sampleErrors(cfg *Config, entry *logrus.Entry) *logrus.Entry {
+	if !cfg.ErrorSampling.enabled() {
+		return entry
+	}
+	n, ok := hook.errors.sample(cfg.ErrorSampling, entry)
+	if !ok {
+		return nil
+	}
+	if n <= cfg.ErrorSampling.First {
+		return entry
+	}
+	// The entry is shared with other hooks, so it must not be modified.
+	sampled := *entry
+	sampled.Data = make(logrus.Fields, len(entry.Data)+1)
+	for k, v := range entry.Data {
+		sampled.Data[k] = v
+	}
+	sampled.Data[OccurrencesKey] = n
+	return &sampled
+}
@@ -0,0 +1,54 @@
+package elogrus
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestErrorSampling(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "sampling-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	cfg := hook.Config()
+	cfg.ErrorSampling = ErrorSampling{Window: time.Hour, First: 2, Every: 3}
+	if err := hook.Reconfigure(cfg); err != nil {
+		t.Fatalf("Error reconfiguring the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+
+	for i := 0; i < 8; i++ {
+		logger.WithError(errors.New("connection refused")).Error("query failed")
+		logger.WithError(errors.New("timeout")).Error("query failed")
+		logger.Info("not sampled")
+	}
+
+	var refused, timeout, info []string
+	for _, doc := range f.Requests(http.MethodPost, "/sampling-log/_doc") {
+		switch {
+		case strings.Contains(doc.Body, "connection refused"):
+			refused = append(refused, doc.Body)
+		case strings.Contains(doc.Body, "timeout"):
+			timeout = append(timeout, doc.Body)
+		default:
+			info = append(info, doc.Body)
+		}
+	}
+	// occurrences 1, 2 in full, then 5 and 8
+	if len(refused) != 4 || len(timeout) != 4 || len(info) != 8 {
+		t.Fatalf("Unexpected number of documents: %d, %d, %d", len(refused), len(timeout), len(info))
+	}
+	if strings.Contains(refused[1], OccurrencesKey) || !strings.Contains(refused[2], `"occurrences":5`) ||
+		!strings.Contains(refused[3], `"occurrences":8`) {
+		t.Errorf("Unexpected documents: %s", strings.Join(refused, "\n"))
+	}
+}
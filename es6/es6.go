@@ -0,0 +1,173 @@
+// Package es6 provides the elogrus client for Elasticsearch 6.x.
+//
+// Documents are written with the "_doc" mapping type and mappings are sent
+// with include_type_name=false, which requires Elasticsearch 6.7 or later.
+// NewWithType writes them with another mapping type, e.g. to indices created
+// by Elasticsearch 5 or clusters rejecting typeless writes.
+package es6
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// DocumentType is the mapping type of the documents.
+const DocumentType = "_doc"
+
+// Client implements core.Client for Elasticsearch 6.x.
+type Client struct {
+	*core.RESTClient
+	// docType is the custom mapping type of the documents, see NewWithType
+	docType string
+}
+
+// New creates a new Client performing requests with t, typically
+// a *elasticsearch.Client of github.com/elastic/go-elasticsearch/v6.
+func New(t core.Transport) *Client {
+	c := core.NewRESTClient(t)
+	c.MappingQuery = url.Values{"include_type_name": {"false"}}
+	return &Client{RESTClient: c}
+}
+
+// NewWithType creates a new Client like New, writing the documents with the
+// mapping type docType instead of DocumentType, in the index requests and as
+// the default _type of the bulk actions. The indices, mappings and templates
+// it creates are typed with docType, so the documents fit the single mapping
+// type an index may have.
+func NewWithType(t core.Transport, docType string) *Client {
+	c := New(t)
+	if docType != "" && docType != DocumentType {
+		c.docType = docType
+		c.DocumentType = docType
+		c.MappingQuery = url.Values{"include_type_name": {"true"}}
+	}
+	return c
+}
+
+// documentType returns the mapping type of the documents.
+func (c *Client) documentType() string {
+	if c.docType == "" {
+		return DocumentType
+	}
+	return c.docType
+}
+
+// Bulk implements core.Client. Actions without a _type get the mapping type
+// of the documents.
+func (c *Client) Bulk(ctx context.Context, index string, body []byte) (*core.BulkResponse, error) {
+	path := "/_bulk"
+	if index != "" {
+		path = "/" + url.PathEscape(index) + "/" + url.PathEscape(c.documentType()) + path
+	}
+	var res core.BulkResponse
+	err := c.Do(ctx, core.Request{Method: http.MethodPost, Path: path, Body: body, ContentType: "application/x-ndjson"}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CreateIndex implements core.Client, the mappings of body are typed with the
+// custom mapping type of the documents if any.
+func (c *Client) CreateIndex(ctx context.Context, index string, body []byte) error {
+	if c.docType != "" && len(body) > 0 {
+		var parts map[string]json.RawMessage
+		if err := json.Unmarshal(body, &parts); err != nil {
+			return err
+		}
+		if mappings, ok := parts["mappings"]; ok {
+			parts["mappings"] = c.typed(mappings)
+			var err error
+			if body, err = json.Marshal(parts); err != nil {
+				return err
+			}
+		}
+	}
+	return c.RESTClient.CreateIndex(ctx, index, body)
+}
+
+// GetMapping implements core.Client, the mappings of the custom mapping type
+// of the documents are returned if any.
+func (c *Client) GetMapping(ctx context.Context, index string) (json.RawMessage, error) {
+	mappings, err := c.RESTClient.GetMapping(ctx, index)
+	if err != nil || c.docType == "" || mappings == nil {
+		return mappings, err
+	}
+	var types map[string]json.RawMessage
+	if err := json.Unmarshal(mappings, &types); err != nil {
+		return nil, err
+	}
+	return types[c.docType], nil
+}
+
+// PutMapping implements core.Client, the mappings are put to the custom
+// mapping type of the documents if any.
+func (c *Client) PutMapping(ctx context.Context, index string, body []byte) error {
+	if c.docType == "" {
+		return c.RESTClient.PutMapping(ctx, index, body)
+	}
+	return c.Do(ctx, core.Request{Method: http.MethodPut, Path: "/" + url.PathEscape(index) + "/_mapping/" + url.PathEscape(c.docType), Body: body}, nil)
+}
+
+// typed returns mappings under the custom mapping type of the documents.
+func (c *Client) typed(mappings json.RawMessage) json.RawMessage {
+	typed, _ := json.Marshal(map[string]json.RawMessage{c.docType: mappings})
+	return typed
+}
+
+// PutLifecyclePolicy implements core.Client. Elasticsearch 6 does not know
+// the max_primary_shard_size rollover condition, it is sent as max_size
+// (the total size of the primary shards) instead.
+func (c *Client) PutLifecyclePolicy(ctx context.Context, name string, policy core.LifecyclePolicy) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"policy": map[string]interface{}{"phases": core.ILMPhases(policy, rolloverConditions(policy.Rollover))},
+	})
+	if err != nil {
+		return err
+	}
+	return c.Do(ctx, core.Request{Method: http.MethodPut, Path: "/_ilm/policy/" + url.PathEscape(name), Body: body}, nil)
+}
+
+// Rollover implements core.Client, max_primary_shard_size is sent as max_size
+// like in PutLifecyclePolicy.
+func (c *Client) Rollover(ctx context.Context, alias string, conditions core.RolloverConditions) (*core.RolloverResponse, error) {
+	return c.RolloverWith(ctx, alias, rolloverConditions(conditions))
+}
+
+// rolloverConditions returns the conditions known to Elasticsearch 6.
+func rolloverConditions(conditions core.RolloverConditions) map[string]interface{} {
+	rollover := map[string]interface{}{}
+	if conditions.MaxAge != "" {
+		rollover["max_age"] = conditions.MaxAge
+	}
+	if conditions.MaxPrimaryShardSize != "" {
+		rollover["max_size"] = conditions.MaxPrimaryShardSize
+	}
+	if conditions.MaxDocs != 0 {
+		rollover["max_docs"] = conditions.MaxDocs
+	}
+	return rollover
+}
+
+// PutIndexTemplate implements core.Client, the template is created as a legacy template.
+func (c *Client) PutIndexTemplate(ctx context.Context, name string, template core.IndexTemplate) error {
+	t := map[string]interface{}{
+		"index_patterns": template.IndexPatterns,
+		"settings":       core.TemplateSettings(template, "index.lifecycle.name", "index.lifecycle.rollover_alias"),
+	}
+	if template.Mappings != nil {
+		t["mappings"] = template.Mappings
+		if c.docType != "" {
+			t["mappings"] = map[string]interface{}{c.docType: template.Mappings}
+		}
+	}
+	body, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return c.Do(ctx, core.Request{Method: http.MethodPut, Path: "/_template/" + url.PathEscape(name), Query: c.MappingQuery, Body: body}, nil)
+}
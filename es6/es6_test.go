@@ -0,0 +1,118 @@
+package es6
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+type request struct {
+	Method, Path, Query, Body string
+}
+
+func newTestClient(t *testing.T) (*Client, *[]request) {
+	t.Helper()
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.Path, r.URL.RawQuery, string(body)})
+		_, _ = io.WriteString(w, `{}`)
+	}))
+	t.Cleanup(server.Close)
+	transport, err := core.NewHTTPTransport(server.URL, nil)
+	if err != nil {
+		t.Fatalf("Error creating the transport: %s", err)
+	}
+	return New(transport), &requests
+}
+
+func TestClient(t *testing.T) {
+	c, requests := newTestClient(t)
+	ctx := context.Background()
+
+	if err := c.CreateIndex(ctx, "logs", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Bulk(ctx, "logs", []byte("{\"index\":{}}\n{}\n")); err != nil {
+		t.Fatal(err)
+	}
+	err := c.PutLifecyclePolicy(ctx, "logs", core.LifecyclePolicy{
+		Rollover: core.RolloverConditions{MaxAge: "1d", MaxPrimaryShardSize: "10gb"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.PutIndexTemplate(ctx, "logs", core.IndexTemplate{
+		IndexPatterns:   []string{"logs-*"},
+		LifecyclePolicy: "logs",
+		RolloverAlias:   "logs",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Rollover(ctx, "logs", core.RolloverConditions{MaxPrimaryShardSize: "10gb"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []request{
+		{"PUT", "/logs", "include_type_name=false", `{}`},
+		{"POST", "/logs/_doc/_bulk", "", "{\"index\":{}}\n{}\n"},
+		{"PUT", "/_ilm/policy/logs", "", `{"policy":{"phases":{"hot":{"actions":{"rollover":{"max_age":"1d","max_size":"10gb"}}}}}}`},
+		{"PUT", "/_template/logs", "include_type_name=false", `{"index_patterns":["logs-*"],"settings":{"index.lifecycle.name":"logs","index.lifecycle.rollover_alias":"logs"}}`},
+		{"POST", "/logs/_rollover", "", `{"conditions":{"max_size":"10gb"}}`},
+	}
+	if len(*requests) != len(expected) {
+		t.Fatalf("Unexpected requests: %+v", *requests)
+	}
+	for i, r := range *requests {
+		if r != expected[i] {
+			t.Errorf("Unexpected request %d:\n%+v\nexpected:\n%+v", i, r, expected[i])
+		}
+	}
+}
+
+func TestNewWithType(t *testing.T) {
+	c, requests := newTestClient(t)
+	c = NewWithType(c.Transport, "log")
+	ctx := context.Background()
+
+	if err := c.CreateIndex(ctx, "logs", []byte(`{"mappings":{"properties":{}},"settings":{}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.IndexWithMeta(ctx, "logs", []byte(`{}`), "", core.DocumentMeta{ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Bulk(ctx, "logs", []byte("{\"index\":{}}\n{}\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PutMapping(ctx, "logs", []byte(`{"properties":{}}`)); err != nil {
+		t.Fatal(err)
+	}
+	err := c.PutIndexTemplate(ctx, "logs", core.IndexTemplate{
+		IndexPatterns: []string{"logs-*"},
+		Mappings:      map[string]interface{}{"properties": map[string]interface{}{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []request{
+		{"PUT", "/logs", "include_type_name=true", `{"mappings":{"log":{"properties":{}}},"settings":{}}`},
+		{"PUT", "/logs/log/1", "", `{}`},
+		{"POST", "/logs/log/_bulk", "", "{\"index\":{}}\n{}\n"},
+		{"PUT", "/logs/_mapping/log", "", `{"properties":{}}`},
+		{"PUT", "/_template/logs", "include_type_name=true", `{"index_patterns":["logs-*"],"mappings":{"log":{"properties":{}}},"settings":{}}`},
+	}
+	if len(*requests) != len(expected) {
+		t.Fatalf("Unexpected requests: %+v", *requests)
+	}
+	for i, r := range *requests {
+		if r != expected[i] {
+			t.Errorf("Unexpected request %d:\n%+v\nexpected:\n%+v", i, r, expected[i])
+		}
+	}
+}
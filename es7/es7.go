@@ -0,0 +1,21 @@
+// Package es7 provides the elogrus client for Elasticsearch 7.x.
+//
+// Index templates are created as composable templates, which requires
+// Elasticsearch 7.8 or later; the max_primary_shard_size rollover condition
+// requires 7.13 or later.
+package es7
+
+import (
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// Client implements core.Client for Elasticsearch 7.x.
+type Client struct {
+	*core.RESTClient
+}
+
+// New creates a new Client performing requests with t, typically
+// a *elasticsearch.Client of github.com/elastic/go-elasticsearch/v7.
+func New(t core.Transport) *Client {
+	return &Client{core.NewRESTClient(t)}
+}
@@ -0,0 +1,63 @@
+package es7
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+type request struct {
+	Method, Path, Query, Body string
+}
+
+func TestClient(t *testing.T) {
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.Path, r.URL.RawQuery, string(body)})
+		_, _ = io.WriteString(w, `{}`)
+	}))
+	defer server.Close()
+	transport, err := core.NewHTTPTransport(server.URL, nil)
+	if err != nil {
+		t.Fatalf("Error creating the transport: %s", err)
+	}
+	var c core.Client = New(transport)
+	ctx := context.Background()
+
+	if _, err := c.IndexExists(ctx, "logs"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateIndex(ctx, "logs", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Index(ctx, "logs", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.IndexWithMeta(ctx, "logs", []byte(`{}`), "", core.DocumentMeta{ID: "1", Routing: "app"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Bulk(ctx, "logs", []byte("{\"index\":{}}\n{}\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []request{
+		{"HEAD", "/logs", "", ""},
+		{"PUT", "/logs", "", `{}`},
+		{"POST", "/logs/_doc", "", `{}`},
+		{"PUT", "/logs/_doc/1", "routing=app", `{}`},
+		{"POST", "/logs/_bulk", "", "{\"index\":{}}\n{}\n"},
+	}
+	if len(requests) != len(expected) {
+		t.Fatalf("Unexpected requests: %+v", requests)
+	}
+	for i, r := range requests {
+		if r != expected[i] {
+			t.Errorf("Unexpected request %d:\n%+v\nexpected:\n%+v", i, r, expected[i])
+		}
+	}
+}
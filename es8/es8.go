@@ -0,0 +1,17 @@
+// Package es8 provides the elogrus client for Elasticsearch 8.x.
+package es8
+
+import (
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// Client implements core.Client for Elasticsearch 8.x.
+type Client struct {
+	*core.RESTClient
+}
+
+// New creates a new Client performing requests with t, typically
+// a *elasticsearch.Client of github.com/elastic/go-elasticsearch/v8.
+func New(t core.Transport) *Client {
+	return &Client{core.NewRESTClient(t)}
+}
@@ -0,0 +1,62 @@
+package elogrus
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestWithFailover(t *testing.T) {
+	primary, primaryClient := newFakeElastic(t)
+	secondary, secondaryClient := newFakeElastic(t)
+	var down atomic.Bool
+	primary.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/_doc") && down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		return false
+	}
+	if _, err := NewElasticHookWithOptions(es8.New(primaryClient), WithIndex("failover-log"), WithFailover(nil, time.Second)); err == nil {
+		t.Error("Expected an error without a secondary client")
+	}
+	hook, err := NewElasticHookWithOptions(es8.New(primaryClient),
+		WithIndex("failover-log"),
+		WithFailover(es8.New(secondaryClient), 50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	log := logrus.New()
+	log.Out = io.Discard
+	log.Hooks.Add(hook)
+
+	log.Info("primary")
+	down.Store(true)
+	log.Info("failed over")
+	log.Info("secondary")
+	if docs := primary.Requests(http.MethodPost, "/failover-log/_doc"); len(docs) != 2 {
+		t.Errorf("Expected 2 requests to the primary cluster, got %d", len(docs))
+	}
+	if docs := secondary.Requests(http.MethodPost, "/failover-log/_doc"); len(docs) != 2 {
+		t.Errorf("Expected 2 documents on the secondary cluster, got %d", len(docs))
+	}
+
+	down.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	log.Info("failed back")
+	log.Info("primary again")
+	if docs := primary.Requests(http.MethodPost, "/failover-log/_doc"); len(docs) != 4 {
+		t.Errorf("Expected the hook to fail back to the primary cluster, got %d requests", len(docs))
+	}
+	if docs := secondary.Requests(http.MethodPost, "/failover-log/_doc"); len(docs) != 2 {
+		t.Errorf("Expected no more documents on the secondary cluster, got %d", len(docs))
+	}
+}
@@ -0,0 +1,118 @@
+package elogrus
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// fakeRequest is a request recorded by fakeElastic.
+type fakeRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Body   string
+}
+
+// fakeElastic is a minimal in-memory stand-in for an Elasticsearch node,
+// good enough for unit tests that do not need a real cluster.
+type fakeElastic struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	requests []fakeRequest
+	indices  map[string]string // index name -> mapping (raw JSON)
+
+	// handle, if set, is consulted first; returning false falls back to
+	// the default behaviour.
+	handle func(w http.ResponseWriter, r *http.Request, body string) bool
+}
+
+func newFakeElastic(t *testing.T) (*fakeElastic, *elasticsearch.Client) {
+	t.Helper()
+	f := &fakeElastic{indices: make(map[string]string)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses: []string{f.server.URL},
+	})
+	if err != nil {
+		t.Fatalf("Error creating the client: %s", err)
+	}
+	return f, client
+}
+
+func (f *fakeElastic) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, fakeRequest{r.Method, r.URL.Path, r.URL.RawQuery, string(body)})
+	handle := f.handle
+	f.mu.Unlock()
+
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	if handle != nil && handle(w, r, string(body)) {
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodHead && len(parts) == 1:
+		if _, ok := f.indices[parts[0]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == http.MethodPut && len(parts) == 1:
+		f.indices[parts[0]] = string(body)
+		_, _ = io.WriteString(w, `{"acknowledged":true}`)
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "_cat" && parts[1] == "indices":
+		var names []string
+		for name := range f.indices {
+			if strings.HasPrefix(name, strings.TrimSuffix(parts[2], "*")) {
+				names = append(names, `{"index":"`+name+`"}`)
+			}
+		}
+		_, _ = io.WriteString(w, "["+strings.Join(names, ",")+"]")
+	case r.Method == http.MethodDelete && len(parts) == 1:
+		if _, ok := f.indices[parts[0]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.indices, parts[0])
+		_, _ = io.WriteString(w, `{"acknowledged":true}`)
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "_close":
+		_, _ = io.WriteString(w, `{"acknowledged":true}`)
+	case len(parts) == 2 && parts[1] == "_doc":
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, `{"_index":"`+parts[0]+`","_id":"1","_version":1,"result":"created","_seq_no":7,"_primary_term":2}`)
+	case len(parts) == 3 && parts[1] == "_doc":
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, `{"_index":"`+parts[0]+`","_id":"`+parts[2]+`","_version":1,"result":"created","_seq_no":7,"_primary_term":2}`)
+	case parts[len(parts)-1] == "_bulk":
+		_, _ = io.WriteString(w, `{"took":1,"errors":false,"items":[]}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":{"type":"not_found","reason":"unexpected request"},"status":404}`)
+	}
+}
+
+// Requests returns the recorded requests matching the method and path.
+// An empty method or path matches anything.
+func (f *fakeElastic) Requests(method, path string) []fakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var res []fakeRequest
+	for _, r := range f.requests {
+		if (method == "" || r.Method == method) && (path == "" || r.Path == path) {
+			res = append(res, r)
+		}
+	}
+	return res
+}
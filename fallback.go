@@ -0,0 +1,16 @@
+package elogrus
+
+import "fmt"
+
+// writeFallback writes the document of an entry that failed to index to the
+// FallbackWriter, followed by a newline, so no entry is lost entirely.
+func (hook *ElasticHook) writeFallback(doc []byte) {
+	line := make([]byte, 0, len(doc)+1)
+	line = append(append(line, doc...), '\n')
+	hook.fallbackMu.Lock()
+	_, err := hook.FallbackWriter.Write(line)
+	hook.fallbackMu.Unlock()
+	if err != nil {
+		hook.handleError(fmt.Errorf("cannot write to the fallback writer: %w", err), doc)
+	}
+}
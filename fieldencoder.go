@@ -0,0 +1,81 @@
+package elogrus
+
+import (
+	"reflect"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fieldEncoder is an encoder registered with SetFieldEncoder for an interface type.
+type fieldEncoder struct {
+	typ    reflect.Type
+	encode func(v interface{}) interface{}
+}
+
+// SetFieldEncoder makes the hook replace the data fields of type T with the
+// value returned by encode, so they are indexed in a form Elasticsearch handles
+// well without wrapping them at every log call, e.g.:
+//
+//	elogrus.SetFieldEncoder(hook, func(d time.Duration) interface{} { return d.Milliseconds() })
+//	elogrus.SetFieldEncoder(hook, func(ip net.IP) interface{} { return ip.String() })
+//	elogrus.SetFieldEncoder(hook, func(m proto.Message) interface{} {
+//		b, _ := protojson.Marshal(m)
+//		return json.RawMessage(b)
+//	})
+//
+// If T is an interface, the encoder applies to the values implementing it
+// whose type has no encoder of its own; the interfaces are tried in the order
+// they were registered. The encoders apply to the top-level data fields and the
+// static fields of the configuration, before they are redacted, renamed and
+// truncated. A nil encode removes the encoder of T.
+func SetFieldEncoder[T any](hook *ElasticHook, encode func(v T) interface{}) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	var enc func(v interface{}) interface{}
+	if encode != nil {
+		enc = func(v interface{}) interface{} { return encode(v.(T)) }
+	}
+	if typ.Kind() == reflect.Interface {
+		encoders := make([]fieldEncoder, 0, len(hook.interfaceEncoders)+1)
+		for _, e := range hook.interfaceEncoders {
+			if e.typ != typ {
+				encoders = append(encoders, e)
+			}
+		}
+		if enc != nil {
+			encoders = append(encoders, fieldEncoder{typ: typ, encode: enc})
+		}
+		hook.interfaceEncoders = encoders
+		return
+	}
+	if enc == nil {
+		delete(hook.fieldEncoders, typ)
+		return
+	}
+	if hook.fieldEncoders == nil {
+		hook.fieldEncoders = map[reflect.Type]func(v interface{}) interface{}{}
+	}
+	hook.fieldEncoders[typ] = enc
+}
+
+// encodeFields replaces the values of data having an encoder, see SetFieldEncoder.
+func (hook *ElasticHook) encodeFields(data logrus.Fields) {
+	if len(hook.fieldEncoders) == 0 && len(hook.interfaceEncoders) == 0 {
+		return
+	}
+	for k, v := range data {
+		if v == nil {
+			continue
+		}
+		t := reflect.TypeOf(v)
+		if enc, ok := hook.fieldEncoders[t]; ok {
+			data[k] = enc(v)
+			continue
+		}
+		for _, e := range hook.interfaceEncoders {
+			if t.Implements(e.typ) {
+				data[k] = e.encode(v)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+type encodedPoint struct{ X, Y int }
+
+func (p encodedPoint) String() string { return fmt.Sprintf("%d,%d", p.X, p.Y) }
+
+func TestSetFieldEncoder(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("encoded-log"),
+		WithFieldEncoder(func(d time.Duration) interface{} { return d.Milliseconds() }),
+		WithFieldEncoder(func(s fmt.Stringer) interface{} { return "stringer:" + s.String() }),
+		WithFieldEncoder(func(ip net.IP) interface{} { return ip.String() }),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	submit := func() map[string]interface{} {
+		t.Helper()
+		doc := NewDocument().SetMessage("encoded").
+			AddField("took", 1500*time.Millisecond).
+			AddField("ip", net.ParseIP("10.0.0.1")).
+			AddField("point", encodedPoint{1, 2}).
+			AddField("count", 3)
+		if err := hook.Submit(doc); err != nil {
+			t.Fatal(err)
+		}
+		reqs := f.Requests(http.MethodPost, "/encoded-log/_doc")
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(reqs[len(reqs)-1].Body), &body); err != nil {
+			t.Fatalf("Invalid document %s: %s", reqs[len(reqs)-1].Body, err)
+		}
+		return body.Data
+	}
+
+	want := map[string]interface{}{"took": float64(1500), "ip": "10.0.0.1", "point": "stringer:1,2", "count": float64(3)}
+	if data := submit(); !reflect.DeepEqual(data, want) {
+		t.Errorf("Expected the fields %v, got %v", want, data)
+	}
+
+	SetFieldEncoder[fmt.Stringer](hook, nil)
+	SetFieldEncoder[time.Duration](hook, nil)
+	want["took"] = float64(1500 * time.Millisecond)
+	want["point"] = map[string]interface{}{"X": float64(1), "Y": float64(2)}
+	if data := submit(); !reflect.DeepEqual(data, want) {
+		t.Errorf("Expected the fields %v once the encoders are removed, got %v", want, data)
+	}
+}
@@ -0,0 +1,113 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldNames renames the built-in fields of the documents in the DefaultFormat,
+// see SetFieldNames. An empty name keeps the default one.
+type FieldNames struct {
+	// Host is the name of the host field, "host" by default.
+	Host string
+	// Message is the name of the message field, "message" by default.
+	Message string
+	// Level is the name of the level field, "level" by default.
+	Level string
+	// Data is the name of the object holding the data fields, "data" by default.
+	Data string
+}
+
+// SetFieldNames renames the built-in fields of the documents in the
+// DefaultFormat, e.g. {Host: "hostname", Message: "msg", Level: "severity"} to
+// write to an existing index without reindexing it. The mappings of the indices
+// created by the hook are not changed, the renamed fields need mappings of their
+// own. See SetTimestampFormat for the timestamp field. Search uses the names too.
+func (hook *ElasticHook) SetFieldNames(names FieldNames) error {
+	renames := map[string]string{}
+	for from, to := range map[string]string{"host": names.Host, "message": names.Message, "level": names.Level, "data": names.Data} {
+		if to != "" && to != from {
+			renames[from] = to
+		}
+	}
+	if len(renames) == 0 {
+		renames = nil
+	}
+	t, err := messageType(renames, hook.timestampFormat.Field)
+	if err != nil {
+		return err
+	}
+	hook.fieldNames, hook.messageType = renames, t
+	return nil
+}
+
+// messageType returns the Message type with the built-in fields renamed and
+// the timestamp field named field, or nil if no field is renamed.
+func messageType(renames map[string]string, field string) (reflect.Type, error) {
+	if len(renames) == 0 && field == "" {
+		return nil, nil
+	}
+	t := reflect.TypeOf(Message{})
+	fields := make([]reflect.StructField, t.NumField())
+	seen := map[string]bool{}
+	for i := range fields {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if to, ok := renames[name]; ok {
+			name = to
+		} else if name == DefaultTimestampField && field != "" {
+			name = field
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate field name %q", name)
+		}
+		seen[name] = true
+		tag := name
+		if opts != "" {
+			tag += "," + opts
+		}
+		f.Tag = reflect.StructTag(fmt.Sprintf("json:%q", tag))
+		fields[i] = f
+	}
+	return reflect.StructOf(fields), nil
+}
+
+// fieldName returns the name of a built-in field of the documents, see SetFieldNames.
+func (hook *ElasticHook) fieldName(name string) string {
+	if to, ok := hook.fieldNames[name]; ok {
+		return to
+	}
+	return name
+}
+
+// renameFields returns a document in the DefaultFormat with the field names of
+// the hook, see SetFieldNames and SetTimestampFormat. The other documents are returned as they are.
+func (hook *ElasticHook) renameFields(v interface{}) interface{} {
+	msg, ok := v.(*Message)
+	if !ok || hook.messageType == nil {
+		return v
+	}
+	return reflect.ValueOf(msg).Elem().Convert(hook.messageType).Interface()
+}
+
+// unmarshalMessage parses a JSON document in the DefaultFormat with the field
+// names of the hook.
+func (hook *ElasticHook) unmarshalMessage(data []byte, msg *Message) error {
+	if hook.messageType == nil {
+		if err := json.Unmarshal(data, msg); err != nil {
+			return err
+		}
+	} else {
+		renamed := reflect.New(hook.messageType)
+		if err := json.Unmarshal(data, renamed.Interface()); err != nil {
+			return err
+		}
+		*msg = renamed.Elem().Convert(reflect.TypeOf(Message{})).Interface().(Message)
+	}
+	if hook.flatFields {
+		return hook.unmarshalFlat(data, msg)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestSetFieldNames(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("field-names-log"),
+		WithHost("web-1"),
+		WithFieldNames(FieldNames{Host: "hostname", Message: "msg", Level: "severity"}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetFieldNames(FieldNames{Message: "level"}); err == nil {
+		t.Error("Expected an error for a duplicate field name")
+	}
+	if err := hook.Submit(NewDocument().SetMessage("renamed").SetLevel(logrus.WarnLevel).AddField("user", "joe")); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := f.Requests(http.MethodPost, "/field-names-log/_doc")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one document, got %d", len(reqs))
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(reqs[0].Body), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range map[string]interface{}{"hostname": "web-1", "msg": "renamed", "severity": "WARNING", "data": map[string]interface{}{"user": "joe"}} {
+		if !reflect.DeepEqual(doc[k], v) {
+			t.Errorf("Expected %s to be %v, got %v", k, v, doc[k])
+		}
+	}
+	for _, k := range []string{"host", "message", "level"} {
+		if _, ok := doc[k]; ok {
+			t.Errorf("Unexpected field %s", k)
+		}
+	}
+
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if !strings.HasSuffix(r.URL.Path, "/_search") {
+			return false
+		}
+		_, _ = io.WriteString(w, `{"hits":{"hits":[{"_id":"1","_source":`+reqs[0].Body+`}]}}`)
+		return true
+	}
+	docs, err := hook.Search(context.Background(), QueryOptions{Levels: []logrus.Level{logrus.WarnLevel}, Message: "renamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Message() != "renamed" || docs[0].Level() != logrus.WarnLevel {
+		t.Errorf("Expected the renamed document, got %+v", docs)
+	}
+	query := f.Requests(http.MethodPost, "/field-names-log/_search")[0].Body
+	if !strings.Contains(query, `"severity":["WARNING"]`) || !strings.Contains(query, `"msg":{`) {
+		t.Errorf("Expected the query to use the renamed fields: %s", query)
+	}
+}
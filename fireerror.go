@@ -0,0 +1,57 @@
+package elogrus
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultErrorsBuffer is the number of failed deliveries the channel returned
+// by Errors holds.
+const DefaultErrorsBuffer = 100
+
+// FireError is a delivery that permanently failed, see Errors.
+type FireError struct {
+	// Entry is a copy of the entry, nil for bulk processor hooks, which keep
+	// only the documents.
+	Entry *logrus.Entry
+	// Document is a copy of the serialized document.
+	Document []byte
+	// Err is the error of the request or the error reported by the cluster.
+	Err error
+}
+
+func (e *FireError) Error() string {
+	return fmt.Sprintf("cannot deliver document: %v", e.Err)
+}
+
+func (e *FireError) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns a channel receiving the deliveries that permanently failed,
+// the same ones as the DeadLetterHandler, e.g. to alert or to log them to
+// stderr. It is meant for the asynchronous and bulk processor hooks, whose Fire
+// cannot return the errors; the failures of a synchronous hook are returned by
+// Fire and received as well. The failures are only sent once Errors is called,
+// and are dropped while the channel is full, so a slow reader never holds up
+// the delivery. The channel is never closed.
+func (hook *ElasticHook) Errors() <-chan FireError {
+	hook.errorsWanted.Store(true)
+	return hook.fireErrors
+}
+
+// sendError sends a failed delivery to the Errors channel if it is read.
+func (hook *ElasticHook) sendError(entry *logrus.Entry, doc []byte, err error) {
+	if !hook.errorsWanted.Load() {
+		return
+	}
+	fe := FireError{Document: append([]byte(nil), doc...), Err: err}
+	if entry != nil {
+		fe.Entry = copyEntry(entry)
+	}
+	select {
+	case hook.fireErrors <- fe:
+	default:
+	}
+}
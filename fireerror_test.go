@@ -0,0 +1,55 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestErrors(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_doc") && strings.Contains(body, "rejected") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":{"type":"mapper_parsing_exception","reason":"failed"}}`)
+			return true
+		}
+		return false
+	}
+	hook, err := NewAsyncElasticHook(client, "localhost", logrus.InfoLevel, "errors-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+	errs := hook.Errors()
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	logger.WithField("user", "joe").Info("rejected")
+	logger.Info("accepted")
+	// the failure is reported by Flush too
+	_ = hook.Flush(context.Background())
+
+	select {
+	case fe := <-errs:
+		var resErr *ResponseError
+		if fe.Entry == nil || fe.Entry.Message != "rejected" || fe.Entry.Data["user"] != "joe" ||
+			!strings.Contains(string(fe.Document), `"message":"rejected"`) || !errors.As(&fe, &resErr) {
+			t.Errorf("Unexpected failed delivery: %+v", fe)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a failed delivery")
+	}
+	select {
+	case fe := <-errs:
+		t.Errorf("Unexpected failed delivery: %+v", fe)
+	default:
+	}
+}
@@ -0,0 +1,109 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// builtinFields are the default names of the built-in fields of the documents
+// in the DefaultFormat, but the data object.
+var builtinFields = []string{"host", DefaultTimestampField, "file", "func", "message", "level", "trace", "span", "schema_version"}
+
+// SetFlatFields makes the hook write the data fields of the entries at the
+// root of the documents in the DefaultFormat instead of under the data object,
+// e.g. for queries and alerts written before the data. prefix. A data field
+// named like a built-in field (see SetFieldNames and SetTimestampFormat) is
+// written with conflictPrefix prepended, e.g. "fields." like the logrus
+// formatters, or dropped if conflictPrefix is empty. The mappings of the
+// indices created by the hook are not changed, the data fields are mapped
+// dynamically. Only JSON documents can be flat.
+func (hook *ElasticHook) SetFlatFields(conflictPrefix string) error {
+	if hook.serializer != nil {
+		return errors.New("only JSON documents can be flat")
+	}
+	hook.flatFields = true
+	hook.conflictPrefix = conflictPrefix
+	return nil
+}
+
+// marshalFlat encodes a document in the DefaultFormat with its data fields at
+// the root, after the built-in fields, see SetFlatFields.
+func (hook *ElasticHook) marshalFlat(msg *Message) ([]byte, error) {
+	base := *msg
+	base.Data = nil
+	data, err := core.Encode(hook.renameFields(&base))
+	if len(msg.Data) == 0 || len(data) < 2 {
+		return data, err
+	}
+	fields := make(logrus.Fields, len(msg.Data))
+	for k, v := range msg.Data {
+		if hook.isBuiltinField(k) {
+			if hook.conflictPrefix == "" {
+				continue
+			}
+			k = hook.conflictPrefix + k
+		}
+		fields[k] = v
+	}
+	flat, ferr := core.Encode(fields)
+	if err == nil {
+		err = ferr
+	}
+	if len(flat) <= 2 {
+		return data, err
+	}
+	// splice the fields into the object of the built-in fields
+	data = append(data[:len(data)-1], ',')
+	return append(data, flat[1:]...), err
+}
+
+// isBuiltinField reports whether key is the name of a built-in field of the
+// documents of the hook.
+func (hook *ElasticHook) isBuiltinField(key string) bool {
+	if key == hook.timestampField() {
+		return true
+	}
+	for _, name := range builtinFields {
+		if name != DefaultTimestampField && key == hook.fieldName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// dataField returns the path of a data field in the documents of the hook.
+func (hook *ElasticHook) dataField(key string) string {
+	if !hook.flatFields {
+		return hook.fieldName("data") + "." + key
+	}
+	if hook.isBuiltinField(key) {
+		return hook.conflictPrefix + key
+	}
+	return key
+}
+
+// unmarshalFlat parses the data fields of a flat JSON document, see SetFlatFields.
+func (hook *ElasticHook) unmarshalFlat(data []byte, msg *Message) error {
+	var fields logrus.Fields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for k, v := range fields {
+		if hook.isBuiltinField(k) {
+			continue
+		}
+		if key := strings.TrimPrefix(k, hook.conflictPrefix); hook.conflictPrefix != "" && key != k && hook.isBuiltinField(key) {
+			k = key
+		}
+		if msg.Data == nil {
+			msg.Data = make(logrus.Fields)
+		}
+		msg.Data[k] = v
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestSetFlatFields(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("flat-log"),
+		WithFieldNames(FieldNames{Level: "severity"}),
+		WithFlatFields("fields."),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	doc := NewDocument().SetMessage("flat").AddField("user", "joe").AddField("message", "clash").AddField("level", 3)
+	if err := hook.Submit(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := f.Requests(http.MethodPost, "/flat-log/_doc")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one document, got %d", len(reqs))
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(reqs[0].Body), &body); err != nil {
+		t.Fatalf("Invalid document %s: %s", reqs[0].Body, err)
+	}
+	for k, v := range map[string]interface{}{"message": "flat", "user": "joe", "fields.message": "clash", "level": float64(3), "severity": "INFO"} {
+		if !reflect.DeepEqual(body[k], v) {
+			t.Errorf("Expected %s to be %v, got %v", k, v, body[k])
+		}
+	}
+	if _, ok := body["data"]; ok {
+		t.Error("Unexpected data object")
+	}
+
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if !strings.HasSuffix(r.URL.Path, "/_search") {
+			return false
+		}
+		_, _ = io.WriteString(w, `{"hits":{"hits":[{"_id":"1","_source":`+reqs[0].Body+`}]}}`)
+		return true
+	}
+	docs, err := hook.Search(context.Background(), QueryOptions{Fields: logrus.Fields{"user": "joe", "message": "clash"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logrus.Fields{"user": "joe", "message": "clash", "level": float64(3)}
+	if len(docs) != 1 || !reflect.DeepEqual(docs[0].Fields(), want) {
+		t.Errorf("Expected the fields %v, got %+v", want, docs)
+	}
+	query := f.Requests(http.MethodPost, "/flat-log/_search")[0].Body
+	if !strings.Contains(query, `{"term":{"user":"joe"}}`) || !strings.Contains(query, `{"term":{"fields.message":"clash"}}`) {
+		t.Errorf("Expected the query to use the flat fields: %s", query)
+	}
+
+	if err := hook.SetFlatFields(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Submit(doc); err != nil {
+		t.Fatal(err)
+	}
+	if body := f.Requests(http.MethodPost, "/flat-log/_doc")[1].Body; strings.Contains(body, "clash") {
+		t.Errorf("Expected the conflicting field to be dropped: %s", body)
+	}
+}
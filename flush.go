@@ -0,0 +1,122 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// ErrHookClosed is returned by Fire once the hook is closed.
+var ErrHookClosed = errors.New("the hook is closed")
+
+// deliveryFailures records the entries the asynchronous and the bulk processor
+// hooks failed to deliver since the last Flush.
+type deliveryFailures struct {
+	mu    sync.Mutex
+	count int
+	first error
+}
+
+// add records n entries that failed with err.
+func (f *deliveryFailures) add(n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.count == 0 {
+		f.first = err
+	}
+	f.count += n
+}
+
+// take returns an error describing the recorded failures, if any, and forgets them.
+func (f *deliveryFailures) take() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.count == 0 {
+		return nil
+	}
+	err := fmt.Errorf("%d entries not delivered: %w", f.count, f.first)
+	f.count, f.first = 0, nil
+	return err
+}
+
+// recordFlush records the documents of a batch that were not written.
+func (hook *ElasticHook) recordFlush(summary core.FlushSummary) {
+	hook.stats.flushed()
+	hook.debugf("flushed %d documents (%d bytes): %d written, %d failed, %d spilled, %d requeued",
+		summary.Documents, summary.Bytes, summary.Succeeded, summary.Documents-summary.Succeeded-summary.Spilled-summary.Requeued,
+		summary.Spilled, summary.Requeued)
+	if summary.Err != nil {
+		hook.failures.add(summary.Documents-summary.Succeeded, summary.Err)
+		return
+	}
+	for reason, n := range summary.Failed {
+		err := fmt.Errorf("documents rejected: %s", reason)
+		hook.failures.add(n, err)
+		hook.stats.setError(err)
+	}
+}
+
+// Flush ships the aggregated entries, sends the entries queued by an
+// asynchronous or bulk processor hook and waits until they are delivered, e.g.
+// before the process exits. It returns an error if any entry could not be
+// delivered since the previous Flush, or the error of the context if it is done
+// first. The batches kept in the spill directory (see SetSpill) count as
+// delivered. The mirror, if any, is flushed too, its failures are not reported.
+func (hook *ElasticHook) Flush(ctx context.Context) error {
+	hook.flushAggregates()
+	if hook.batcher != nil {
+		if err := hook.batcher.Flush(); err != nil {
+			return err
+		}
+	}
+	if hook.mirror != nil {
+		if err := hook.mirror.batcher.Flush(); err != nil {
+			return err
+		}
+	}
+	if hook.batcher != nil {
+		if err := hook.batcher.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if hook.pool != nil {
+		if err := hook.pool.pending.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if hook.mirror != nil {
+		if err := hook.mirror.batcher.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	hook.stats.flushed()
+	return hook.failures.take()
+}
+
+// Close flushes the hook (see Flush) and stops it: its goroutines exit, the spill
+// directory is closed and Fire returns ErrHookClosed from then on. The hook is stopped even if the flush fails.
+// The hook must be removed from the loggers or they must stop logging first, the
+// entries fired during Close may be lost.
+func (hook *ElasticHook) Close(ctx context.Context) error {
+	if !hook.closed.CompareAndSwap(false, true) {
+		return ErrHookClosed
+	}
+	err := hook.Flush(ctx)
+	if hook.batcher != nil {
+		_ = hook.batcher.Close()
+	}
+	if hook.mirror != nil {
+		_ = hook.mirror.batcher.Close()
+	}
+	hook.Cancel()
+	if hook.spiller != nil {
+		hook.closeSpill()
+	}
+	if hook.account != nil {
+		hook.account.Close()
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestFlush(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "flush-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+	if err := hook.Reconfigure(Config{Level: logrus.InfoLevel, FlushInterval: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := hook.Submit(NewDocument().SetMessage("entry")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hook.Flush(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if reqs := f.Requests(http.MethodPost, "/flush-log/_bulk"); len(reqs) != 1 || strings.Count(reqs[0].Body, "\n") != 6 {
+		t.Errorf("Expected the entries to be delivered when Flush returns, got %+v", reqs)
+	}
+}
+
+func TestFlushErrors(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_doc") && strings.Contains(body, "rejected") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":{"type":"mapper_parsing_exception","reason":"failed"}}`)
+			return true
+		}
+		return false
+	}
+	hook, err := NewAsyncElasticHook(client, "localhost", logrus.InfoLevel, "flush-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+
+	if err := hook.Submit(NewDocument().SetMessage("rejected")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("accepted")); err != nil {
+		t.Fatal(err)
+	}
+	var e *ResponseError
+	if err := hook.Flush(context.Background()); !errors.As(err, &e) || !strings.Contains(err.Error(), "1 entries not delivered") {
+		t.Errorf("Expected the delivery error, got %v", err)
+	}
+	if n := len(f.Requests(http.MethodPost, "/flush-log/_doc")); n != 2 {
+		t.Errorf("Expected the entries to be delivered when Flush returns, got %d", n)
+	}
+	if err := hook.Flush(context.Background()); err != nil {
+		t.Errorf("Expected the errors to be reported once, got %v", err)
+	}
+}
+
+func TestFlushTimeout(t *testing.T) {
+	f, client := newFakeElastic(t)
+	release := make(chan struct{})
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_bulk") {
+			<-release
+		}
+		return false
+	}
+	defer close(release)
+	hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "flush-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+
+	if err := hook.Submit(NewDocument().SetMessage("entry")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := hook.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewAsyncElasticHook(client, "localhost", logrus.InfoLevel, "close-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+
+	if err := hook.Submit(NewDocument().SetMessage("entry")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Close(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if n := len(f.Requests(http.MethodPost, "/close-log/_doc")); n != 1 {
+		t.Errorf("Expected the entry to be delivered when Close returns, got %d", n)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("late")); !errors.Is(err, ErrHookClosed) {
+		t.Errorf("Expected ErrHookClosed, got %v", err)
+	}
+	if err := hook.Close(context.Background()); !errors.Is(err, ErrHookClosed) {
+		t.Errorf("Expected ErrHookClosed, got %v", err)
+	}
+}
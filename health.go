@@ -0,0 +1,106 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// ErrIndexNotFound is returned by Ping when the index the hook writes to does
+// not exist and is managed externally, e.g. a data stream without an index
+// template.
+var ErrIndexNotFound = errors.New("index not found")
+
+// HealthFunc receives the result of a health probe whenever it changes, nil
+// once the cluster is healthy again, see SetHealthCheck.
+type HealthFunc func(err error)
+
+// healthCheck holds the result of the last health probe, see SetHealthCheck.
+type healthCheck struct {
+	mu      sync.Mutex
+	err     error
+	checked bool
+}
+
+// set records the result of a probe and reports whether the health changed.
+func (h *healthCheck) set(err error) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	changed := !h.checked || (err == nil) != (h.err == nil)
+	h.err, h.checked = err, true
+	return changed
+}
+
+// Ping checks that the cluster is reachable and that the index the hook writes
+// to is available, e.g. for the readiness checks of an orchestrator. An index
+// that does not exist is reported with ErrIndexNotFound if it is managed
+// externally, the other ones are created by the hook with the next entry.
+func (hook *ElasticHook) Ping(ctx context.Context) error {
+	index := hook.index()
+	exists, err := hook.client.IndexExists(ctx, index)
+	if err != nil {
+		return fmt.Errorf("cannot reach the cluster: %w", err)
+	}
+	if !exists && hook.externalIndices {
+		return fmt.Errorf("%w: %s", ErrIndexNotFound, index)
+	}
+	return nil
+}
+
+// SetHealthCheck makes the hook Ping the cluster in the background every
+// interval, until it is closed. onChange, if not nil, is called with the result
+// of the first probe and whenever the cluster becomes unhealthy or healthy
+// again, see Health for the result of the last probe. The probes are bound by
+// the request timeout of the hook, see SetRequestTimeout.
+func (hook *ElasticHook) SetHealthCheck(interval time.Duration, onChange HealthFunc) error {
+	if interval <= 0 {
+		return errors.New("health check interval must be positive")
+	}
+	if hook.health != nil {
+		return errors.New("the hook already has a health check")
+	}
+	h := &healthCheck{}
+	hook.health = h
+	go func() {
+		pprof.SetGoroutineLabels(hook.labels)
+		for {
+			ctx, cancel := hook.requestContextFrom(hook.ctx)
+			err := hook.Ping(ctx)
+			cancel()
+			if hook.ctx.Err() != nil {
+				return
+			}
+			if h.set(err) {
+				if err != nil {
+					hook.debugf("health check failed: %v", err)
+				} else {
+					hook.debugf("health check passed")
+				}
+				if onChange != nil {
+					onChange(err)
+				}
+			}
+			select {
+			case <-hook.ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+	return nil
+}
+
+// Health returns the result of the last health probe of SetHealthCheck, nil
+// before the first one or without a health check.
+func (hook *ElasticHook) Health() error {
+	h := hook.health
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
@@ -0,0 +1,104 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestPing(t *testing.T) {
+	f, client := newFakeElastic(t)
+	var down atomic.Bool
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.Method == http.MethodHead && down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		return false
+	}
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "ping-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.Ping(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	down.Store(true)
+	if err := hook.Ping(context.Background()); err == nil {
+		t.Error("Expected an error for an unreachable cluster")
+	}
+	down.Store(false)
+
+	stream, err := NewElasticHookWithOptions(es8.New(client), WithDataStream("logs-ping-default"))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := stream.Ping(context.Background()); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("Expected ErrIndexNotFound, got %v", err)
+	}
+}
+
+func TestSetHealthCheck(t *testing.T) {
+	f, client := newFakeElastic(t)
+	var down atomic.Bool
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.Method == http.MethodHead && down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		return false
+	}
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "health-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+	var mu sync.Mutex
+	var changes []error
+	changed := make(chan struct{}, 10)
+	err = hook.SetHealthCheck(5*time.Millisecond, func(err error) {
+		mu.Lock()
+		changes = append(changes, err)
+		mu.Unlock()
+		changed <- struct{}{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.SetHealthCheck(time.Millisecond, nil); err == nil {
+		t.Error("Expected an error for a second health check")
+	}
+
+	wait := func() {
+		select {
+		case <-changed:
+		case <-time.After(time.Second):
+			t.Fatal("Expected the health to change")
+		}
+	}
+	wait()
+	down.Store(true)
+	wait()
+	if hook.Health() == nil {
+		t.Error("Expected the cluster to be unhealthy")
+	}
+	down.Store(false)
+	wait()
+	if err := hook.Health(); err != nil {
+		t.Errorf("Unexpected health: %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(changes) != 3 || changes[0] != nil || changes[1] == nil || changes[2] != nil {
+		t.Errorf("Unexpected health changes: %v", changes)
+	}
+}
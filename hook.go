@@ -4,60 +4,231 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
-	"strings"
+	"io"
+	"reflect"
+	"runtime/pprof"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
-	"github.com/elastic/go-elasticsearch/v8/esapi"
 	"github.com/sirupsen/logrus"
 
-	"gopkg.in/go-extras/elogrus.v8/internal/bulk"
+	"gopkg.in/go-extras/elogrus.v8/core"
+	"gopkg.in/go-extras/elogrus.v8/es8"
 )
 
 var (
 	// ErrCannotCreateIndex Fired if the index is not created
 	ErrCannotCreateIndex = fmt.Errorf("cannot create index")
+	// ErrDocumentTooLarge Fired if the document of an entry exceeds the MaxDocumentSize
+	ErrDocumentTooLarge = fmt.Errorf("document too large")
 )
 
+// ResponseError is an error response returned by Elasticsearch.
+type ResponseError = core.ResponseError
+
 // IndexNameFunc get index name
 type IndexNameFunc func() string
 
 type fireFunc func(entry *logrus.Entry, hook *ElasticHook) error
 
+// ErrorHandlerFunc receives errors the hook cannot return from Fire,
+// together with the affected document or batch, which belongs to the handler.
+type ErrorHandlerFunc func(err error, data []byte)
+
+// IndexResponse is the response of the cluster to a written document.
+type IndexResponse = core.IndexResponse
+
+// WriteHandlerFunc receives the entries written by a synchronous hook together
+// with the id, sequence number and primary term of their documents.
+type WriteHandlerFunc func(entry *logrus.Entry, res *IndexResponse)
+
+// FlushSummary describes the outcome of a batch sent by a bulk processor hook.
+type FlushSummary = core.FlushSummary
+
+// FlushHandlerFunc receives the summary of every batch sent by a bulk processor hook.
+type FlushHandlerFunc func(summary FlushSummary)
+
+// DeadLetterFunc receives the entries that could not be delivered, once the
+// retries are exhausted, together with their document and the error, e.g. to
+// write them to a file or a queue. The entry is nil for bulk processor hooks,
+// which keep only the documents. The document belongs to the handler, it can be
+// kept after the call returns.
+type DeadLetterFunc func(entry *logrus.Entry, doc []byte, err error)
+
+// OversizedHandlerFunc receives entries whose documents are too large to be
+// shipped, together with the serialized document.
+type OversizedHandlerFunc func(entry *logrus.Entry, data []byte)
+
+// EncodeError is reported to the ErrorHandler when an entry could not be
+// encoded as is and some of its values were replaced with placeholders.
+// The entry itself is still delivered.
+type EncodeError = core.EncodeError
+
 // ModifyMessageFunc is a function that can be used to generate the object sent to elasticsearch.
 // The output value should be useable by json.Marshal
 type ModifyMessageFunc func(entry *logrus.Entry, message *Message) interface{}
 
 // ElasticHook is a logrus
 // hook for ElasticSearch
+//
+// The Set methods of the hook, and the SetDocumentType and SetFieldEncoder
+// functions, are not safe for concurrent use: call them before adding the hook
+// to a logger, or use the Options of NewElasticHookWithOptions. Reconfigure
+// changes the settings of a hook in use.
 type ElasticHook struct {
-	client    *elasticsearch.Client
+	client    core.Client
 	host      string
 	index     IndexNameFunc
-	levels    []logrus.Level
+	rotation  *IndexRotation
 	ctx       context.Context
 	ctxCancel context.CancelFunc
-	fireFunc  fireFunc
+	// labels holds the pprof labels of the background goroutines of the hook.
+	labels   context.Context
+	fireFunc fireFunc
+
+	schemaVersion int
+	config        atomic.Pointer[Config]
+	pool          *workerPool
+	batcher       *core.Batcher
+	limiter       *core.Limiter
+	rateLimiter   *core.RateLimiter
+	account       *core.BudgetAccount
+	mirror        *mirror
+	spiller       *spiller
+	levelLabels   map[logrus.Level]string
+	errors        errorSampler
+	aggregator    aggregator
+	serializer    Serializer // JSON if nil
+
+	// indexCache holds the indices known to exist, see ensureIndex
+	indexCache *IndexCache
+
+	// requestTimeout bounds the requests delivering entries if positive
+	requestTimeout time.Duration
+	// retryPolicy is used by the synchronous hook, see SetRetryPolicy
+	retryPolicy RetryPolicy
+	// breaker fails the deliveries fast while the cluster is unhealthy, see SetCircuitBreaker
+	breaker *core.Breaker
+
+	// failures holds the entries not delivered since the last Flush
+	failures deliveryFailures
+	closed   atomic.Bool
+	// health holds the result of the last health probe, see SetHealthCheck
+	health *healthCheck
+	// fireErrors receives the failed deliveries once errorsWanted, see Errors
+	fireErrors   chan FireError
+	errorsWanted atomic.Bool
+
+	// documentFormat is the layout of the documents, see SetDocumentFormat
+	documentFormat DocumentFormat
+	// dataStream is set if the hook writes to a data stream, see WithDataStream
+	dataStream bool
+	// externalIndices is set if the hook neither checks nor creates its
+	// indices, see WithExternalIndices
+	externalIndices bool
+	// indexBody is the body of the requests creating indices, see SetIndexBody
+	indexBody map[string]interface{}
+
+	// documentFunc, if set, creates the documents instead of createMessage,
+	// see SetDocumentType
+	documentFunc func(entry *logrus.Entry) interface{}
+
+	// idFunc, if set, returns the _id of the documents, see SetIDFunc
+	idFunc IDFunc
+	// routingFunc, if set, returns the routing of the documents, see SetRouting
+	routingFunc RoutingFunc
+	// levelIndex, if set, returns the index of the entries of a level, see SetLevelIndex
+	levelIndex LevelIndexFunc
+	// fieldRenames maps the data fields to their names in the documents, see SetFieldRenames
+	fieldRenames map[string]string
+	// fieldNames maps the built-in fields to their names in the documents and
+	// messageType is the Message type with these names and the timestamp
+	// field, see SetFieldNames and SetTimestampFormat
+	fieldNames  map[string]string
+	messageType reflect.Type
+	// flatFields writes the data fields at the root of the documents, the ones
+	// named like a built-in field with conflictPrefix, see SetFlatFields
+	flatFields     bool
+	conflictPrefix string
+	// timestampFormat defines the timestamp field of the documents, see SetTimestampFormat
+	timestampFormat TimestampFormat
+	// callerFields are the names of the caller data fields, see SetCallerFields
+	callerFields CallerFields
+	// fieldEncoders and interfaceEncoders replace the data fields of some
+	// types, see SetFieldEncoder
+	fieldEncoders     map[reflect.Type]func(v interface{}) interface{}
+	interfaceEncoders []fieldEncoder
+	// dedotSeparator, if set, replaces the dots of the data fields, see SetDedotKeys
+	dedotSeparator string
+	// expandKeys nests the values of the dotted data fields, see SetExpandKeys
+	expandKeys bool
+	// redactMatch, if set, matches the redacted data fields, see SetRedaction
+	redactMatch FieldMatcher
+	redactMode  RedactionMode
+
+	// metrics receives the measurements of the hook, it is stats
+	metrics Metrics
+	// tracer, if set, traces the requests sending documents, see SetTracer
+	tracer Tracer
+	// debugLogger, if set, receives the diagnostics of the hook, see SetDebugLogger
+	debugLogger DebugLogger
+	// stats records the statistics of the hook and passes the measurements on
+	// to the metrics set with SetMetrics, see Stats
+	stats hookStats
 
 	// MessageModifierFunc is a function that can be called to create a
 	// custom object to send to Elasticsearch for setting root fields
 	// like "trace.id" or customizing other parts of the message
 	MessageModifierFunc ModifyMessageFunc
-}
 
-type Message struct {
-	Host      string        `json:"host,omitempty"`
-	Timestamp string        `json:"@timestamp"`
-	File      string        `json:"file,omitempty"`
-	Func      string        `json:"func,omitempty"`
-	Message   string        `json:"message,omitempty"`
-	Data      logrus.Fields `json:"data,omitempty"`
-	Level     string        `json:"level,omitempty"`
+	// UseLoggerFormatter makes the hook serialize entries with the formatter of
+	// their logger (e.g. a logrus.JSONFormatter with its FieldMap, TimestampFormat
+	// and DataKey settings), so the documents match the locally printed JSON.
+	// The formatter must produce JSON objects, the default document is sent
+	// (and the error reported) otherwise. Entries without a logger, e.g. the
+	// submitted documents, always use the default document.
+	UseLoggerFormatter bool
+
+	// OversizedHandler, if set, is called with the entries whose documents
+	// exceed the MaxDocumentSize of the configuration
+	OversizedHandler OversizedHandlerFunc
+
+	// WriteHandler, if set, is called by synchronous hooks with every entry
+	// written and the response of the cluster, e.g. for proof-of-write records
+	WriteHandler WriteHandlerFunc
+
+	// FlushHandler, if set, is called by bulk processor hooks with the summary
+	// of every batch, e.g. for delivery SLO accounting
+	FlushHandler FlushHandlerFunc
+
+	// DeadLetterHandler, if set, is called with the entries that permanently
+	// failed to index. The entries dropped before delivery, e.g. oversized or
+	// above the queue limit, and the copies of the mirror are not passed to it.
+	DeadLetterHandler DeadLetterFunc
+
+	// FallbackWriter, if set, receives the documents of the entries that
+	// permanently failed to index, one per line, e.g. os.Stderr or a local file
+	FallbackWriter io.Writer
+	// fallbackMu serializes the writes to the FallbackWriter
+	fallbackMu sync.Mutex
+
+	// IndexEventHandler, if set, is called when the hook creates, rolls over or
+	// recreates an index. The index created by the constructor is not reported.
+	IndexEventHandler IndexEventHandlerFunc
+
+	// ErrorHandler, if set, is called with problems the hook cannot return
+	// from Fire, e.g. values replaced while encoding an entry or batches the
+	// bulk processor could not send
+	ErrorHandler ErrorHandlerFunc
 }
 
+// Message is the document created for a log entry by default.
+type Message = core.Message
+
 // NewElasticHook creates new hook.
 // client - ElasticSearch client with specific es version (v5/v6/v7/...)
 // host - host of system
@@ -93,7 +264,7 @@ func NewBulkProcessorElasticHook(client *elasticsearch.Client, host string, leve
 // level - log level
 // indexFunc - function providing the name of index
 func NewElasticHookWithFunc(client *elasticsearch.Client, host string, level logrus.Level, indexFunc IndexNameFunc) (*ElasticHook, error) {
-	return newHookFuncAndFireFunc(client, host, level, indexFunc, syncFireFunc)
+	return NewElasticHookWithClient(es8.New(client), host, level, indexFunc)
 }
 
 // NewAsyncElasticHookWithFunc creates new asynchronous hook with
@@ -104,7 +275,7 @@ func NewElasticHookWithFunc(client *elasticsearch.Client, host string, level log
 // level - log level
 // indexFunc - function providing the name of index
 func NewAsyncElasticHookWithFunc(client *elasticsearch.Client, host string, level logrus.Level, indexFunc IndexNameFunc) (*ElasticHook, error) {
-	return newHookFuncAndFireFunc(client, host, level, indexFunc, asyncFireFunc)
+	return NewAsyncElasticHookWithClient(es8.New(client), host, level, indexFunc)
 }
 
 // NewBulkProcessorElasticHookWithFunc creates new hook with
@@ -116,77 +287,206 @@ func NewAsyncElasticHookWithFunc(client *elasticsearch.Client, host string, leve
 // level - log level
 // indexFunc - function providing the name of index
 func NewBulkProcessorElasticHookWithFunc(client *elasticsearch.Client, host string, level logrus.Level, indexFunc IndexNameFunc) (*ElasticHook, error) {
-	fireFunc, err := makeBulkFireFunc(client)
-	if err != nil {
-		return nil, err
-	}
-	return newHookFuncAndFireFunc(client, host, level, indexFunc, fireFunc)
+	return NewBulkProcessorElasticHookWithClient(es8.New(client), host, level, indexFunc)
+}
+
+// NewElasticHookWithClient creates new hook using a client for
+// a specific cluster version (see the es6, es7, es8 and opensearch packages).
+// client - cluster client
+// host - host of system
+// level - log level
+// indexFunc - function providing the name of index
+func NewElasticHookWithClient(client core.Client, host string, level logrus.Level, indexFunc IndexNameFunc) (*ElasticHook, error) {
+	return newHook(client, &hookOptions{mode: SyncDelivery, host: host, indexFunc: indexFunc, config: Config{Level: level}})
+}
+
+// NewAsyncElasticHookWithClient creates new asynchronous hook using a client for
+// a specific cluster version (see the es6, es7, es8 and opensearch packages).
+// client - cluster client
+// host - host of system
+// level - log level
+// indexFunc - function providing the name of index
+func NewAsyncElasticHookWithClient(client core.Client, host string, level logrus.Level, indexFunc IndexNameFunc) (*ElasticHook, error) {
+	return newHook(client, &hookOptions{mode: AsyncDelivery, host: host, indexFunc: indexFunc, config: Config{Level: level}})
+}
+
+// NewBulkProcessorElasticHookWithClient creates new hook that uses a bulk processor
+// for indexing using a client for a specific cluster version (see the es6, es7, es8
+// and opensearch packages).
+// client - cluster client
+// host - host of system
+// level - log level
+// indexFunc - function providing the name of index
+func NewBulkProcessorElasticHookWithClient(client core.Client, host string, level logrus.Level, indexFunc IndexNameFunc) (*ElasticHook, error) {
+	return newHook(client, &hookOptions{mode: BulkDelivery, host: host, indexFunc: indexFunc, config: Config{Level: level}})
 }
 
-func newHookFuncAndFireFunc(client *elasticsearch.Client, host string, level logrus.Level, indexFunc IndexNameFunc, fireFunc fireFunc) (*ElasticHook, error) {
-	var levels []logrus.Level
-	for _, l := range []logrus.Level{
-		logrus.PanicLevel,
-		logrus.FatalLevel,
-		logrus.ErrorLevel,
-		logrus.WarnLevel,
-		logrus.InfoLevel,
-		logrus.DebugLevel,
-		logrus.TraceLevel,
-	} {
-		if l <= level {
-			levels = append(levels, l)
+// newHook creates a hook delivering the documents in the mode of the options,
+// the index is created with the mappings of the format if it does not exist,
+// unless the hook writes to a data stream. Only the settings of the options
+// needed by the constructor are applied, see hookOptions.apply for the others.
+func newHook(client core.Client, o *hookOptions) (*ElasticHook, error) {
+	switch o.mode {
+	case SyncDelivery:
+		return newHookFuncAndFireFunc(client, o, syncFireFunc)
+	case AsyncDelivery:
+		hook, err := newHookFuncAndFireFunc(client, o, asyncFireFunc)
+		if err != nil {
+			return nil, err
+		}
+		hook.pool = newWorkerPool()
+		return hook, nil
+	case BulkDelivery:
+		hook, err := newHookFuncAndFireFunc(client, o, bulkFireFunc)
+		if err != nil {
+			return nil, err
 		}
+		// The goroutines of the bulk processor inherit the labels.
+		pprof.Do(hook.labels, pprof.Labels(), func(context.Context) {
+			hook.batcher = newBatcher(hook)
+		})
+		return hook, nil
 	}
+	return nil, fmt.Errorf("invalid delivery mode: %s", o.mode)
+}
 
+func newHookFuncAndFireFunc(client core.Client, o *hookOptions, fireFunc fireFunc) (*ElasticHook, error) {
 	ctx, cancel := context.WithCancel(context.TODO())
-
-	// Use the IndexExists service to check if a specified index exists.
-	indexExistsResp, err := client.Indices.Exists([]string{indexFunc()})
-	if err != nil {
-		// Handle error
-		cancel()
-		return nil, err
+	index := o.indexFunc()
+	hook := &ElasticHook{
+		client:        client,
+		host:          o.host,
+		index:         o.indexFunc,
+		ctx:           ctx,
+		ctxCancel:     cancel,
+		fireFunc:      fireFunc,
+		schemaVersion: DefaultSchemaVersion,
+		labels:        pprof.WithLabels(context.Background(), pprof.Labels("elogrus.index", index, "elogrus.mode", o.mode.String())),
 	}
-	if indexExistsResp.StatusCode == http.StatusNotFound {
-		createIndexResp, err := client.Indices.Create(indexFunc())
-		if err != nil || createIndexResp.IsError() {
+	hook.stats.Metrics = NopMetrics{}
+	hook.metrics = &hook.stats
+	hook.documentFormat = o.format
+	hook.dataStream = o.dataStream
+	hook.externalIndices = o.externalIndices || o.dataStream
+	hook.indexBody = o.indexBody
+	hook.debugLogger = o.debugLogger
+	hook.indexCache = NewIndexCache(DefaultIndexCacheTTL)
+	hook.fireErrors = make(chan FireError, DefaultErrorsBuffer)
+	hook.config.Store(&Config{
+		Level:         o.config.Level,
+		FlushInterval: DefaultFlushInterval,
+	})
+
+	// Check if the index exists and create it otherwise, unless it is managed
+	// externally, e.g. a data stream created by its index template.
+	if !hook.externalIndices {
+		exists, err := client.IndexExists(ctx, index)
+		if err != nil {
 			cancel()
-			return nil, ErrCannotCreateIndex
+			return nil, err
+		}
+		if !exists {
+			if err := hook.createIndex(ctx, index); err != nil {
+				hook.debugf("cannot create index %s: %v", index, err)
+				cancel()
+				return nil, ErrCannotCreateIndex
+			}
+			hook.debugf("created index %s", index)
 		}
 	}
-
-	return &ElasticHook{
-		client:    client,
-		host:      host,
-		index:     indexFunc,
-		levels:    levels,
-		ctx:       ctx,
-		ctxCancel: cancel,
-		fireFunc:  fireFunc,
-	}, nil
+	hook.indexCache.add(index)
+	return hook, nil
 }
 
 // Fire is required to implement
 // Logrus hook
 func (hook *ElasticHook) Fire(entry *logrus.Entry) error {
+	if hook.closed.Load() {
+		return ErrHookClosed
+	}
+	if isDebugEntry(entry) {
+		return nil
+	}
+	cfg := hook.config.Load()
+	if !cfg.enabled(entry) {
+		return nil
+	}
+	if entry = hook.sampleErrors(cfg, entry); entry == nil || hook.aggregate(cfg, entry) {
+		return nil
+	}
+	if hook.rateLimiter != nil {
+		if err := hook.rateLimiter.Wait(hook.ctx); err != nil {
+			hook.metrics.DocumentsDropped(1)
+			return err
+		}
+	}
+	hook.metrics.EntryFired()
 	return hook.fireFunc(entry, hook)
 }
 
 func asyncFireFunc(entry *logrus.Entry, hook *ElasticHook) error {
-	e := *entry
-	go func() {
-		_ = syncFireFunc(&e, hook) // TODO: return channel with error
-	}()
+	data, err := hook.encode(entry)
+	if err != nil {
+		return err
+	}
+	meta := hook.documentMeta(entry)
+	hook.mirrorDocument(meta, data)
+	if hook.limiter != nil {
+		if err := hook.limiter.Acquire(); err != nil {
+			hook.metrics.DocumentsDropped(1)
+			return err
+		}
+	}
+	if hook.account != nil {
+		if err := hook.account.Reserve(len(data)); err != nil {
+			if hook.limiter != nil {
+				hook.limiter.Release(1)
+			}
+			hook.metrics.DocumentsDropped(1)
+			return err
+		}
+	}
+	job := asyncJob{t: entry.Time, index: hook.entryIndex(entry), meta: meta, data: data}
+	if hook.DeadLetterHandler != nil || hook.errorsWanted.Load() {
+		// The entry is reused by the logger once Fire returns, keep a copy.
+		job.entry = copyEntry(entry)
+	}
+	if err := hook.enqueue(job); err != nil {
+		hook.release(len(data))
+		hook.metrics.DocumentsDropped(1)
+		return err
+	}
 	return nil
 }
 
-func createMessage(entry *logrus.Entry, hook *ElasticHook) interface{} {
-	level := entry.Level.String()
+// createMessage creates the document of an entry. pooled, if not nil, is the
+// message of the document taken from the pool, to be returned with putMessage
+// once the document is serialized: the messages passed to the
+// MessageModifierFunc may be retained and are never pooled.
+func createMessage(entry *logrus.Entry, hook *ElasticHook) (doc interface{}, pooled *Message) {
+	if hook.documentFunc != nil {
+		return hook.documentFunc(entry), nil
+	}
+	if hook.documentFormat == ECSFormat {
+		return hook.createECSMessage(entry), nil
+	}
 
-	if e, ok := entry.Data[logrus.ErrorKey]; ok && e != nil {
+	// read once, the field may be changed at any time
+	modify := hook.MessageModifierFunc
+	var msg *Message
+	if modify == nil {
+		msg = getMessage()
+		pooled = msg
+	} else {
+		msg = &Message{}
+	}
+	data := hook.fillEntryData(entry, msg.Data)
+	if hook.expandKeys {
+		expandKeys(data)
+	}
+	if e, ok := data[logrus.ErrorKey]; ok && e != nil {
 		if err, ok := e.(error); ok {
-			entry.Data[logrus.ErrorKey] = err.Error()
+			data[logrus.ErrorKey] = err.Error()
 		}
 	}
 
@@ -197,111 +497,272 @@ func createMessage(entry *logrus.Entry, hook *ElasticHook) interface{} {
 		function = entry.Caller.Function
 	}
 
-	msg := &Message{
-		hook.host,
-		entry.Time.UTC().Format(time.RFC3339Nano),
-		file,
-		function,
-		entry.Message,
-		entry.Data,
-		strings.ToUpper(level),
+	*msg = Message{
+		Host:          hook.host,
+		Timestamp:     hook.formatTime(entry.Time),
+		File:          file,
+		Func:          function,
+		Message:       hook.entryMessage(entry, data),
+		Data:          data,
+		Level:         hook.levelLabel(entry.Level),
+		SchemaVersion: hook.schemaVersion,
 	}
+	msg.Trace, msg.Span = entryTrace(entry)
 
-	if hook.MessageModifierFunc != nil {
-		return hook.MessageModifierFunc(entry, msg)
+	if modify != nil {
+		return modify(entry, msg), nil
 	}
 
-	return msg
+	return msg, pooled
 }
 
-func syncFireFunc(entry *logrus.Entry, hook *ElasticHook) error {
-	data, err := json.Marshal(createMessage(entry, hook))
-	if err != nil {
-		return err
+// entryData returns a copy of the fields of the entry merged with the static
+// fields of the hook and the caller fields of SetCallerFields, redacted and
+// renamed according to SetRedaction and SetFieldRenames, de-dotted according to
+// SetDedotKeys and truncated to the MaxFieldLength. The values with a pointer
+// MarshalJSON method are replaced with pointers, see addressMarshalers. The entry is shared with
+// other hooks and the formatter of the logger, so it must never be modified.
+func (hook *ElasticHook) entryData(entry *logrus.Entry) logrus.Fields {
+	return hook.fillEntryData(entry, nil)
+}
+
+// fillEntryData is entryData filling the empty map data, e.g. one of a pooled
+// message, or a new map if data is nil.
+func (hook *ElasticHook) fillEntryData(entry *logrus.Entry, data logrus.Fields) logrus.Fields {
+	var static logrus.Fields
+	maxLength := 0
+	if cfg := hook.config.Load(); cfg != nil {
+		static = cfg.Fields
+		maxLength = cfg.MaxFieldLength
+	}
+	if data == nil {
+		data = make(logrus.Fields, len(static)+len(entry.Data))
+	}
+	for k, v := range static {
+		data[k] = v
+	}
+	for k, v := range entry.Data {
+		data[k] = v
+	}
+	hook.addCaller(entry, data)
+	delete(data, IndexKey)
+	hook.encodeFields(data)
+	hook.redact(data)
+	if len(hook.fieldRenames) > 0 {
+		// rename from the original fields, so the order of the renames does not matter
+		renamed := make(logrus.Fields)
+		for from, to := range hook.fieldRenames {
+			if v, ok := data[from]; ok {
+				renamed[to] = v
+			}
+		}
+		for from := range hook.fieldRenames {
+			delete(data, from)
+		}
+		for k, v := range renamed {
+			data[k] = v
+		}
 	}
-	req := esapi.IndexRequest{
-		Index: hook.index(),
-		Body:  bytes.NewReader(data),
+	if hook.dedotSeparator != "" {
+		dedotKeys(data, hook.dedotSeparator)
 	}
+	addressMarshalers(data)
+	truncateFields(data, maxLength)
+	return data
+}
 
-	// Perform the request with the client.
-	res, err := req.Do(context.Background(), hook.client)
+// format serializes the entry with the formatter of its logger, see UseLoggerFormatter.
+func (hook *ElasticHook) format(entry *logrus.Entry) ([]byte, error) {
+	e := *entry
+	e.Data = hook.entryData(entry)
+	if hook.expandKeys {
+		expandKeys(e.Data)
+	}
+	e.Message = hook.entryMessage(entry, e.Data)
+	e.Buffer = nil
+	formatted, err := entry.Logger.Formatter.Format(&e)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer res.Body.Close()
-
-	return err
+	// Bulk requests need a document per line, so get rid of any pretty printing.
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			buf.Reset()
+			bufferPool.Put(buf)
+		}
+	}()
+	if err := json.Compact(buf, formatted); err != nil {
+		return nil, fmt.Errorf("formatter output is not JSON: %w", err)
+	}
+	if buf.Len() == 0 || buf.Bytes()[0] != '{' {
+		return nil, fmt.Errorf("formatter output is not a JSON object: %s", buf.Bytes())
+	}
+	return append([]byte(nil), buf.Bytes()...), nil
 }
 
-// Create closure with bulk processor tied to fireFunc.
-// Note: garbage collector will never be able to free memory allocated for bulkWriters
-func makeBulkFireFunc(client *elasticsearch.Client) (fireFunc, error) {
-	bulkWriters := make(map[*ElasticHook]*bulk.Writer)
-	var lock sync.RWMutex
-
-	getWriter := func(hook *ElasticHook) *bulk.Writer {
-		lock.RLock()
-		writer := bulkWriters[hook]
-		lock.RUnlock()
-		if writer != nil {
-			return writer
+// encode serializes the document for the entry and checks its size. Documents
+// larger than the MaxDocumentSize are passed to the OversizedHandler and either
+// replaced with a summary or rejected with ErrDocumentTooLarge.
+func (hook *ElasticHook) encode(entry *logrus.Entry) ([]byte, error) {
+	data, err := hook.serialize(entry)
+	if err != nil {
+		if errors.Is(err, ErrNoTimestamp) {
+			hook.metrics.DocumentsDropped(1)
 		}
-		lock.Lock()
-		writer = bulkWriters[hook]
-		if writer != nil { // this is a second check to avoid sequential writes
-			lock.Unlock()
-			return writer
+		return nil, err
+	}
+	cfg := hook.config.Load()
+	if cfg.MaxDocumentSize <= 0 || len(data) <= cfg.MaxDocumentSize {
+		return data, nil
+	}
+	if hook.OversizedHandler != nil {
+		hook.OversizedHandler(entry, data)
+	}
+	if cfg.SummarizeOversized {
+		if summary := hook.summarize(entry, len(data), cfg.MaxDocumentSize); summary != nil {
+			return summary, nil
 		}
+	}
+	hook.metrics.DocumentsDropped(1)
+	return nil, ErrDocumentTooLarge
+}
 
-		// long path, create a new writer
-		writer = bulk.NewBulkWriterWithErrorHandler(time.Second, func(data []byte) error {
-			res, err := client.Bulk(bytes.NewReader(data),
-				client.Bulk.WithIndex(hook.index()),
-			)
-			if err != nil {
-				return err
-			}
-			defer res.Body.Close()
-			if res.IsError() {
-				raw := make(map[string]interface{})
-				if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
-					return fmt.Errorf("failure to to parse response body: %s", err.Error())
-				} else {
-					return fmt.Errorf("error: [%d] %s: %s",
-						res.StatusCode,
-						raw["error"].(map[string]interface{})["type"],
-						raw["error"].(map[string]interface{})["reason"],
-					)
-				}
-				// A successful response might still contain errors for particular documents...
-				//
+// serialize serializes the document for the entry. Values that cannot be encoded
+// are replaced with placeholders and reported to the ErrorHandler.
+func (hook *ElasticHook) serialize(entry *logrus.Entry) ([]byte, error) {
+	if hook.UseLoggerFormatter && hook.serializer == nil && entry.Logger != nil && entry.Logger.Formatter != nil {
+		data, err := hook.format(entry)
+		if err == nil {
+			if hook.dataStream && !hook.hasTimestamp(data) {
+				return nil, ErrNoTimestamp
 			}
-			return nil
-		}, func(data []byte, err error) {
-			// TODO: how to handle the error??
-			// panic(fmt.Sprintf("error: %s", err))
-		})
-		bulkWriters[hook] = writer
-		lock.Unlock()
+			return data, nil
+		}
+		// Fall back to the default document.
+		hook.handleError(err, data)
+	}
+	doc, pooled := createMessage(entry, hook)
+	// the documents in the DefaultFormat always have a timestamp
+	_, isMessage := doc.(*Message)
+	data, err := hook.marshal(doc)
+	if pooled != nil {
+		putMessage(pooled)
+	}
+	if err != nil {
+		if data == nil {
+			return nil, err
+		}
+		hook.handleError(err, data)
+	}
+	if hook.dataStream && !isMessage && !hook.hasTimestamp(data) {
+		return nil, ErrNoTimestamp
+	}
+	return data, nil
+}
 
-		return writer
+func (hook *ElasticHook) handleError(err error, data []byte) {
+	hook.stats.setError(err)
+	hook.debugf("%v", err)
+	if hook.ErrorHandler != nil {
+		// the data of a batch are in a buffer reused by the next flush
+		hook.ErrorHandler(err, append([]byte(nil), data...))
 	}
+}
 
-	return func(entry *logrus.Entry, hook *ElasticHook) error {
-		data, err := json.Marshal(createMessage(entry, hook))
-		if err != nil {
-			return err
+func (hook *ElasticHook) deadLetter(entry *logrus.Entry, doc []byte, err error) {
+	if hook.DeadLetterHandler != nil {
+		hook.DeadLetterHandler(entry, doc, err)
+	}
+	if hook.FallbackWriter != nil {
+		hook.writeFallback(doc)
+	}
+	hook.sendError(entry, doc, err)
+}
+
+// copyEntry returns a copy of the entry with its own fields, which outlives Fire.
+func copyEntry(entry *logrus.Entry) *logrus.Entry {
+	e := *entry
+	e.Data = copyFields(entry.Data)
+	e.Buffer = nil
+	return &e
+}
+
+// entryContext returns the context of the requests delivering an entry: the
+// context of the entry if any, so its deadline, cancellation and values (e.g.
+// tracing spans) apply to the requests, the context of the hook otherwise.
+func (hook *ElasticHook) entryContext(entry *logrus.Entry) context.Context {
+	if entry.Context != nil {
+		return entry.Context
+	}
+	return hook.ctx
+}
+
+func syncFireFunc(entry *logrus.Entry, hook *ElasticHook) error {
+	data, err := hook.encode(entry)
+	if err != nil {
+		return err
+	}
+	meta := hook.documentMeta(entry)
+	hook.mirrorDocument(meta, data)
+	res, err := hook.indexWithRetries(hook.entryContext(entry), hook.documentIndex(hook.entryIndex(entry), entry.Time), meta, data)
+	if err != nil {
+		hook.metrics.DocumentsFailed(1)
+		hook.stats.setError(err)
+		hook.deadLetter(entry, data, err)
+		return err
+	}
+	hook.metrics.DocumentsSent(1)
+	if hook.WriteHandler != nil {
+		hook.WriteHandler(entry, res)
+	}
+	return nil
+}
+
+// newBatcher creates the bulk processor of the hook.
+func newBatcher(hook *ElasticHook) *core.Batcher {
+	b := core.NewBatcher(hook.client, hook.targetIndex, hook.config.Load().FlushInterval, core.DefaultBulkRetries, hook.handleError)
+	b.SetMissingIndexHandler(hook.recreateIndex)
+	b.SetIndexHandler(func(index string) {
+		hook.ensureIndex(index)
+	})
+	b.SetMetrics(hook.metrics)
+	b.SetRejectHandler(func(doc []byte, err error) {
+		// the document is in a batch buffer reused by the next flush
+		hook.deadLetter(nil, append([]byte(nil), doc...), err)
+	})
+	b.SetFlushHandler(func(summary core.FlushSummary) {
+		hook.recordFlush(summary)
+		if hook.FlushHandler != nil {
+			hook.FlushHandler(summary)
 		}
-		data = append([]byte(`{"index":{}}`+"\n"), data...)
-		_, _ = getWriter(hook).Write(append(data, '\n'))
-		return nil
-	}, nil
+	})
+	return b
+}
+
+func bulkFireFunc(entry *logrus.Entry, hook *ElasticHook) error {
+	data, err := hook.encode(entry)
+	if err != nil {
+		return err
+	}
+	meta := hook.documentMeta(entry)
+	hook.mirrorDocument(meta, data)
+	// the batcher creates the indices when it sends the batch, see newBatcher
+	if index := hook.entryIndex(entry); index != "" {
+		return hook.batcher.AddWithMeta(index, meta, data)
+	}
+	if hook.rotation != nil && !entry.Time.IsZero() {
+		return hook.batcher.AddWithMeta(hook.rotation.NameAt(entry.Time), meta, data)
+	}
+	return hook.batcher.AddWithMeta("", meta, data)
 }
 
-// Levels Required for logrus hook implementation
+// Levels Required for logrus hook implementation.
+// The hook registers itself for all levels and filters entries in Fire,
+// so that the level can be changed with Reconfigure after the hook is added.
+// The levels that are shipped are in Config.
 func (hook *ElasticHook) Levels() []logrus.Level {
-	return hook.levels
+	return logrus.AllLevels
 }
 
 // Cancel all calls to elastic
@@ -4,15 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"runtime/pprof"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+	"gopkg.in/go-extras/elogrus.v8/es8"
 )
 
 type NewHookFunc func(client *elasticsearch.Client, host string, level logrus.Level, index string) (*ElasticHook, error)
@@ -246,3 +253,313 @@ func TestError(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateMessageKeepsEntryData(t *testing.T) {
+	hook := &ElasticHook{}
+	hook.config.Store(&Config{Fields: logrus.Fields{}})
+	cause := fmt.Errorf("boom")
+	entry := logrus.NewEntry(logrus.New()).WithError(cause)
+
+	doc, _ := createMessage(entry, hook)
+	msg := doc.(*Message)
+	if msg.Data[logrus.ErrorKey] != "boom" {
+		t.Errorf("Unexpected error field: %#v", msg.Data[logrus.ErrorKey])
+	}
+	if entry.Data[logrus.ErrorKey] != cause {
+		t.Errorf("The entry was modified: %#v", entry.Data[logrus.ErrorKey])
+	}
+}
+
+func TestWriteHandler(t *testing.T) {
+	_, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "write-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	var written []string
+	hook.WriteHandler = func(entry *logrus.Entry, res *IndexResponse) {
+		written = append(written, fmt.Sprintf("%s %s/%s %d/%d", entry.Message, res.Index, res.ID, res.SeqNo, res.PrimaryTerm))
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+
+	logger.Info("audited")
+	if len(written) != 1 || written[0] != "audited write-log/1 7/2" {
+		t.Errorf("Unexpected confirmations: %q", written)
+	}
+}
+
+// nopClient accepts everything without talking to a cluster.
+type nopClient struct {
+	core.Client
+}
+
+func (nopClient) IndexExists(context.Context, string) (bool, error) {
+	return true, nil
+}
+
+func (nopClient) Bulk(context.Context, string, []byte) (*core.BulkResponse, error) {
+	return &core.BulkResponse{}, nil
+}
+
+func BenchmarkBulkProcessorHook(b *testing.B) {
+	hook, err := NewBulkProcessorElasticHookWithClient(nopClient{}, "localhost", logrus.InfoLevel, func() string { return "bench-log" })
+	if err != nil {
+		b.Fatalf("Error creating the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	entry := logger.WithFields(logrus.Fields{"user": "joe", "attempt": 3})
+	entry.Time = time.Now()
+	entry.Level = logrus.InfoLevel
+	entry.Message = "Hello world!"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = hook.Fire(entry)
+		}
+	})
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "entries/s")
+}
+
+func BenchmarkSerialize(b *testing.B) {
+	hook, err := NewElasticHookWithClient(nopClient{}, "localhost", logrus.InfoLevel, func() string { return "bench-log" })
+	if err != nil {
+		b.Fatalf("Error creating the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	entry := logger.WithFields(logrus.Fields{"user": "joe", "attempt": 3, "path": "/orders"})
+	entry.Time = time.Now()
+	entry.Level = logrus.InfoLevel
+	entry.Message = "Hello world!"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = hook.serialize(entry)
+		}
+	})
+}
+
+func TestPooledMessages(t *testing.T) {
+	hook, err := NewElasticHookWithClient(nopClient{}, "localhost", logrus.InfoLevel, func() string { return "pooled-log" })
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	logger := logrus.New()
+	for i := 0; i < 10; i++ {
+		entry := logger.WithField(fmt.Sprintf("field%d", i), i)
+		entry.Message = fmt.Sprintf("entry %d", i)
+		data, err := hook.serialize(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Invalid document %s: %s", data, err)
+		}
+		if msg.Message != entry.Message || len(msg.Data) != 1 || msg.Data[fmt.Sprintf("field%d", i)] != float64(i) {
+			t.Errorf("Expected only the fields of %q, got %s", entry.Message, data)
+		}
+	}
+
+	var kept []*Message
+	hook.MessageModifierFunc = func(entry *logrus.Entry, msg *Message) interface{} {
+		kept = append(kept, msg)
+		return msg
+	}
+	users := []string{"joe", "ann", "bob"}
+	for _, user := range users {
+		if _, err := hook.serialize(logger.WithField("user", user)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, msg := range kept {
+		if msg.Data["user"] != users[i] {
+			t.Errorf("Expected the messages passed to the MessageModifierFunc not to be reused, got %v for %s", msg.Data, users[i])
+		}
+	}
+}
+
+func TestPprofLabels(t *testing.T) {
+	_, client := newFakeElastic(t)
+	_, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "labelled-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"elogrus.index":"labelled-log"`) || !strings.Contains(buf.String(), `"elogrus.mode":"bulk"`) {
+		t.Errorf("The bulk processor goroutine is not labelled:\n%s", buf.String())
+	}
+}
+
+func TestDefaultMappings(t *testing.T) {
+	f, client := newFakeElastic(t)
+	if _, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "mapped-log"); err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	created := f.Requests(http.MethodPut, "/mapped-log")
+	if len(created) != 1 {
+		t.Fatalf("Expected the index to be created")
+	}
+	var body struct {
+		Mappings struct {
+			Properties map[string]struct {
+				Type   string                     `json:"type"`
+				Fields map[string]json.RawMessage `json:"fields"`
+			} `json:"properties"`
+			DynamicTemplates []map[string]struct {
+				PathMatch string `json:"path_match"`
+				Mapping   struct {
+					Type   string                     `json:"type"`
+					Fields map[string]json.RawMessage `json:"fields"`
+				} `json:"mapping"`
+			} `json:"dynamic_templates"`
+		} `json:"mappings"`
+	}
+	if err := json.Unmarshal([]byte(created[0].Body), &body); err != nil {
+		t.Fatalf("Error parsing the create index body: %s", err)
+	}
+	if templates := body.Mappings.DynamicTemplates; len(templates) != 1 ||
+		templates[0]["data_strings"].PathMatch != "data.*" || templates[0]["data_strings"].Mapping.Type != "keyword" ||
+		templates[0]["data_strings"].Mapping.Fields["text"] == nil {
+		t.Errorf("Unexpected dynamic templates: %s", created[0].Body)
+	}
+	props := body.Mappings.Properties
+	if props["@timestamp"].Type != "date" || props["level"].Type != "keyword" || props["host"].Type != "keyword" ||
+		props["message"].Type != "text" || props["message"].Fields["keyword"] == nil {
+		t.Errorf("Unexpected mappings: %s", created[0].Body)
+	}
+}
+
+// contextTransport records the contexts of the requests of a transport.
+type contextTransport struct {
+	core.Transport
+	contexts []context.Context
+}
+
+func (t *contextTransport) Perform(req *http.Request) (*http.Response, error) {
+	t.contexts = append(t.contexts, req.Context())
+	return t.Transport.Perform(req)
+}
+
+func TestEntryContext(t *testing.T) {
+	_, client := newFakeElastic(t)
+	transport := &contextTransport{Transport: client}
+	hook, err := NewElasticHookWithClient(es8.New(transport), "localhost", logrus.InfoLevel, func() string { return "context-log" })
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "span")
+	transport.contexts = nil
+	if err := hook.Fire(logger.WithContext(ctx).WithField("k", "v")); err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.contexts) != 1 || transport.contexts[0].Value(key{}) != "span" {
+		t.Errorf("Expected the request to carry the context of the entry")
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := hook.Fire(logger.WithContext(canceled).WithField("k", "v")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the request to be canceled, got %v", err)
+	}
+}
+
+func TestErrorHandlerKeepsBatches(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if !strings.HasSuffix(r.URL.Path, "/_bulk") {
+			return false
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":{"type":"illegal_argument_exception","reason":"failed"}}`)
+		return true
+	}
+	var mu sync.Mutex
+	var batches [][]byte
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("error-handler-log"),
+		WithDeliveryMode(BulkDelivery),
+		WithErrorHandler(func(err error, data []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			batches = append(batches, data)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+
+	// The second flush reuses the buffer of the first one.
+	for _, msg := range []string{"first", "second"} {
+		if err := hook.Submit(NewDocument().SetMessage(msg)); err != nil {
+			t.Fatal(err)
+		}
+		_ = hook.Flush(context.Background())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 2 || !strings.Contains(string(batches[0]), `"message":"first"`) || !strings.Contains(string(batches[1]), `"message":"second"`) {
+		t.Errorf("Unexpected batches: %q", batches)
+	}
+}
+
+func TestErrorHandlerDeliveryFailures(t *testing.T) {
+	for _, mode := range []DeliveryMode{AsyncDelivery, BulkDelivery} {
+		t.Run(mode.String(), func(t *testing.T) {
+			f, client := newFakeElastic(t)
+			f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+				if strings.HasSuffix(r.URL.Path, "/_doc") || strings.HasSuffix(r.URL.Path, "/_bulk") {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = io.WriteString(w, `{"error":{"type":"illegal_argument_exception","reason":"failed"}}`)
+					return true
+				}
+				return false
+			}
+			handled := make(chan string, 4)
+			hook, err := NewElasticHookWithOptions(es8.New(client),
+				WithIndex("error-handler-log"),
+				WithDeliveryMode(mode),
+				WithErrorHandler(func(err error, data []byte) {
+					if !strings.Contains(err.Error(), "illegal_argument_exception") {
+						t.Errorf("Unexpected error: %s", err)
+					}
+					handled <- string(data)
+				}),
+			)
+			if err != nil {
+				t.Fatalf("Error creating the hook: %s", err)
+			}
+			defer hook.Cancel()
+
+			if err := hook.Submit(NewDocument().SetMessage("lost entry")); err != nil {
+				t.Fatalf("Expected the failure to be reported asynchronously, got %s", err)
+			}
+			_ = hook.Flush(context.Background())
+			select {
+			case data := <-handled:
+				if !strings.Contains(data, "lost entry") {
+					t.Errorf("Expected the payload of the failed entry, got %s", data)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Expected the delivery failure to be reported to the ErrorHandler")
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package elogrus
+
+import "os"
+
+// HostEnv is the environment variable overriding the host name returned by Hostname.
+const HostEnv = "ELOGRUS_HOST"
+
+// Hostname returns the host to ship the documents with: the value of the
+// ELOGRUS_HOST environment variable if set, the name of the machine reported by
+// the kernel otherwise, or "localhost" if it is unknown. It is the default host
+// of NewElasticHookWithOptions, the other constructors take it as their host
+// argument:
+//
+//	hook, err := elogrus.NewBulkProcessorElasticHook(client, elogrus.Hostname(), logrus.InfoLevel, "mylog")
+func Hostname() string {
+	if host := os.Getenv(HostEnv); host != "" {
+		return host
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "localhost"
+}
@@ -0,0 +1,362 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// IndexKey is the data field overriding the index of an entry, e.g.
+//
+//	log.WithField(elogrus.IndexKey, "audit").Info("user deleted")
+//
+// writes the document to the audit index instead of the index of the hook. The
+// field is not part of the document. Unlike the index of the hook, the index of
+// an entry is not rotated.
+const IndexKey = "elogrus.index"
+
+// IndexEventKind is the kind of an IndexEvent.
+type IndexEventKind int
+
+const (
+	// IndexCreated is emitted when the hook creates a new index, e.g. the next
+	// index of a time-based naming scheme.
+	IndexCreated IndexEventKind = iota + 1
+	// IndexRolledOver is emitted when Rollover rolled the alias of the hook over.
+	IndexRolledOver
+	// IndexRecreated is emitted when the index of the hook was found missing
+	// while writing to it, and was created again.
+	IndexRecreated
+)
+
+func (k IndexEventKind) String() string {
+	switch k {
+	case IndexCreated:
+		return "created"
+	case IndexRolledOver:
+		return "rolled over"
+	case IndexRecreated:
+		return "recreated"
+	}
+	return fmt.Sprintf("IndexEventKind(%d)", int(k))
+}
+
+// IndexEvent describes a change of the indices of a hook.
+type IndexEvent struct {
+	Kind IndexEventKind
+	// Index is the created index, or the new index of a rollover.
+	Index string
+	// OldIndex is the previous index of a rollover.
+	OldIndex string
+}
+
+// IndexEventHandlerFunc receives the index events of a hook, e.g. to apply
+// permissions to new indices.
+type IndexEventHandlerFunc func(event IndexEvent)
+
+func (hook *ElasticHook) indexEvent(event IndexEvent) {
+	if event.Kind == IndexRolledOver {
+		hook.debugf("rolled over index %s to %s", event.OldIndex, event.Index)
+	} else {
+		hook.debugf("%s index %s", event.Kind, event.Index)
+	}
+	if hook.IndexEventHandler != nil {
+		hook.IndexEventHandler(event)
+	}
+}
+
+// createIndex creates an index with the body set by SetIndexBody, or the
+// mappings of the document format of the hook.
+func (hook *ElasticHook) createIndex(ctx context.Context, index string) error {
+	req := make(map[string]interface{}, len(hook.indexBody)+1)
+	for k, v := range hook.indexBody {
+		req[k] = v
+	}
+	mappings := hook.documentFormat.mappings()
+	if m, ok := req["mappings"].(map[string]interface{}); ok {
+		mappings = make(map[string]interface{}, len(m)+1)
+		for k, v := range m {
+			mappings[k] = v
+		}
+	}
+	mappings["_meta"] = indexMeta{SchemaVersion: hook.schemaVersion}
+	req["mappings"] = mappings
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return hook.client.CreateIndex(ctx, index, body)
+}
+
+// SetIndexBody sets the body of the requests creating the indices of the hook:
+// their settings, mappings and aliases, e.g.
+//
+//	err := hook.SetIndexBody(`{
+//		"settings": {"number_of_shards": 3},
+//		"mappings": {"properties": {"data": {"properties": {"user_id": {"type": "keyword"}}}}}
+//	}`)
+//
+// body is a JSON object as a string, a []byte or a json.RawMessage, or a value
+// encoded to one such as a map or a struct. The mappings replace the mappings of
+// the document format, rather than relying on dynamic mapping for the fields they
+// declare; the format mappings are kept if body has none. Like SetDocumentFormat,
+// it only applies to the indices created from then on, use WithIndexBody for the
+// index created by the constructor.
+func (hook *ElasticHook) SetIndexBody(body interface{}) error {
+	parsed, err := parseIndexBody(body)
+	if err != nil {
+		return err
+	}
+	hook.indexBody = parsed
+	return nil
+}
+
+// parseIndexBody decodes the body of the index creation requests, see SetIndexBody.
+func parseIndexBody(body interface{}) (map[string]interface{}, error) {
+	var data []byte
+	switch b := body.(type) {
+	case string:
+		data = []byte(b)
+	case []byte:
+		data = b
+	case json.RawMessage:
+		data = b
+	default:
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("cannot encode index body: %w", err)
+		}
+	}
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("index body is not a JSON object: %w", err)
+	}
+	return parsed, nil
+}
+
+// DefaultIndexCacheTTL is how long an index is known to exist by an IndexCache.
+const DefaultIndexCacheTTL = 10 * time.Minute
+
+// IndexCache remembers the indices known to exist, so that hooks check and create
+// every index once rather than on every new index name. Each hook has its own cache
+// by default; hooks writing to the same cluster can share one with SetIndexCache.
+// Indices are checked again once their entry is older than the TTL of the cache.
+type IndexCache struct {
+	ttl time.Duration
+	// mu serializes the checks and creations of indices
+	mu sync.Mutex
+	// expiry holds the time until which an index is known to exist
+	expiry sync.Map
+}
+
+// NewIndexCache creates a new IndexCache, ttl defaults to DefaultIndexCacheTTL.
+func NewIndexCache(ttl time.Duration) *IndexCache {
+	if ttl <= 0 {
+		ttl = DefaultIndexCacheTTL
+	}
+	return &IndexCache{ttl: ttl}
+}
+
+// known reports whether the index is known to exist.
+func (c *IndexCache) known(index string) bool {
+	expiry, ok := c.expiry.Load(index)
+	if !ok {
+		return false
+	}
+	if time.Now().Before(expiry.(time.Time)) {
+		return true
+	}
+	c.expiry.Delete(index)
+	return false
+}
+
+// add records that the index exists.
+func (c *IndexCache) add(index string) {
+	c.expiry.Store(index, time.Now().Add(c.ttl))
+}
+
+// SetIndexCache makes the hook share the cache of existing indices with other
+// hooks writing to the same cluster.
+func (hook *ElasticHook) SetIndexCache(cache *IndexCache) {
+	cache.add(hook.index())
+	hook.indexCache = cache
+}
+
+// targetIndex returns the current index of the hook, see ensureIndex.
+func (hook *ElasticHook) targetIndex() string {
+	return hook.ensureIndex(hook.index())
+}
+
+// indexAt returns the index of an entry logged at t: the index of that time if
+// the hook has an index rotation, the current index otherwise. See ensureIndex.
+func (hook *ElasticHook) indexAt(t time.Time) string {
+	if hook.rotation == nil || t.IsZero() {
+		return hook.targetIndex()
+	}
+	return hook.ensureIndex(hook.rotation.NameAt(t))
+}
+
+// LevelIndexFunc returns the index of the entries of a level, "" for the index
+// of the hook, see SetLevelIndex.
+type LevelIndexFunc func(level logrus.Level) string
+
+// LevelIndices returns a LevelIndexFunc writing the entries of the levels of
+// indices to their index, and the others to the index of the hook.
+func LevelIndices(indices map[logrus.Level]string) LevelIndexFunc {
+	return func(level logrus.Level) string {
+		return indices[level]
+	}
+}
+
+// SetLevelIndex makes the hook write the entries to the index returned by
+// levelIndex for their level instead of its index, e.g. the errors to an index
+// kept for longer. The IndexKey field of an entry takes precedence. Like the
+// index of an entry, the index of a level is not rotated.
+func (hook *ElasticHook) SetLevelIndex(levelIndex LevelIndexFunc) {
+	hook.levelIndex = levelIndex
+}
+
+// entryIndex returns the index overriding the index of the hook for an entry,
+// see IndexKey and SetLevelIndex, or "" if there is none.
+func (hook *ElasticHook) entryIndex(entry *logrus.Entry) string {
+	if index, _ := entry.Data[IndexKey].(string); index != "" {
+		return index
+	}
+	if hook.levelIndex != nil {
+		return hook.levelIndex(entry.Level)
+	}
+	return ""
+}
+
+// documentIndex returns the index of a document: the index of its entry if it
+// has one, see entryIndex, the index of the hook at t otherwise.
+func (hook *ElasticHook) documentIndex(index string, t time.Time) string {
+	if index != "" {
+		return hook.ensureIndex(index)
+	}
+	return hook.indexAt(t)
+}
+
+// ensureIndex creates the index if the hook has not seen it yet and it does
+// not exist. It returns the index.
+func (hook *ElasticHook) ensureIndex(index string) string {
+	if hook.externalIndices {
+		return index
+	}
+	cache := hook.indexCache
+	if cache.known(index) {
+		return index
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.known(index) {
+		return index
+	}
+	ctx, cancel := hook.requestContext()
+	defer cancel()
+	exists, err := hook.client.IndexExists(ctx, index)
+	if err == nil && !exists {
+		if err = hook.createIndex(ctx, index); err == nil {
+			hook.indexEvent(IndexEvent{Kind: IndexCreated, Index: index})
+		}
+	}
+	if err != nil {
+		// Try again with the next document, the cluster may still create
+		// the index automatically.
+		hook.handleError(fmt.Errorf("cannot create index %s: %w", index, err), nil)
+		return index
+	}
+	cache.add(index)
+	return index
+}
+
+// recreateIndex creates the index of the hook again after it was found missing.
+func (hook *ElasticHook) recreateIndex(index string) error {
+	if hook.dataStream {
+		return fmt.Errorf("data stream %s does not exist, no index template matches it", index)
+	}
+	if hook.externalIndices {
+		return fmt.Errorf("index %s does not exist, it is managed externally", index)
+	}
+	ctx, cancel := hook.requestContext()
+	defer cancel()
+	err := hook.createIndex(ctx, index)
+	var e *core.ResponseError
+	if errors.As(err, &e) && e.Type == "resource_already_exists_exception" {
+		// created concurrently
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot recreate index %s: %w", index, err)
+	}
+	hook.indexEvent(IndexEvent{Kind: IndexRecreated, Index: index})
+	return nil
+}
+
+// indexDocument indexes a single document, recreating the index if it is missing.
+func (hook *ElasticHook) indexDocument(ctx context.Context, index string, meta core.DocumentMeta, data []byte) (*core.IndexResponse, error) {
+	res, err := hook.sendDocument(ctx, index, meta, data)
+	if core.IsIndexNotFound(err) {
+		if err = hook.recreateIndex(index); err == nil {
+			res, err = hook.sendDocument(ctx, index, meta, data)
+		}
+	}
+	return res, err
+}
+
+// sendDocument indexes a single document within the request timeout, ctx
+// bounds the request as well. It fails with ErrCircuitOpen while the circuit
+// breaker is open.
+func (hook *ElasticHook) sendDocument(ctx context.Context, index string, meta core.DocumentMeta, data []byte) (*core.IndexResponse, error) {
+	if hook.breaker != nil {
+		if err := hook.breaker.Allow(); err != nil {
+			return nil, err
+		}
+	}
+	ctx, cancel := hook.requestContextFrom(ctx)
+	defer cancel()
+	end := func(error) {}
+	if hook.tracer != nil {
+		ctx, end = hook.tracer.Start(ctx, core.OpIndex, index, 1, len(data))
+	}
+	start := time.Now()
+	res, err := hook.client.IndexWithMeta(ctx, index, data, hook.contentType(), meta)
+	hook.metrics.RequestSent(1, len(data), time.Since(start))
+	end(err)
+	if hook.breaker != nil {
+		hook.breaker.Record(err)
+	}
+	return res, err
+}
+
+// SetRequestTimeout bounds the duration of every request the hook sends to the
+// cluster while delivering entries, so an unresponsive cluster cannot block the
+// synchronous hook forever. By default there is no timeout.
+func (hook *ElasticHook) SetRequestTimeout(timeout time.Duration) {
+	hook.requestTimeout = timeout
+	if hook.batcher != nil {
+		hook.batcher.SetRequestTimeout(timeout)
+	}
+}
+
+// requestContext returns the context of a request, see SetRequestTimeout.
+func (hook *ElasticHook) requestContext() (context.Context, context.CancelFunc) {
+	return hook.requestContextFrom(context.Background())
+}
+
+// requestContextFrom returns the context of a request derived from parent, see
+// SetRequestTimeout.
+func (hook *ElasticHook) requestContextFrom(parent context.Context) (context.Context, context.CancelFunc) {
+	if hook.requestTimeout > 0 {
+		return context.WithTimeout(parent, hook.requestTimeout)
+	}
+	return parent, func() {}
+}
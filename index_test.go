@@ -0,0 +1,332 @@
+package elogrus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestIndexEvents(t *testing.T) {
+	f, client := newFakeElastic(t)
+	var missing atomic.Bool
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		switch {
+		case r.URL.Path == "/events-log-2/_rollover":
+			_, _ = io.WriteString(w, `{"old_index":"events-log-2-000001","new_index":"events-log-2-000002","rolled_over":true}`)
+			return true
+		case strings.HasSuffix(r.URL.Path, "/_doc") && missing.CompareAndSwap(true, false):
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}`)
+			return true
+		}
+		return false
+	}
+	var index atomic.Value
+	index.Store("events-log-1")
+	hook, err := NewElasticHookWithFunc(client, "localhost", logrus.InfoLevel, func() string { return index.Load().(string) })
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	var mu sync.Mutex
+	var events []IndexEvent
+	hook.IndexEventHandler = func(event IndexEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	logger.Info("first")
+	index.Store("events-log-2")
+	logger.Info("second")
+	logger.Info("third")
+	missing.Store(true)
+	logger.Info("fourth")
+	if _, err := hook.Rollover(context.Background(), RolloverConditions{}); err != nil {
+		t.Fatalf("Error rolling over: %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []IndexEvent{
+		{Kind: IndexCreated, Index: "events-log-2"},
+		{Kind: IndexRecreated, Index: "events-log-2"},
+		{Kind: IndexRolledOver, Index: "events-log-2-000002", OldIndex: "events-log-2-000001"},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Unexpected events: %+v", events)
+	}
+	if n := len(f.Requests(http.MethodPost, "/events-log-2/_doc")); n != 4 {
+		t.Errorf("Expected 4 documents sent to events-log-2, got %d", n)
+	}
+	if n := len(f.Requests(http.MethodPut, "/events-log-2")); n != 2 {
+		t.Errorf("Expected events-log-2 to be created twice, got %d", n)
+	}
+}
+
+func TestSharedIndexCache(t *testing.T) {
+	f, client := newFakeElastic(t)
+	var index atomic.Value
+	index.Store("cached-log-1")
+	indexFunc := func() string { return index.Load().(string) }
+	cache := NewIndexCache(time.Hour)
+	logger := logrus.New()
+	logger.Out = io.Discard
+	for i := 0; i < 3; i++ {
+		hook, err := NewElasticHookWithFunc(client, "localhost", logrus.InfoLevel, indexFunc)
+		if err != nil {
+			t.Fatalf("Error creating the hook: %s", err)
+		}
+		hook.SetIndexCache(cache)
+		logger.AddHook(hook)
+	}
+
+	index.Store("cached-log-2")
+	logger.Info("first")
+	logger.Info("second")
+	if n := len(f.Requests(http.MethodHead, "/cached-log-2")); n != 1 {
+		t.Errorf("Expected one existence check, got %d", n)
+	}
+	if n := len(f.Requests(http.MethodPost, "/cached-log-2/_doc")); n != 6 {
+		t.Errorf("Expected 6 documents, got %d", n)
+	}
+}
+
+func TestIndexKey(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "app-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	logger.WithField(IndexKey, "audit-log").WithField("user", "alice").Info("user deleted")
+	logger.Info("regular")
+
+	reqs := f.Requests(http.MethodPost, "/audit-log/_doc")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one document in the audit index, got %d", len(reqs))
+	}
+	if strings.Contains(reqs[0].Body, IndexKey) || !strings.Contains(reqs[0].Body, `"user":"alice"`) {
+		t.Errorf("Unexpected document: %s", reqs[0].Body)
+	}
+	if len(f.Requests(http.MethodPut, "/audit-log")) != 1 {
+		t.Error("Expected the audit index to be created")
+	}
+	if reqs := f.Requests(http.MethodPost, "/app-log/_doc"); len(reqs) != 1 {
+		t.Errorf("Expected one document in the index of the hook, got %d", len(reqs))
+	}
+
+	hook, err = NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "app-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("bulk").AddField(IndexKey, "audit-log")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reqs = f.Requests(http.MethodPost, "/app-log/_bulk")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one bulk request, got %d", len(reqs))
+	}
+	if !strings.Contains(reqs[0].Body, `{"index":{"_index":"audit-log"}}`) || strings.Contains(reqs[0].Body, IndexKey) {
+		t.Errorf("Unexpected bulk request: %s", reqs[0].Body)
+	}
+}
+
+func TestIndexKeyBulkOutage(t *testing.T) {
+	f, client := newFakeElastic(t)
+	release := make(chan struct{})
+	var once sync.Once
+	answer := func() { once.Do(func() { close(release) }) }
+	t.Cleanup(answer)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.URL.Path == "/audit-log" {
+			// the cluster does not answer
+			<-release
+		}
+		return false
+	}
+	hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "app-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+
+	// The index is created in the background, Submit does not wait for it.
+	done := make(chan error, 1)
+	go func() {
+		done <- hook.Submit(NewDocument().SetMessage("bulk").AddField(IndexKey, "audit-log"))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Submit not to wait for the index to be created")
+	}
+	flushed := make(chan struct{})
+	go func() {
+		_ = hook.Flush(context.Background())
+		close(flushed)
+	}()
+	answer()
+	<-flushed
+	if len(f.Requests(http.MethodPut, "/audit-log")) != 1 {
+		t.Error("Expected the audit index to be created")
+	}
+	if reqs := f.Requests(http.MethodPost, "/app-log/_bulk"); len(reqs) != 1 || !strings.Contains(reqs[0].Body, `"_index":"audit-log"`) {
+		t.Errorf("Unexpected bulk requests: %+v", reqs)
+	}
+}
+
+func TestSetLevelIndex(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("app-logs"),
+		WithLevelIndex(LevelIndices(map[logrus.Level]string{logrus.ErrorLevel: "app-errors"})),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	logger.Error("failed")
+	logger.Info("regular")
+	logger.WithField(IndexKey, "audit-log").Error("user deleted")
+
+	for index, want := range map[string]int{"/app-errors/_doc": 1, "/app-logs/_doc": 1, "/audit-log/_doc": 1} {
+		if n := len(f.Requests(http.MethodPost, index)); n != want {
+			t.Errorf("Expected %d documents in %s, got %d", want, index, n)
+		}
+	}
+}
+
+func TestWithIndexBody(t *testing.T) {
+	f, client := newFakeElastic(t)
+	type indexBody struct {
+		Settings map[string]interface{} `json:"settings"`
+		Mappings map[string]interface{} `json:"mappings"`
+	}
+	body := indexBody{
+		Settings: map[string]interface{}{"number_of_shards": 3},
+		Mappings: map[string]interface{}{"properties": map[string]interface{}{"user_id": map[string]string{"type": "keyword"}}},
+	}
+	hook, err := NewElasticHookWithOptions(es8.New(client), WithIndex("body-log"), WithIndexBody(body))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	created := f.Requests(http.MethodPut, "/body-log")
+	if len(created) != 1 {
+		t.Fatalf("Expected the index to be created, got %d requests", len(created))
+	}
+	expected := `{"mappings":{"_meta":{"schema_version":1},"properties":{"user_id":{"type":"keyword"}}},"settings":{"number_of_shards":3}}`
+	if created[0].Body != expected {
+		t.Errorf("Unexpected index body:\n%s\nexpected:\n%s", created[0].Body, expected)
+	}
+
+	// The mappings of the format are kept without mappings in the body.
+	if err := hook.SetIndexBody(`{"settings":{"number_of_replicas":0}}`); err != nil {
+		t.Fatal(err)
+	}
+	hook.ensureIndex("body-log-2")
+	created = f.Requests(http.MethodPut, "/body-log-2")
+	if len(created) != 1 || !strings.Contains(created[0].Body, `"number_of_replicas":0`) || !strings.Contains(created[0].Body, `"message"`) {
+		t.Errorf("Unexpected index creation: %+v", created)
+	}
+
+	if err := hook.SetIndexBody("[]"); err == nil {
+		t.Error("Expected an error for a body which is not a JSON object")
+	}
+	if _, err := NewElasticHookWithOptions(es8.New(client), WithIndex("body-log"), WithIndexBody(func() {})); err == nil {
+		t.Error("Expected an error for a body which cannot be encoded")
+	}
+}
+
+func TestWithExternalIndices(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.Method == http.MethodHead || r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `{"error":{"type":"security_exception","reason":"unauthorized"},"status":403}`)
+			return true
+		}
+		return false
+	}
+	if _, err := NewElasticHookWithOptions(es8.New(client), WithIndex("external-log")); err == nil {
+		t.Fatal("Expected an error without the privileges to create the index")
+	}
+
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndexFunc(func() string { return "external-log-" + time.Now().Format("2006.01.02") }),
+		WithExternalIndices(),
+		WithSchemaVersion(2, nil),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("entry")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("entry").AddField(IndexKey, "audit-log")); err != nil {
+		t.Fatal(err)
+	}
+	if reqs := f.Requests(http.MethodPost, ""); len(reqs) != 2 {
+		t.Errorf("Expected only the documents to be sent: %+v", reqs)
+	}
+	if reqs := f.Requests(http.MethodHead, ""); len(reqs) != 1 {
+		t.Errorf("Expected only the check of the first hook: %+v", reqs)
+	}
+}
+
+func TestLazyIndexCreation(t *testing.T) {
+	for _, mode := range []DeliveryMode{SyncDelivery, AsyncDelivery, BulkDelivery} {
+		t.Run(mode.String(), func(t *testing.T) {
+			f, client := newFakeElastic(t)
+			var index atomic.Value
+			index.Store("daily-log-2024.05.17")
+			hook, err := NewElasticHookWithOptions(es8.New(client),
+				WithIndexFunc(func() string { return index.Load().(string) }),
+				WithDeliveryMode(mode),
+			)
+			if err != nil {
+				t.Fatalf("Error creating the hook: %s", err)
+			}
+			defer hook.Cancel()
+
+			index.Store("daily-log-2024.05.18")
+			for i := 0; i < 3; i++ {
+				if err := hook.Submit(NewDocument().SetMessage("entry")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := hook.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"/daily-log-2024.05.17", "/daily-log-2024.05.18"} {
+				if n := len(f.Requests(http.MethodPut, name)); n != 1 {
+					t.Errorf("Expected %s to be created once, got %d requests", name, n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package bulk
+
+import (
+	"errors"
+	"sync"
+)
+
+// OverflowPolicy defines what happens to a write that does not fit in the
+// maximum size of a Writer.
+type OverflowPolicy int
+
+const (
+	// Block makes Write wait until a flush makes room for the data.
+	Block OverflowPolicy = iota
+	// DropOldest drops the oldest buffered writes to make room for the data.
+	// Writes are dropped whole, the oldest of every staging buffer first.
+	DropOldest
+	// DropNewest drops the data, Write returns ErrBufferFull.
+	DropNewest
+)
+
+// ErrBufferFull is returned by Write when the data do not fit in the maximum size.
+var ErrBufferFull = errors.New("bulk.Writer buffer is full")
+
+// DropHandlerFunc receives the data of a write dropped by the DropOldest policy.
+// The data are reused once it returns.
+type DropHandlerFunc func(data []byte)
+
+// limit bounds the data staged by a Writer.
+type limit struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	max     int
+	policy  OverflowPolicy
+	size    int
+	dropped uint64
+	closed  bool
+	onDrop  DropHandlerFunc
+	// full asks the processor for an early flush, it is buffered so the
+	// request is not lost while a flush is in progress
+	full chan bool
+}
+
+// SetMaxSize bounds the data buffered between two flushes to size bytes, policy
+// defines what happens to the writes above it. A write larger than size is
+// always dropped with ErrBufferFull. A nonpositive size removes the bound.
+// It must be called before the first Write.
+func (b *Writer) SetMaxSize(size int, policy OverflowPolicy) {
+	b.limit.mu.Lock()
+	b.limit.max = size
+	b.limit.policy = policy
+	b.limit.mu.Unlock()
+}
+
+// SetDropHandler makes the Writer call onDrop with the data of every write
+// dropped by the DropOldest policy, e.g. to release resources held for them.
+// It must be called before the first Write.
+func (b *Writer) SetDropHandler(onDrop DropHandlerFunc) {
+	b.limit.onDrop = onDrop
+}
+
+// Dropped returns the number of writes dropped because the buffer was full.
+func (b *Writer) Dropped() uint64 {
+	b.limit.mu.Lock()
+	defer b.limit.mu.Unlock()
+	return b.limit.dropped
+}
+
+// reserve makes room for n bytes to be written to the staging buffer i
+// according to the overflow policy.
+func (b *Writer) reserve(i, n int) error {
+	l := &b.limit
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n > l.max {
+		l.dropped++
+		return ErrBufferFull
+	}
+	for l.size+n > l.max {
+		if l.closed {
+			return ErrWriterClosed
+		}
+		switch l.policy {
+		case DropNewest:
+			l.dropped++
+			return ErrBufferFull
+		case DropOldest:
+			if b.dropOldest(i, l.size+n-l.max) {
+				continue
+			}
+		}
+		// Flush early rather than wait for the next tick, unless an
+		// early flush is requested already.
+		select {
+		case l.full <- true:
+		default:
+		}
+		l.cond.Wait()
+	}
+	l.size += n
+	return nil
+}
+
+// dropOldest drops the oldest writes of the staging buffers, starting with
+// buffer i, until need bytes are freed. It reports whether any write was
+// dropped. The limit must be locked.
+func (b *Writer) dropOldest(i, need int) bool {
+	dropped := false
+	for k := 0; k < len(b.shards) && need > 0; k++ {
+		s := &b.shards[(i+k)%len(b.shards)]
+		s.mu.Lock()
+		for len(s.sizes) > 0 && need > 0 {
+			n := s.sizes[0]
+			if b.limit.onDrop != nil {
+				b.limit.onDrop(s.buf[:n])
+			}
+			s.buf = append(s.buf[:0], s.buf[n:]...)
+			s.sizes = s.sizes[1:]
+			b.limit.size -= n
+			b.limit.dropped++
+			need -= n
+			dropped = true
+		}
+		s.mu.Unlock()
+	}
+	return dropped
+}
+
+// release frees n bytes of staged data, once they are collected for a flush.
+func (l *limit) release(n int) {
+	if n == 0 {
+		return
+	}
+	l.mu.Lock()
+	if l.max > 0 {
+		l.size -= n
+	}
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// close wakes up the blocked writes, which then fail.
+func (l *limit) close() {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
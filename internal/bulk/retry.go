@@ -0,0 +1,37 @@
+package bulk
+
+// retry keeps the data of the failed flushes, see SetRetry.
+type retry struct {
+	max     int
+	maxSize int
+	// attempts is the number of failed flushes of the kept data
+	attempts int
+	// err is the error of the last failed flush
+	err error
+}
+
+// SetRetry makes the Writer keep the data of a failed flush instead of passing
+// them to the ErrorHandlerFunc, and flush them again with the next flush, ahead
+// of the data written meanwhile. The data written meanwhile share the retries
+// left of the kept data. After maxRetries failed retries, or once the kept data
+// exceed maxSize bytes, the data are passed to the ErrorHandlerFunc. A
+// nonpositive maxSize removes the bound. The data still kept when the Writer is
+// closed are passed to the ErrorHandlerFunc too.
+// It must be called before the first Write.
+func (b *Writer) SetRetry(maxRetries, maxSize int) {
+	b.retry.max = maxRetries
+	b.retry.maxSize = maxSize
+}
+
+// keep records a failed flush of the flush buffer and reports whether its data
+// are kept for the next flush.
+func (b *Writer) keep(err error) bool {
+	r := &b.retry
+	if r.attempts >= r.max || (r.maxSize > 0 && len(b.buf) > r.maxSize) {
+		r.attempts = 0
+		return false
+	}
+	r.attempts++
+	r.err = err
+	return true
+}
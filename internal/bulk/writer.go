@@ -2,23 +2,30 @@ package bulk
 
 import (
 	"errors"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // A function of a FlushFunc type once called will receive
 // a buffer containing all the data from writes made after
-// the previous FlushFunc call. The data buffer will be cleaned up
-// automatically after this function is executed (i.e. you do
-// not need to clean it up yourself).
+// the previous FlushFunc call. The buffer is reused for the
+// next flush once this function returns, so the data must be
+// copied if they are needed afterwards.
 // Any error returned from this function will be passed to a ErrorHandlerFunc function
 type FlushFunc func(data []byte) error
 
 // ErrorHandlerFunc is a function that gets errors occured in FlushFunc
-// It will also get a buffer copy so that you could somehow analyze it.
-// After this function is called, the buffer is destroyed automatically
-// by the calling code (i.e. you do not need to clean it up yourself).
+// It will also get the flushed buffer so that you could somehow analyze it.
+// Like in FlushFunc, the buffer is reused once this function returns,
+// so the data must be copied if they are kept.
 type ErrorHandlerFunc func(data []byte, err error)
 
+// ErrWriterClosed is returned by the methods of a Writer called after Close.
+var ErrWriterClosed = errors.New("bulk.Writer is closed")
+
 // NoErrorHandler is an empty function that is used when no ErrorHandler is required
 // One may always process all their errors directly in the FlushFunc.
 var NoErrorHandler = func(data []byte, err error) {}
@@ -26,16 +33,37 @@ var NoErrorHandler = func(data []byte, err error) {}
 // Writer is an implenetation of an io.WriteCloser interface.
 // It lets creating a buffered writer that can flush (and thus physically write)
 // the buffer by a time ticker or by manual calls of Writer.Flush().
+//
+// Writes go to one of several staging buffers (one per CPU), so that concurrent
+// writers rarely wait for each other; the staging buffers are merged on flush.
+// Data of a single Write call is never split, but data of concurrent calls may be
+// flushed in a different order than they were written.
 type Writer struct {
 	ticker       *time.Ticker
 	tickerCh     <-chan time.Time
 	buf          []byte
-	data         chan []byte
+	capacity     int64
+	shards       []shard
+	next         uint32
 	quit         chan bool
 	flusher      chan bool
-	closed       bool
+	interval     chan time.Duration
+	jitter       chan float64
+	closed       atomic.Bool
 	flushFunc    FlushFunc
 	errorHandler ErrorHandlerFunc
+
+	// limit bounds the staged data, see SetMaxSize
+	limit limit
+	// retry keeps the data of the failed flushes, see SetRetry
+	retry retry
+	// flushInterval and flushJitter are owned by the processor, see SetFlushJitter
+	flushInterval time.Duration
+	flushJitter   float64
+	// done is closed once the processor has stopped, closeErr is the error
+	// of its final flush
+	done     chan struct{}
+	closeErr error
 }
 
 // NewBulkWriter creates a new bulk.Writer instance
@@ -58,58 +86,143 @@ func NewBulkWriter(flushInterval time.Duration, flushFunc FlushFunc) *Writer {
 func NewBulkWriterWithErrorHandler(flushInterval time.Duration, flushFunc FlushFunc, errorHandler ErrorHandlerFunc) *Writer {
 	bw := &Writer{
 		buf:          make([]byte, 0),
-		data:         make(chan []byte),
+		shards:       make([]shard, runtime.GOMAXPROCS(0)),
 		quit:         make(chan bool),
+		done:         make(chan struct{}),
 		flushFunc:    flushFunc,
 		errorHandler: errorHandler,
 		flusher:      make(chan bool),
+		interval:     make(chan time.Duration),
+		jitter:       make(chan float64),
+	}
+	bw.limit.cond = sync.NewCond(&bw.limit.mu)
+	bw.limit.full = make(chan bool, 1)
+	bw.setFlushInterval(flushInterval)
+	go bw.processor()
+	return bw
+}
+
+func (b *Writer) setFlushInterval(flushInterval time.Duration) {
+	if b.ticker != nil {
+		b.ticker.Stop()
+		b.ticker = nil
 	}
+	b.flushInterval = flushInterval
 	if flushInterval > 0 {
-		bw.ticker = time.NewTicker(flushInterval)
-		bw.tickerCh = bw.ticker.C
+		b.ticker = time.NewTicker(b.nextInterval())
+		b.tickerCh = b.ticker.C
 	} else {
-		bw.tickerCh = make(chan time.Time)
+		b.tickerCh = make(chan time.Time)
 	}
-	go bw.processor()
-	return bw
 }
 
-func (b *Writer) flush() {
+// shard is a staging buffer. It is padded to a cache line to avoid false
+// sharing between the neighbouring shards.
+type shard struct {
+	mu  sync.Mutex
+	buf []byte
+	// sizes holds the size of every write in buf, the oldest first, when
+	// the oldest data are dropped on overflow
+	sizes []int
+	_     [8]byte
+}
+
+// collect moves the data of all the staging buffers to the flush buffer.
+func (b *Writer) collect() {
+	start := len(b.buf)
+	for i := range b.shards {
+		s := &b.shards[i]
+		s.mu.Lock()
+		b.buf = append(b.buf, s.buf...)
+		s.buf = s.buf[:0]
+		s.sizes = s.sizes[:0]
+		s.mu.Unlock()
+	}
+	b.limit.release(len(b.buf) - start)
+}
+
+// flush flushes the buffered data and returns the error of the FlushFunc.
+func (b *Writer) flush() error {
+	if want := int(atomic.LoadInt64(&b.capacity)); cap(b.buf) < want {
+		// the buffer may hold the data of a failed flush
+		b.buf = append(make([]byte, 0, want), b.buf...)
+	}
+	b.collect()
 	if len(b.buf) == 0 {
-		return
+		return nil
 	}
-	if err := b.flushFunc(b.buf); err != nil {
+	err := b.flushFunc(b.buf)
+	if err != nil {
+		if b.keep(err) {
+			return err
+		}
 		b.errorHandler(b.buf, err)
 	}
-	b.buf = []byte{}
+	b.retry.attempts = 0
+	b.buf = b.buf[:0]
+	return err
 }
 
 func (b *Writer) processor() {
 loop:
 	for {
 		select {
-		case d := <-b.data:
-			b.buf = append(b.buf, d...)
 		case <-b.flusher:
-			b.flush()
+			_ = b.flush()
+		case <-b.limit.full:
+			_ = b.flush()
 		case <-b.tickerCh:
-			b.flush()
+			_ = b.flush()
+			if b.flushJitter > 0 {
+				b.ticker.Reset(b.nextInterval())
+			}
+		case d := <-b.interval:
+			b.setFlushInterval(d)
+		case j := <-b.jitter:
+			b.flushJitter = j
+			b.setFlushInterval(b.flushInterval)
 		case <-b.quit:
-			b.flush()
+			b.closeErr = b.flush()
+			if len(b.buf) > 0 {
+				// kept by a failed flush
+				b.errorHandler(b.buf, b.retry.err)
+			}
 			break loop
 		}
 	}
+	if b.ticker != nil {
+		b.ticker.Stop()
+	}
+	close(b.done)
 }
 
 // Write is an implementation of an io.Writer interface. The data are appended to a temporary
 // buffer that will be cleaned up on flush.
-// It will return an error if called after Close() was called.
+// It will return ErrWriterClosed if called after Close() was called.
 func (b *Writer) Write(data []byte) (n int, err error) {
-	if b.closed {
-		return 0, errors.New("writing on a closed bulk.Writer")
+	if b.closed.Load() {
+		return 0, ErrWriterClosed
 	}
 
-	b.data <- data
+	i := atomic.AddUint32(&b.next, 1) % uint32(len(b.shards))
+	if b.limit.max > 0 {
+		if err := b.reserve(int(i), len(data)); err != nil {
+			return 0, err
+		}
+	}
+	s := &b.shards[i]
+	s.mu.Lock()
+	if b.closed.Load() {
+		// Close may have collected the shards for the final flush already
+		s.mu.Unlock()
+		b.limit.release(len(data))
+		return 0, ErrWriterClosed
+	}
+	s.buf = append(s.buf, data...)
+	if b.limit.policy == DropOldest {
+		s.sizes = append(s.sizes, len(data))
+	}
+	s.mu.Unlock()
 
 	return len(data), nil
 }
@@ -117,28 +230,105 @@ func (b *Writer) Write(data []byte) (n int, err error) {
 // Flush forces buffer flush. It is mainly suited for buffer flushing
 // when automatic flushing is turned off, but you may call it even
 // if automatic flushing is turned on.
-// It will return an error if called after Close() was called.
+// It will return ErrWriterClosed if called after Close() was called.
 func (b *Writer) Flush() error {
-	if b.closed {
-		return errors.New("flushing a closed bulk.Writer")
+	if b.closed.Load() {
+		return ErrWriterClosed
+	}
+	select {
+	case b.flusher <- true:
+		return nil
+	case <-b.done:
+		return ErrWriterClosed
+	}
+}
+
+// Preallocate makes room for size bytes of buffered data, so that the buffers
+// do not need to grow while the expected amount of data is written.
+func (b *Writer) Preallocate(size int) {
+	atomic.StoreInt64(&b.capacity, int64(size))
+	// The data of a flush is spread over the staging buffers, with some slack
+	// for an uneven distribution.
+	perShard := 2 * size / len(b.shards)
+	for i := range b.shards {
+		s := &b.shards[i]
+		s.mu.Lock()
+		if cap(s.buf) < perShard {
+			buf := make([]byte, len(s.buf), perShard)
+			copy(buf, s.buf)
+			s.buf = buf
+		}
+		s.mu.Unlock()
+	}
+	// The flush buffer is grown by the processor on the next flush.
+	_ = b.Flush()
+}
+
+// SetFlushInterval changes how often the buffer is flushed automatically,
+// a nonpositive value turns automatic flushing off. The buffered data are kept.
+// It will return ErrWriterClosed if called after Close() was called.
+func (b *Writer) SetFlushInterval(flushInterval time.Duration) error {
+	if b.closed.Load() {
+		return ErrWriterClosed
+	}
+	select {
+	case b.interval <- flushInterval:
+		return nil
+	case <-b.done:
+		return ErrWriterClosed
+	}
+}
+
+// SetFlushJitter makes every automatic flush interval vary randomly by up to
+// the jitter fraction of the flush interval, e.g. 0.2 for intervals between
+// 0.8 and 1.2 times the flush interval, so the writers started at the same time
+// do not flush at the same time. The jitter must be in [0, 1), 0 turns it off.
+func (b *Writer) SetFlushJitter(jitter float64) error {
+	if b.closed.Load() {
+		return ErrWriterClosed
+	}
+	if jitter < 0 || jitter >= 1 {
+		return errors.New("flush jitter must be in [0, 1)")
+	}
+	select {
+	case b.jitter <- jitter:
+		return nil
+	case <-b.done:
+		return ErrWriterClosed
+	}
+}
+
+// nextInterval returns the flush interval with a random jitter.
+func (b *Writer) nextInterval() time.Duration {
+	if b.flushJitter <= 0 {
+		return b.flushInterval
+	}
+	d := time.Duration(float64(b.flushInterval) * (1 + b.flushJitter*(2*rand.Float64()-1)))
+	if d <= 0 {
+		d = 1
 	}
-	b.flusher <- true
-	return nil
+	return d
 }
 
 // Close is an implementation of an io.Closer interface.
 // It closes the writer, stops any activity and any subsiquent operations
-// will result in a error.
-// It will return an error if called after Close() was called.
+// will result in a error. It waits for the final flush of the buffered data
+// and returns the error of its FlushFunc, which is passed to the
+// ErrorHandlerFunc as well.
+// It will return ErrWriterClosed if called after Close() was called.
 func (b *Writer) Close() error {
-	if b.closed {
-		return errors.New("closing a closed bulk.Writer")
+	if !b.closed.CompareAndSwap(false, true) {
+		return ErrWriterClosed
 	}
 
-	b.closed = true
-	close(b.quit)
-	if b.ticker != nil {
-		b.ticker.Stop()
+	// Wait for the writes in progress, the following ones see the writer
+	// closed, so the final flush has all the accepted data.
+	for i := range b.shards {
+		b.shards[i].mu.Lock()
+		b.shards[i].mu.Unlock()
 	}
-	return nil
+	close(b.quit)
+	b.limit.close()
+	<-b.done
+	return b.closeErr
 }
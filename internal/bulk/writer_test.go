@@ -1,7 +1,9 @@
 package bulk
 
 import (
+	"errors"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -94,3 +96,319 @@ func TestWriter_Flush(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestWriter_SetFlushInterval(t *testing.T) {
+	var called int32
+	w := NewBulkWriter(0, func(data []byte) error {
+		atomic.AddInt32(&called, 1)
+		if string(data) != TestData {
+			t.Errorf("Unexpected data: %q", string(data))
+		}
+		return nil
+	})
+	_, err := w.Write([]byte(TestData))
+	if err != nil {
+		t.Errorf("Error writing to the writer: %s", err.Error())
+		t.FailNow()
+	}
+
+	err = w.SetFlushInterval(time.Millisecond)
+	if err != nil {
+		t.Errorf("Error setting the flush interval: %s", err.Error())
+		t.FailNow()
+	}
+	time.Sleep(10 * time.Millisecond)
+	if atomic.LoadInt32(&called) == 0 {
+		t.Error("FlushFunc was not called after turning on automatic flushing")
+		t.FailNow()
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Errorf("Error closing the writer: %s", err.Error())
+		t.FailNow()
+	}
+	if w.SetFlushInterval(time.Second) == nil {
+		t.Error("Expected an error on a closed writer")
+	}
+}
+
+func BenchmarkWriter_Write(b *testing.B) {
+	w := NewBulkWriter(10*time.Millisecond, func(data []byte) error { return nil })
+	defer w.Close()
+	data := []byte(TestData)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = w.Write(data)
+		}
+	})
+}
+
+func TestWriter_Preallocate(t *testing.T) {
+	w := NewBulkWriter(0, func(data []byte) error { return nil })
+	defer w.Close()
+	w.Preallocate(1 << 20)
+	time.Sleep(10 * time.Millisecond)
+
+	data := make([]byte, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = w.Write(data)
+	})
+	if allocs != 0 {
+		t.Errorf("Unexpected allocations per write: %v", allocs)
+	}
+}
+
+func TestWriter_SetMaxSize(t *testing.T) {
+	t.Run("block", func(t *testing.T) {
+		var flushed int64
+		w := NewBulkWriter(0, func(data []byte) error {
+			atomic.AddInt64(&flushed, int64(len(data)))
+			return nil
+		})
+		w.SetMaxSize(2*len(TestData), Block)
+		for i := 0; i < 10; i++ {
+			if _, err := w.Write([]byte(TestData)); err != nil {
+				t.Fatalf("Error writing to the writer: %s", err.Error())
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Error closing the writer: %s", err.Error())
+		}
+		time.Sleep(10 * time.Millisecond)
+		if got := atomic.LoadInt64(&flushed); got != int64(10*len(TestData)) {
+			t.Errorf("unexpected flushed size: %d", got)
+		}
+		if w.Dropped() != 0 {
+			t.Errorf("unexpected dropped count: %d", w.Dropped())
+		}
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		w := NewBulkWriter(0, func(data []byte) error { return nil })
+		defer w.Close()
+		w.SetMaxSize(2*len(TestData), DropNewest)
+		for i := 0; i < 2; i++ {
+			if _, err := w.Write([]byte(TestData)); err != nil {
+				t.Fatalf("Error writing to the writer: %s", err.Error())
+			}
+		}
+		if _, err := w.Write([]byte(TestData)); err != ErrBufferFull {
+			t.Fatalf("expected ErrBufferFull, got %v", err)
+		}
+		if w.Dropped() != 1 {
+			t.Errorf("unexpected dropped count: %d", w.Dropped())
+		}
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		flushed := make(chan string, 1)
+		w := NewBulkWriter(0, func(data []byte) error {
+			flushed <- string(data)
+			return nil
+		})
+		defer w.Close()
+		w.SetMaxSize(8, DropOldest)
+		var dropped []string
+		w.SetDropHandler(func(data []byte) {
+			dropped = append(dropped, string(data))
+		})
+		for _, data := range []string{"first\n", "second\n", "third\n"} {
+			if _, err := w.Write([]byte(data)); err != nil {
+				t.Fatalf("Error writing to the writer: %s", err.Error())
+			}
+		}
+		if len(dropped) != 2 || dropped[0] != "first\n" || dropped[1] != "second\n" {
+			t.Errorf("unexpected dropped data: %q", dropped)
+		}
+		if w.Dropped() != 2 {
+			t.Errorf("unexpected dropped count: %d", w.Dropped())
+		}
+		if _, err := w.Write([]byte(TestData)); err != ErrBufferFull {
+			t.Errorf("expected ErrBufferFull for a write larger than the maximum size, got %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("Error flushing the writer: %s", err.Error())
+		}
+		if data := <-flushed; data != "third\n" {
+			t.Errorf("unexpected flushed data: %q", data)
+		}
+	})
+}
+
+func TestWriter_SetRetry(t *testing.T) {
+	var failing int32 = 1
+	flushed := make(chan string, 10)
+	handled := make(chan string, 10)
+	w := NewBulkWriterWithErrorHandler(0,
+		func(data []byte) error {
+			flushed <- string(data)
+			if atomic.LoadInt32(&failing) == 1 {
+				return errors.New("unavailable")
+			}
+			return nil
+		},
+		func(data []byte, err error) {
+			handled <- string(data)
+		},
+	)
+	w.SetRetry(2, 5)
+	flush := func(data string) string {
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		return <-flushed
+	}
+
+	// the kept data are flushed again ahead of the new data
+	if data := flush("a"); data != "a" {
+		t.Errorf("Unexpected data: %q", data)
+	}
+	if data := flush("b"); data != "ab" {
+		t.Errorf("Unexpected data: %q", data)
+	}
+	atomic.StoreInt32(&failing, 0)
+	if data := flush("c"); data != "abc" {
+		t.Errorf("Unexpected data: %q", data)
+	}
+
+	// given up on after the retries
+	atomic.StoreInt32(&failing, 1)
+	flush("d")
+	flush("e")
+	if data := flush("f"); data != "def" {
+		t.Errorf("Unexpected data: %q", data)
+	}
+	if data := <-handled; data != "def" {
+		t.Errorf("Unexpected data passed to the error handler: %q", data)
+	}
+
+	// or once they are too large
+	flush("ghi")
+	flush("jkl")
+	if data := <-handled; data != "ghijkl" {
+		t.Errorf("Unexpected data passed to the error handler: %q", data)
+	}
+
+	// and when the writer is closed
+	flush("m")
+	if err := w.Close(); err == nil {
+		t.Error("Expected the error of the final flush")
+	}
+	if data := <-handled; data != "m" {
+		t.Errorf("Unexpected data passed to the error handler: %q", data)
+	}
+}
+
+func TestWriter_SetFlushJitter(t *testing.T) {
+	var called int32
+	w := NewBulkWriter(20*time.Millisecond, func(data []byte) error {
+		atomic.AddInt32(&called, 1)
+		return nil
+	})
+	if err := w.SetFlushJitter(1); err == nil {
+		t.Error("Expected an error for a jitter of 1")
+	}
+	if err := w.SetFlushJitter(0.5); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte(TestData)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(40 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&called); n < 3 {
+		t.Errorf("Expected the automatic flushes to go on, got %d", n)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the intervals vary within the jitter
+	w = &Writer{flushInterval: time.Second, flushJitter: 0.2}
+	varied := false
+	for i := 0; i < 100; i++ {
+		d := w.nextInterval()
+		if d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("Interval out of the jitter: %s", d)
+		}
+		varied = varied || d != time.Second
+	}
+	if !varied {
+		t.Error("Expected the intervals to vary")
+	}
+}
+
+func TestWriter_Close(t *testing.T) {
+	var flushed int32
+	failure := errors.New("unavailable")
+	w := NewBulkWriter(0, func(data []byte) error {
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&flushed, 1)
+		return failure
+	})
+	if _, err := w.Write([]byte(TestData)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != failure {
+		t.Errorf("Expected the error of the final flush, got %v", err)
+	}
+	if atomic.LoadInt32(&flushed) != 1 {
+		t.Error("Expected Close to wait for the final flush")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Expected an error closing a closed writer")
+	}
+}
+
+func TestWriter_ConcurrentClose(t *testing.T) {
+	var written, flushed int64
+	w := NewBulkWriter(time.Millisecond, func(data []byte) error {
+		atomic.AddInt64(&flushed, int64(len(data)))
+		return nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				n, err := w.Write([]byte(TestData))
+				atomic.AddInt64(&written, int64(n))
+				if err != nil {
+					if !errors.Is(err, ErrWriterClosed) {
+						t.Errorf("Unexpected error: %s", err)
+					}
+					return
+				}
+				if err := w.Flush(); err != nil && !errors.Is(err, ErrWriterClosed) {
+					t.Errorf("Unexpected error: %s", err)
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	closed := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			closed <- w.Close()
+		}()
+	}
+	if err1, err2 := <-closed, <-closed; (err1 == nil) == (err2 == nil) || !errors.Is(err1, ErrWriterClosed) && !errors.Is(err2, ErrWriterClosed) {
+		t.Errorf("Expected a single Close to succeed, got %v and %v", err1, err2)
+	}
+	wg.Wait()
+	if written != flushed {
+		t.Errorf("Expected every accepted write to be flushed, %d bytes written and %d flushed", written, flushed)
+	}
+	if err := w.SetFlushInterval(time.Second); !errors.Is(err, ErrWriterClosed) {
+		t.Errorf("Expected ErrWriterClosed, got %v", err)
+	}
+}
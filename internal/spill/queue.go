@@ -0,0 +1,281 @@
+// Package spill implements a FIFO of byte batches stored on disk, used to keep
+// documents that cannot be delivered while the cluster is unavailable.
+//
+// The batches are NDJSON bulk bodies, appended as they are to segment files of
+// a bounded size, so a segment can be replayed with any bulk tool. The files
+// are recycled once their batches are removed, and the batches are read into
+// pooled buffers, so a sustained outage does not cause any allocations per
+// batch.
+package spill
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const segmentExt = ".ndjson"
+
+// ErrTooLarge is returned when a batch does not fit in a segment.
+var ErrTooLarge = errors.New("batch larger than the segment size")
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(Buffer) },
+}
+
+// Buffer holds the batches of a segment returned by Queue.Read.
+type Buffer struct {
+	data []byte
+}
+
+// Bytes returns the batches of the segment.
+func (b *Buffer) Bytes() []byte {
+	return b.data
+}
+
+// String returns the batches of the segment as a string.
+func (b *Buffer) String() string {
+	return string(b.data)
+}
+
+// Release returns the buffer to the pool, it must not be used afterwards.
+func (b *Buffer) Release() {
+	b.data = b.data[:0]
+	bufferPool.Put(b)
+}
+
+type segment struct {
+	file *os.File
+	size int64 // bytes used by the batches, the size of the file
+}
+
+// Queue is a FIFO of byte batches stored in segment files of a directory.
+type Queue struct {
+	mu          sync.Mutex
+	dir         string
+	segmentSize int64
+	seq         int
+	segments    []*segment // the oldest first, the last one is written to
+	free        []*os.File // removed segments waiting to be reused, truncated
+	scratch     []byte
+}
+
+// Open opens the queue stored in dir, creating the directory if needed.
+// segmentSize is the size of the segment files, it limits the size of a batch.
+func Open(dir string, segmentSize int64) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	q := &Queue{dir: dir, segmentSize: segmentSize}
+	for _, name := range names {
+		var seq int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(filepath.Base(name), segmentExt), "%d", &seq); err != nil {
+			continue
+		}
+		f, err := os.OpenFile(name, os.O_RDWR, 0)
+		if err != nil {
+			q.Close()
+			return nil, err
+		}
+		s := &segment{file: f}
+		if s.size, err = recoverSize(f); err != nil {
+			f.Close()
+			q.Close()
+			return nil, err
+		}
+		q.segments = append(q.segments, s)
+		q.seq = seq
+	}
+	return q, nil
+}
+
+// recoverSize finds the end of the complete bulk items of a segment, i.e. after an
+// even number of lines, and truncates the segment there: a batch being written
+// when the process stopped may be cut anywhere.
+func recoverSize(f *os.File) (int64, error) {
+	var buf [32 << 10]byte
+	var off, end, prev int64
+	lines := 0
+	for {
+		n, err := f.ReadAt(buf[:], off)
+		for i := 0; i < n; i++ {
+			if buf[i] == '\n' {
+				lines++
+				prev, end = end, off+int64(i)+1
+			}
+		}
+		off += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if lines%2 == 1 {
+		end = prev
+	}
+	if end != off {
+		if err := f.Truncate(end); err != nil {
+			return 0, err
+		}
+	}
+	return end, nil
+}
+
+// Write appends a batch of NDJSON lines to the queue.
+func (q *Queue) Write(batch []byte) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if int64(len(batch)) > q.segmentSize {
+		return ErrTooLarge
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if batch[len(batch)-1] != '\n' {
+		// every line must end with a newline for the next batch
+		if int64(len(batch)+1) > q.segmentSize {
+			return ErrTooLarge
+		}
+		q.scratch = append(append(q.scratch[:0], batch...), '\n')
+		batch = q.scratch
+	}
+	if len(q.segments) == 0 || q.segments[len(q.segments)-1].size+int64(len(batch)) > q.segmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+	s := q.segments[len(q.segments)-1]
+	if _, err := s.file.WriteAt(batch, s.size); err != nil {
+		// drop what was written of the batch
+		_ = s.file.Truncate(s.size)
+		return err
+	}
+	s.size += int64(len(batch))
+	return nil
+}
+
+// rotate starts a new segment, reusing a removed one if possible.
+func (q *Queue) rotate() error {
+	q.seq++
+	name := filepath.Join(q.dir, fmt.Sprintf("%016d%s", q.seq, segmentExt))
+	var f *os.File
+	if n := len(q.free); n > 0 {
+		f, q.free = q.free[n-1], q.free[:n-1]
+		if err := os.Rename(f.Name(), name); err != nil {
+			f.Close()
+			return err
+		}
+		// The file keeps its name for reading and writing.
+		f.Close()
+		var err error
+		if f, err = os.OpenFile(name, os.O_RDWR, 0); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if f, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o640); err != nil {
+			return err
+		}
+	}
+	q.segments = append(q.segments, &segment{file: f})
+	return nil
+}
+
+// Peek returns the batches of the oldest segment, concatenated in the order
+// they were written, without removing them from the queue: the segment is
+// removed by Remove once they are processed, so they are read again after a
+// failure or a restart. It returns io.EOF if the queue is empty. The buffer
+// should be released once it is processed.
+func (q *Queue) Peek() (*Buffer, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.segments) > 1 && q.segments[0].size == 0 {
+		q.free = append(q.free, q.segments[0].file)
+		q.segments = q.segments[1:]
+	}
+	if len(q.segments) == 0 || q.segments[0].size == 0 {
+		return nil, io.EOF
+	}
+	if len(q.segments) == 1 {
+		// the next batches go to another segment, so Remove does not drop them
+		if err := q.rotate(); err != nil {
+			return nil, err
+		}
+	}
+	s := q.segments[0]
+	buf := bufferPool.Get().(*Buffer)
+	if int64(cap(buf.data)) < s.size {
+		buf.data = make([]byte, s.size)
+	}
+	buf.data = buf.data[:s.size]
+	if _, err := s.file.ReadAt(buf.data, 0); err != nil {
+		buf.Release()
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Remove removes the oldest segment, whose batches were returned by Peek, from
+// the queue. The file is emptied and reused for the next segments.
+func (q *Queue) Remove() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.segments) < 2 {
+		// the segment being written was not peeked
+		return nil
+	}
+	s := q.segments[0]
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	q.segments = q.segments[1:]
+	q.free = append(q.free, s.file)
+	return nil
+}
+
+// Len returns the number of bytes the segment files take.
+func (q *Queue) Len() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var n int64
+	for _, s := range q.segments {
+		n += s.size
+	}
+	return n
+}
+
+// Close closes the segment files. Segments that were removed are deleted,
+// the others are kept to be read after the queue is opened again.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var errs []error
+	for _, f := range q.free {
+		errs = append(errs, f.Close(), os.Remove(f.Name()))
+	}
+	for _, s := range q.segments {
+		errs = append(errs, s.file.Close())
+	}
+	q.free, q.segments = nil, nil
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
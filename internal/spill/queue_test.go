@@ -0,0 +1,163 @@
+package spill
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, 64)
+	if err != nil {
+		t.Fatalf("Error opening the queue: %s", err)
+	}
+	for _, batch := range []string{"{}\nfirst\n", "{}\nsecond\n", "{}\na batch that needs a new segment, really really\n", "{}\nlast"} {
+		if err := q.Write([]byte(batch)); err != nil {
+			t.Fatalf("Error writing %q: %s", batch, err)
+		}
+	}
+	if err := q.Write(make([]byte, 65)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+
+	// The segments hold the batches as they are.
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 segment files, got %v", files)
+	}
+	if data, err := os.ReadFile(files[0]); err != nil || string(data) != "{}\nfirst\n{}\nsecond\n" {
+		t.Errorf("Unexpected first segment file: %q %v", data, err)
+	}
+
+	// A segment stays in the queue until it is removed.
+	for i := 0; i < 2; i++ {
+		buf, err := q.Peek()
+		if err != nil {
+			t.Fatalf("Error reading the queue: %s", err)
+		}
+		if buf.String() != "{}\nfirst\n{}\nsecond\n" {
+			t.Errorf("Unexpected first segment: %q", buf.String())
+		}
+		buf.Release()
+	}
+	if err := q.Remove(); err != nil {
+		t.Fatalf("Error removing a segment: %s", err)
+	}
+
+	// The removed segment is emptied and reused rather than a new file created.
+	if fi, err := os.Stat(files[0]); err != nil || fi.Size() != 0 {
+		t.Errorf("Expected the removed segment to be emptied: %v %v", fi, err)
+	}
+	if err := q.Write([]byte("{}\nafter read\n")); err != nil {
+		t.Fatal(err)
+	}
+	files, _ = filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(files) != 2 {
+		t.Errorf("Expected 2 segment files, got %v", files)
+	}
+	var size int64
+	for _, name := range files {
+		if fi, err := os.Stat(name); err == nil {
+			size += fi.Size()
+		}
+	}
+	if size != q.Len() {
+		t.Errorf("Expected the files to take %d bytes, got %d", q.Len(), size)
+	}
+
+	// Unread segments survive reopening, a batch cut by a crash is dropped.
+	if err := q.Close(); err != nil {
+		t.Fatalf("Error closing the queue: %s", err)
+	}
+	f, err := os.OpenFile(files[len(files)-1], os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("{}\ncut"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	q, err = Open(dir, 64)
+	if err != nil {
+		t.Fatalf("Error reopening the queue: %s", err)
+	}
+	size = q.Len()
+	// a segment peeked but not removed is kept, e.g. if the process stopped
+	// before it was processed
+	if buf, err := q.Peek(); err != nil {
+		t.Fatal(err)
+	} else {
+		buf.Release()
+	}
+	q.Close()
+	q, err = Open(dir, 64)
+	if err != nil {
+		t.Fatalf("Error reopening the queue: %s", err)
+	}
+	defer q.Close()
+	if q.Len() != size {
+		t.Errorf("Expected %d bytes after reopening, got %d", size, q.Len())
+	}
+	var got string
+	for {
+		buf, err := q.Peek()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading the queue: %s", err)
+		}
+		got += buf.String()
+		buf.Release()
+		if strings.HasSuffix(got, "after read\n") {
+			// the batches written after the last segment was peeked are kept
+			if err := q.Write([]byte("{}\nafter peek\n")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := q.Remove(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected := "{}\na batch that needs a new segment, really really\n{}\nlast\n{}\nafter read\n{}\nafter peek\n"; got != expected {
+		t.Errorf("Unexpected batches: %q", got)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Expected an empty queue, got %d bytes", q.Len())
+	}
+}
+
+func BenchmarkQueue(b *testing.B) {
+	q, err := Open(b.TempDir(), 1<<20)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer q.Close()
+	batch := make([]byte, 16<<10)
+	for i := range batch {
+		batch[i] = 'x'
+	}
+	batch[len(batch)/2], batch[len(batch)-1] = '\n', '\n'
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(batch)))
+	for i := 0; i < b.N; i++ {
+		if err := q.Write(batch); err != nil {
+			b.Fatal(err)
+		}
+		if i%32 == 31 {
+			buf, err := q.Peek()
+			if err != nil {
+				b.Fatal(err)
+			}
+			buf.Release()
+			if err := q.Remove(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package elogrus
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// levelLabels holds the registered level labels, replaced on every registration
+	levelLabels   atomic.Pointer[map[logrus.Level]string]
+	levelLabelsMu sync.Mutex
+)
+
+// RegisterLevelLabel sets the label of a level in the documents of all hooks,
+// e.g. "CRITICAL" for logrus.PanicLevel or "NOTICE" for logrus.InfoLevel. By
+// default, the label is the upper-cased name of the level (e.g. "WARNING").
+// It is meant to be called at startup, e.g. from an init function.
+func RegisterLevelLabel(level logrus.Level, label string) {
+	levelLabelsMu.Lock()
+	defer levelLabelsMu.Unlock()
+	labels := make(map[logrus.Level]string)
+	if current := levelLabels.Load(); current != nil {
+		for l, s := range *current {
+			labels[l] = s
+		}
+	}
+	labels[level] = label
+	levelLabels.Store(&labels)
+}
+
+// SetLevelLabels overrides the level labels of the hook, levels missing from
+// labels keep the registered (or default) label, see RegisterLevelLabel.
+func (hook *ElasticHook) SetLevelLabels(labels map[logrus.Level]string) {
+	hook.levelLabels = make(map[logrus.Level]string, len(labels))
+	for level, label := range labels {
+		hook.levelLabels[level] = label
+	}
+}
+
+// levelLabel returns the label of a level in the documents of the hook.
+func (hook *ElasticHook) levelLabel(level logrus.Level) string {
+	if label, ok := hook.levelLabels[level]; ok {
+		return label
+	}
+	if labels := levelLabels.Load(); labels != nil {
+		if label, ok := (*labels)[level]; ok {
+			return label
+		}
+	}
+	return strings.ToUpper(level.String())
+}
+
+// parseLevelLabel returns the level of a label in the documents of the hook.
+func (hook *ElasticHook) parseLevelLabel(label string) (logrus.Level, bool) {
+	for _, level := range logrus.AllLevels {
+		if strings.EqualFold(hook.levelLabel(level), label) {
+			return level, true
+		}
+	}
+	level, err := logrus.ParseLevel(strings.ToLower(label))
+	return level, err == nil
+}
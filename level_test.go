@@ -0,0 +1,44 @@
+package elogrus
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLevelLabels(t *testing.T) {
+	RegisterLevelLabel(logrus.WarnLevel, "ALERT")
+	t.Cleanup(func() { levelLabels.Store(nil) })
+
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "labels-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	hook.SetLevelLabels(map[logrus.Level]string{logrus.InfoLevel: "NOTICE"})
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+
+	logger.Info("notice")
+	logger.Warn("alert")
+	logger.Error("error")
+	docs := f.Requests(http.MethodPost, "/labels-log/_doc")
+	if len(docs) != 3 {
+		t.Fatalf("Expected 3 documents, got %d", len(docs))
+	}
+	for i, label := range []string{"NOTICE", "ALERT", "ERROR"} {
+		if !strings.Contains(docs[i].Body, `"level":"`+label+`"`) {
+			t.Errorf("Expected the %s label: %s", label, docs[i].Body)
+		}
+	}
+
+	for label, expected := range map[string]logrus.Level{"notice": logrus.InfoLevel, "ALERT": logrus.WarnLevel, "ERROR": logrus.ErrorLevel} {
+		if level, ok := hook.parseLevelLabel(label); !ok || level != expected {
+			t.Errorf("Unexpected level of %s: %s", label, level)
+		}
+	}
+}
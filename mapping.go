@@ -0,0 +1,81 @@
+package elogrus
+
+// DataKeywordMaxLength is the longest string of the data fields indexed as a
+// keyword by DefaultMappings, longer strings are only searchable as text.
+const DataKeywordMaxLength = 256
+
+// MappingOptions adjust the mappings returned by Mappings.
+type MappingOptions struct {
+	// FlattenedData maps the data object to the flattened type: all its fields,
+	// whatever their names and depth, are indexed as keywords under a single
+	// field mapping. It protects the cluster from mapping explosions when the
+	// applications log arbitrary keys, at the cost of numeric and full-text
+	// queries on the data fields. Requires Elasticsearch 7.3+.
+	FlattenedData bool
+}
+
+// Mappings returns DefaultMappings adjusted by opts.
+func Mappings(opts MappingOptions) map[string]interface{} {
+	mappings := DefaultMappings()
+	if opts.FlattenedData {
+		mappings["properties"].(map[string]interface{})["data"] = map[string]interface{}{
+			"type":         "flattened",
+			"ignore_above": DataKeywordMaxLength,
+		}
+		delete(mappings, "dynamic_templates")
+	}
+	return mappings
+}
+
+// DefaultMappings returns the index mappings for the documents of the hook.
+// They are applied when the hook creates its index and, unless other mappings
+// are configured, by Bootstrap to the index template:
+//
+//   - @timestamp is a date
+//   - message is full-text searchable, with a message.keyword subfield for
+//     sorting and aggregations of short messages
+//   - host, level, file, func, trace.id and span.id are keywords
+//   - the fields of the entries (data) are mapped dynamically, strings as
+//     keywords (aggregatable, up to DataKeywordMaxLength characters) with a
+//     text subfield (e.g. data.error.text) for full-text search of any length
+//
+// The result is a new map on every call, so it can be modified.
+func DefaultMappings() map[string]interface{} {
+	keyword := func() map[string]interface{} {
+		return map[string]interface{}{"type": "keyword", "ignore_above": 1024}
+	}
+	return map[string]interface{}{
+		"properties": map[string]interface{}{
+			"@timestamp": map[string]interface{}{"type": "date"},
+			"host":       keyword(),
+			"level":      keyword(),
+			"file":       keyword(),
+			"func":       keyword(),
+			"message": map[string]interface{}{
+				"type": "text",
+				"fields": map[string]interface{}{
+					"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256},
+				},
+			},
+			"trace":          map[string]interface{}{"properties": map[string]interface{}{"id": keyword()}},
+			"span":           map[string]interface{}{"properties": map[string]interface{}{"id": keyword()}},
+			"schema_version": map[string]interface{}{"type": "integer"},
+			"data":           map[string]interface{}{"type": "object", "dynamic": true},
+		},
+		"dynamic_templates": []interface{}{
+			map[string]interface{}{
+				"data_strings": map[string]interface{}{
+					"path_match":         "data.*",
+					"match_mapping_type": "string",
+					"mapping": map[string]interface{}{
+						"type":         "keyword",
+						"ignore_above": DataKeywordMaxLength,
+						"fields": map[string]interface{}{
+							"text": map[string]interface{}{"type": "text"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
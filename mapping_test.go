@@ -0,0 +1,47 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMappings(t *testing.T) {
+	data, err := json.Marshal(Mappings(MappingOptions{FlattenedData: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mappings struct {
+		Properties map[string]struct {
+			Type string `json:"type"`
+		} `json:"properties"`
+		DynamicTemplates []interface{} `json:"dynamic_templates"`
+	}
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		t.Fatal(err)
+	}
+	if mappings.Properties["data"].Type != "flattened" || mappings.DynamicTemplates != nil {
+		t.Errorf("Unexpected mappings: %s", data)
+	}
+	if mappings.Properties["message"].Type != "text" {
+		t.Errorf("Expected the other fields to keep their mappings: %s", data)
+	}
+
+	if _, ok := DefaultMappings()["dynamic_templates"]; !ok {
+		t.Error("Expected the default mappings to be unaffected")
+	}
+}
+
+func TestDefaultMappingsFieldsAreDistinct(t *testing.T) {
+	mappings := DefaultMappings()
+	properties := mappings["properties"].(map[string]interface{})
+	properties["host"].(map[string]interface{})["ignore_above"] = 64
+	for _, name := range []string{"level", "file", "func"} {
+		if above := properties[name].(map[string]interface{})["ignore_above"]; above != 1024 {
+			t.Errorf("Expected the %s field to keep its mapping, got ignore_above %v", name, above)
+		}
+	}
+	trace := properties["trace"].(map[string]interface{})["properties"].(map[string]interface{})["id"]
+	if above := trace.(map[string]interface{})["ignore_above"]; above != 1024 {
+		t.Errorf("Expected the trace.id field to keep its mapping, got ignore_above %v", above)
+	}
+}
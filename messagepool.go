@@ -0,0 +1,47 @@
+package elogrus
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxPooledFields is the number of data fields above which the map of a
+// message is not pooled: maps never shrink, a few large entries would keep
+// their memory around for good.
+const maxPooledFields = 128
+
+// maxPooledBuffer is the capacity above which a buffer is not pooled, see
+// maxPooledFields.
+const maxPooledBuffer = 64 << 10
+
+// messagePool holds the messages created by createMessage with their data
+// fields, reused from one entry to the next to spare the allocations.
+var messagePool = sync.Pool{
+	New: func() interface{} { return &Message{Data: logrus.Fields{}} },
+}
+
+// bufferPool holds the buffers compacting the output of the formatters.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// getMessage returns an empty message with an empty data map from the pool.
+func getMessage() *Message {
+	return messagePool.Get().(*Message)
+}
+
+// putMessage returns a message to the pool once it has been serialized, it
+// must not be used afterwards.
+func putMessage(msg *Message) {
+	data := msg.Data
+	if len(data) > maxPooledFields {
+		return
+	}
+	for k := range data {
+		delete(data, k)
+	}
+	*msg = Message{Data: data}
+	messagePool.Put(msg)
+}
@@ -0,0 +1,68 @@
+package elogrus
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// IDFunc returns the _id of the document of an entry, see SetIDFunc.
+type IDFunc func(entry *logrus.Entry) string
+
+// RoutingFunc returns the routing value of the document of an entry, see SetRouting.
+type RoutingFunc func(entry *logrus.Entry) string
+
+// SetIDFunc makes the hook set the _id of every document to the result of
+// idFunc, e.g. a hash of the entry or an event ID from its fields. A document
+// replaces the document with the same _id, so an entry delivered twice (retried
+// after a timeout, replayed from the spill directory or logged again by a
+// restarted process) is indexed once. An empty _id is generated by the cluster.
+func (hook *ElasticHook) SetIDFunc(idFunc IDFunc) {
+	hook.idFunc = idFunc
+}
+
+// SetRouting makes the hook route every document to the shard selected by the
+// result of routingFunc instead of its _id, e.g. to keep the entries of an
+// application together on large clusters. See StaticRouting and FieldRouting.
+// An empty value routes the document by its _id.
+func (hook *ElasticHook) SetRouting(routingFunc RoutingFunc) {
+	hook.routingFunc = routingFunc
+}
+
+// StaticRouting returns a RoutingFunc routing all the documents with value.
+func StaticRouting(value string) RoutingFunc {
+	return func(*logrus.Entry) string {
+		return value
+	}
+}
+
+// FieldRouting returns a RoutingFunc routing the documents with the value of
+// the key data field of their entries. The entries without it are routed by
+// the _id of their documents.
+func FieldRouting(key string) RoutingFunc {
+	return func(entry *logrus.Entry) string {
+		switch v := entry.Data[key].(type) {
+		case nil:
+			return ""
+		case string:
+			return v
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+}
+
+// documentMeta returns the metadata of the document of an entry, see SetIDFunc,
+// SetRouting and WithDataStream.
+func (hook *ElasticHook) documentMeta(entry *logrus.Entry) core.DocumentMeta {
+	meta := core.DocumentMeta{Create: hook.dataStream}
+	if hook.idFunc != nil {
+		meta.ID = hook.idFunc(entry)
+	}
+	if hook.routingFunc != nil {
+		meta.Routing = hook.routingFunc(entry)
+	}
+	return meta
+}
@@ -0,0 +1,103 @@
+package elogrus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestSetIDFunc(t *testing.T) {
+	f, client := newFakeElastic(t)
+	idFunc := func(entry *logrus.Entry) string {
+		id, _ := entry.Data["event_id"].(string)
+		return id
+	}
+	hook, err := NewElasticHookWithOptions(es8.New(client), WithIndex("id-log"), WithIDFunc(idFunc))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	var ids []string
+	hook.WriteHandler = func(entry *logrus.Entry, res *IndexResponse) {
+		ids = append(ids, res.ID)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	logger.WithField("event_id", "ev-1").Info("with id")
+	logger.Info("without id")
+
+	if reqs := f.Requests(http.MethodPut, "/id-log/_doc/ev-1"); len(reqs) != 1 {
+		t.Errorf("Expected the document to be put with its id, got %d requests", len(reqs))
+	}
+	if reqs := f.Requests(http.MethodPost, "/id-log/_doc"); len(reqs) != 1 {
+		t.Errorf("Expected the document without id to be posted, got %d requests", len(reqs))
+	}
+	if len(ids) != 2 || ids[0] != "ev-1" {
+		t.Errorf("Unexpected ids: %q", ids)
+	}
+
+	hook, err = NewElasticHookWithOptions(es8.New(client), WithIndex("id-log"), WithIDFunc(idFunc),
+		WithDeliveryMode(BulkDelivery))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("bulk").AddField("event_id", "ev-2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("bulk")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reqs := f.Requests(http.MethodPost, "/id-log/_bulk")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one bulk request, got %d", len(reqs))
+	}
+	if !strings.Contains(reqs[0].Body, `{"index":{"_id":"ev-2"}}`) || !strings.Contains(reqs[0].Body, `{"index":{}}`) {
+		t.Errorf("Unexpected bulk request: %s", reqs[0].Body)
+	}
+}
+
+func TestSetRouting(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client), WithIndex("routing-log"), WithRouting(StaticRouting("app-1")))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("static")); err != nil {
+		t.Fatal(err)
+	}
+	if reqs := f.Requests(http.MethodPost, "/routing-log/_doc"); len(reqs) != 1 || reqs[0].Query != "routing=app-1" {
+		t.Errorf("Expected the document to be routed: %+v", reqs)
+	}
+
+	hook, err = NewElasticHookWithOptions(es8.New(client), WithIndex("routing-log"), WithRouting(FieldRouting("tenant")),
+		WithDeliveryMode(BulkDelivery))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("field").AddField("tenant", 42)); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("no field")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reqs := f.Requests(http.MethodPost, "/routing-log/_bulk")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one bulk request, got %d", len(reqs))
+	}
+	if !strings.Contains(reqs[0].Body, `{"index":{"routing":"42"}}`) || !strings.Contains(reqs[0].Body, `{"index":{}}`) {
+		t.Errorf("Unexpected bulk request: %s", reqs[0].Body)
+	}
+}
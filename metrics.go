@@ -0,0 +1,20 @@
+package elogrus
+
+import "gopkg.in/go-extras/elogrus.v8/core"
+
+// Metrics receives the measurements of a hook, see SetMetrics.
+type Metrics = core.Metrics
+
+// NopMetrics discards all the measurements, it is the default Metrics.
+type NopMetrics = core.NopMetrics
+
+// SetMetrics makes the hook report to m the entries it accepts for delivery,
+// the documents it sends, fails to send and drops, the bytes of its requests,
+// the retries and the number of documents waiting in its queue, e.g. to export
+// them to Prometheus or expvar. A nil m discards them.
+func (hook *ElasticHook) SetMetrics(m Metrics) {
+	if m == nil {
+		m = NopMetrics{}
+	}
+	hook.stats.Metrics = m
+}
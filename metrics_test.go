@@ -0,0 +1,124 @@
+package elogrus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+// recordingMetrics sums the measurements of a hook.
+type recordingMetrics struct {
+	mu                       sync.Mutex
+	fired, sent, bytes       int
+	failed, dropped, retried int
+	depth                    int
+}
+
+func (m *recordingMetrics) EntryFired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fired++
+}
+
+func (m *recordingMetrics) DocumentsSent(docs int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent += docs
+}
+
+func (m *recordingMetrics) RequestSent(_, bytes int, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytes += bytes
+}
+
+func (m *recordingMetrics) DocumentsFailed(docs int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failed += docs
+}
+
+func (m *recordingMetrics) DocumentsDropped(docs int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped += docs
+}
+
+func (m *recordingMetrics) RequestRetried() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retried++
+}
+
+func (m *recordingMetrics) QueueDepth(docs int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.depth = docs
+}
+
+func TestSetMetrics(t *testing.T) {
+	for _, mode := range []DeliveryMode{SyncDelivery, AsyncDelivery, BulkDelivery} {
+		t.Run(mode.String(), func(t *testing.T) {
+			f, client := newFakeElastic(t)
+			f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/_doc") && strings.Contains(body, "rejected"):
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = io.WriteString(w, `{"error":{"type":"mapper_parsing_exception","reason":"failed"}}`)
+					return true
+				case strings.HasSuffix(r.URL.Path, "/_bulk"):
+					var items []string
+					lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+					for i := 1; i < len(lines); i += 2 {
+						if strings.Contains(lines[i], "rejected") {
+							items = append(items, `{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed"}}}`)
+						} else {
+							items = append(items, `{"index":{"status":201}}`)
+						}
+					}
+					_, _ = io.WriteString(w, `{"errors":true,"items":[`+strings.Join(items, ",")+`]}`)
+					return true
+				}
+				return false
+			}
+			m := &recordingMetrics{depth: -1}
+			hook, err := NewElasticHookWithOptions(es8.New(client),
+				WithIndex("metrics-log"),
+				WithDeliveryMode(mode),
+				WithMetrics(m),
+				WithConfig(Config{Level: logrus.InfoLevel, FlushInterval: DefaultFlushInterval, MaxDocumentSize: 200}),
+			)
+			if err != nil {
+				t.Fatalf("Error creating the hook: %s", err)
+			}
+			defer hook.Cancel()
+
+			for _, msg := range []string{"first", "second", "rejected", strings.Repeat("x", 300)} {
+				_ = hook.Submit(NewDocument().SetMessage(msg))
+			}
+			// the rejected entry fails the flush
+			_ = hook.Flush(context.Background())
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if m.fired != 4 || m.sent != 2 || m.failed != 1 || m.dropped != 1 {
+				t.Errorf("Expected 4 entries fired, 2 documents sent, 1 failed and 1 dropped, got %d, %d, %d and %d",
+					m.fired, m.sent, m.failed, m.dropped)
+			}
+			if m.bytes == 0 {
+				t.Error("Expected the bytes sent to be reported")
+			}
+			if mode != SyncDelivery && m.depth != 0 {
+				t.Errorf("Expected an empty queue, got %d", m.depth)
+			}
+		})
+	}
+}
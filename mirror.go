@@ -0,0 +1,63 @@
+package elogrus
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// DefaultMirrorQueueSize is the number of documents queued for the mirror,
+// the documents above it are dropped.
+const DefaultMirrorQueueSize = 10000
+
+// mirror sends copies of the documents of a hook to a second index.
+type mirror struct {
+	batcher *core.Batcher
+	limiter *core.Limiter
+}
+
+// SetMirror makes the hook write a copy of every document to a second index,
+// possibly in another cluster, e.g. during a migration or for redundancy across
+// regions. The documents are serialized once. The copies are sent in bulk by a
+// separate queue of DefaultMirrorQueueSize documents: they are best-effort, a
+// slow or unavailable mirror neither slows down nor fails the hook, the copies
+// that do not fit in the queue are dropped (see MirrorDropped). Failures of the
+// mirror are reported to the ErrorHandler.
+func (hook *ElasticHook) SetMirror(client core.Client, index string) error {
+	if index == "" {
+		return errors.New("mirror index must not be empty")
+	}
+	if hook.mirror != nil {
+		return errors.New("the hook already has a mirror")
+	}
+	if hook.serializer != nil {
+		return errors.New("bulk requests can only be sent as JSON")
+	}
+	m := &mirror{limiter: core.NewLimiter(DefaultMirrorQueueSize, false)}
+	m.batcher = core.NewBatcher(client, func() string { return index }, hook.config.Load().FlushInterval, core.DefaultBulkRetries,
+		func(err error, data []byte) {
+			hook.handleError(fmt.Errorf("mirror %s: %w", index, err), data)
+		})
+	m.batcher.SetLimiter(m.limiter)
+	hook.mirror = m
+	return nil
+}
+
+// MirrorDropped returns the number of documents the mirror dropped because its
+// queue was full.
+func (hook *ElasticHook) MirrorDropped() uint64 {
+	if hook.mirror == nil {
+		return 0
+	}
+	return hook.mirror.limiter.Dropped()
+}
+
+// mirrorDocument queues a copy of a document for the mirror, if any.
+func (hook *ElasticHook) mirrorDocument(meta core.DocumentMeta, data []byte) {
+	if hook.mirror == nil {
+		return
+	}
+	// ErrQueueFull is counted by the limiter
+	_ = hook.mirror.batcher.AddWithMeta("", meta, data)
+}
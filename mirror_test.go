@@ -0,0 +1,43 @@
+package elogrus
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestMirror(t *testing.T) {
+	f, client := newFakeElastic(t)
+	mirrorFake, mirrorClient := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "primary-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetMirror(es8.New(mirrorClient), "mirror-log"); err != nil {
+		t.Fatalf("Error setting the mirror: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	logger.Info("mirrored")
+	if err := hook.mirror.batcher.Flush(); err != nil {
+		t.Fatalf("Error flushing the mirror: %s", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	primary := f.Requests(http.MethodPost, "/primary-log/_doc")
+	mirrored := mirrorFake.Requests(http.MethodPost, "/mirror-log/_bulk")
+	if len(primary) != 1 || len(mirrored) != 1 {
+		t.Fatalf("Expected one primary and one mirrored request, got %d and %d", len(primary), len(mirrored))
+	}
+	if !strings.HasSuffix(mirrored[0].Body, primary[0].Body+"\n") {
+		t.Errorf("Mirrored document differs:\n%s\n%s", primary[0].Body, mirrored[0].Body)
+	}
+}
@@ -0,0 +1,130 @@
+// Package opensearch provides the elogrus client for OpenSearch.
+//
+// Lifecycle policies are created with the Index State Management plugin
+// instead of ILM.
+package opensearch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// Client implements core.Client for OpenSearch.
+type Client struct {
+	*core.RESTClient
+}
+
+// New creates a new Client performing requests with t, typically
+// a *opensearch.Client of github.com/opensearch-project/opensearch-go.
+func New(t core.Transport) *Client {
+	return &Client{core.NewRESTClient(t)}
+}
+
+// PutLifecyclePolicy implements core.Client, the policy is created as an ISM policy
+// attached to policy.IndexPatterns. The max_primary_shard_size rollover condition
+// is sent as min_size (the total size of the primary shards).
+func (c *Client) PutLifecyclePolicy(ctx context.Context, name string, policy core.LifecyclePolicy) error {
+	rollover := map[string]interface{}{}
+	if policy.Rollover.MaxAge != "" {
+		rollover["min_index_age"] = policy.Rollover.MaxAge
+	}
+	if policy.Rollover.MaxPrimaryShardSize != "" {
+		rollover["min_size"] = policy.Rollover.MaxPrimaryShardSize
+	}
+	if policy.Rollover.MaxDocs != 0 {
+		rollover["min_doc_count"] = policy.Rollover.MaxDocs
+	}
+	hot := map[string]interface{}{
+		"name":        "hot",
+		"actions":     []interface{}{map[string]interface{}{"rollover": rollover}},
+		"transitions": []interface{}{},
+	}
+	states := []interface{}{hot}
+	if policy.DeleteAfter != "" {
+		hot["transitions"] = []interface{}{map[string]interface{}{
+			"state_name": "delete",
+			"conditions": map[string]interface{}{"min_index_age": policy.DeleteAfter},
+		}}
+		states = append(states, map[string]interface{}{
+			"name":        "delete",
+			"actions":     []interface{}{map[string]interface{}{"delete": map[string]interface{}{}}},
+			"transitions": []interface{}{},
+		})
+	}
+	p := map[string]interface{}{
+		"description":   "managed by elogrus",
+		"default_state": "hot",
+		"states":        states,
+	}
+	if len(policy.IndexPatterns) > 0 {
+		p["ism_template"] = []interface{}{map[string]interface{}{
+			"index_patterns": policy.IndexPatterns,
+			"priority":       100,
+		}}
+	}
+	body, err := json.Marshal(map[string]interface{}{"policy": p})
+	if err != nil {
+		return err
+	}
+
+	// ISM policies can only be updated by passing the sequence number of the current version
+	path := "/_plugins/_ism/policies/" + url.PathEscape(name)
+	var current struct {
+		SeqNo       int64 `json:"_seq_no"`
+		PrimaryTerm int64 `json:"_primary_term"`
+	}
+	var query url.Values
+	err = c.Do(ctx, core.Request{Method: http.MethodGet, Path: path}, &current)
+	var e *core.ResponseError
+	switch {
+	case err == nil:
+		query = url.Values{
+			"if_seq_no":       {strconv.FormatInt(current.SeqNo, 10)},
+			"if_primary_term": {strconv.FormatInt(current.PrimaryTerm, 10)},
+		}
+	case !errors.As(err, &e) || e.StatusCode != http.StatusNotFound:
+		return err
+	}
+	return c.Do(ctx, core.Request{Method: http.MethodPut, Path: path, Query: query, Body: body}, nil)
+}
+
+// Rollover implements core.Client. The max_primary_shard_size condition is sent
+// as max_size (the total size of the primary shards).
+func (c *Client) Rollover(ctx context.Context, alias string, conditions core.RolloverConditions) (*core.RolloverResponse, error) {
+	rollover := map[string]interface{}{}
+	if conditions.MaxAge != "" {
+		rollover["max_age"] = conditions.MaxAge
+	}
+	if conditions.MaxPrimaryShardSize != "" {
+		rollover["max_size"] = conditions.MaxPrimaryShardSize
+	}
+	if conditions.MaxDocs != 0 {
+		rollover["max_docs"] = conditions.MaxDocs
+	}
+	return c.RolloverWith(ctx, alias, rollover)
+}
+
+// PutIndexTemplate implements core.Client. The lifecycle policy is attached
+// by the policy itself, only the rollover alias is set on the template.
+func (c *Client) PutIndexTemplate(ctx context.Context, name string, template core.IndexTemplate) error {
+	t := map[string]interface{}{
+		"settings": core.TemplateSettings(template, "", "plugins.index_state_management.rollover_alias"),
+	}
+	if template.Mappings != nil {
+		t["mappings"] = template.Mappings
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"index_patterns": template.IndexPatterns,
+		"template":       t,
+	})
+	if err != nil {
+		return err
+	}
+	return c.Do(ctx, core.Request{Method: http.MethodPut, Path: "/_index_template/" + url.PathEscape(name), Body: body}, nil)
+}
@@ -0,0 +1,67 @@
+package opensearch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+type request struct {
+	Method, Path, Query, Body string
+}
+
+func TestPutLifecyclePolicy(t *testing.T) {
+	var requests []request
+	existing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{r.Method, r.URL.Path, r.URL.RawQuery, string(body)})
+		if r.Method == http.MethodGet && !existing {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, `{"_id":"logs","_seq_no":7,"_primary_term":1}`)
+	}))
+	defer server.Close()
+	transport, err := core.NewHTTPTransport(server.URL, nil)
+	if err != nil {
+		t.Fatalf("Error creating the transport: %s", err)
+	}
+	c := New(transport)
+
+	policy := core.LifecyclePolicy{
+		IndexPatterns: []string{"logs-*"},
+		Rollover:      core.RolloverConditions{MaxAge: "1d"},
+		DeleteAfter:   "30d",
+	}
+	if err := c.PutLifecyclePolicy(context.Background(), "logs", policy); err != nil {
+		t.Fatal(err)
+	}
+	existing = true
+	if err := c.PutLifecyclePolicy(context.Background(), "logs", policy); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"policy":{"default_state":"hot","description":"managed by elogrus",` +
+		`"ism_template":[{"index_patterns":["logs-*"],"priority":100}],"states":[` +
+		`{"actions":[{"rollover":{"min_index_age":"1d"}}],"name":"hot","transitions":[{"conditions":{"min_index_age":"30d"},"state_name":"delete"}]},` +
+		`{"actions":[{"delete":{}}],"name":"delete","transitions":[]}]}}`
+	expected := []request{
+		{"GET", "/_plugins/_ism/policies/logs", "", ""},
+		{"PUT", "/_plugins/_ism/policies/logs", "", body},
+		{"GET", "/_plugins/_ism/policies/logs", "", ""},
+		{"PUT", "/_plugins/_ism/policies/logs", "if_primary_term=1&if_seq_no=7", body},
+	}
+	if len(requests) != len(expected) {
+		t.Fatalf("Unexpected requests: %+v", requests)
+	}
+	for i, r := range requests {
+		if r != expected[i] {
+			t.Errorf("Unexpected request %d:\n%+v\nexpected:\n%+v", i, r, expected[i])
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestNewElasticHookWithOptions(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("options-log"),
+		WithDeliveryMode(BulkDelivery),
+		WithHost("web-1"),
+		WithLevel(logrus.WarnLevel),
+		WithFields(logrus.Fields{"env": "prod"}),
+		WithECSFormat(),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+
+	if created := f.Requests(http.MethodPut, "/options-log"); len(created) != 1 || !strings.Contains(created[0].Body, "labels_strings") {
+		t.Errorf("Expected the index to be created with the ECS mappings: %+v", created)
+	}
+	if cfg := hook.Config(); cfg.Level != logrus.WarnLevel || cfg.Fields["env"] != "prod" {
+		t.Errorf("Unexpected configuration: %+v", cfg)
+	}
+
+	if err := hook.Submit(NewDocument().SetMessage("ignored")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("shipped").SetLevel(logrus.ErrorLevel)); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.batcher.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	reqs := f.Requests(http.MethodPost, "/options-log/_bulk")
+	if len(reqs) != 1 || strings.Count(reqs[0].Body, "\n") != 2 ||
+		!strings.Contains(reqs[0].Body, `"host":{"name":"web-1"}`) || !strings.Contains(reqs[0].Body, `"env":"prod"`) {
+		t.Errorf("Unexpected bulk requests: %+v", reqs)
+	}
+}
+
+func TestNewElasticHookWithOptionsErrors(t *testing.T) {
+	_, client := newFakeElastic(t)
+	if _, err := NewElasticHookWithOptions(es8.New(client)); !errors.Is(err, ErrNoIndex) {
+		t.Errorf("Expected ErrNoIndex, got %v", err)
+	}
+	if _, err := NewElasticHookWithOptions(es8.New(client), WithIndex("options-log"), WithQueueLimit(10, FireBlocking)); err == nil {
+		t.Error("Expected an error for a queue limit on a synchronous hook")
+	}
+	if _, err := NewElasticHookWithOptions(es8.New(client), WithIndex("options-log"), WithLevel(logrus.Level(42))); err == nil {
+		t.Error("Expected an error for an invalid level")
+	}
+}
+
+func TestWithAddresses(t *testing.T) {
+	f, client := newFakeElastic(t)
+	var built *http.Transport
+	var opts core.ConnectionOptions
+	newRoundTripper = func(o core.ConnectionOptions) *http.Transport {
+		opts, built = o, core.NewRoundTripper(o)
+		return built
+	}
+	defer func() { newRoundTripper = core.NewRoundTripper }()
+
+	hook, err := NewElasticHookWithOptions(nil,
+		WithAddresses(f.server.URL),
+		WithIndex("addresses-log"),
+		WithKeepAlive(5*time.Second),
+		WithMaxIdleConnsPerHost(64),
+		WithResponseHeaderTimeout(3*time.Second),
+		WithTLSHandshakeTimeout(2*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if d := core.NewDialer(opts); d.KeepAlive != 5*time.Second {
+		t.Errorf("Expected a keep-alive of 5s, got %s", d.KeepAlive)
+	}
+	if built.MaxIdleConnsPerHost != 64 || built.ResponseHeaderTimeout != 3*time.Second || built.TLSHandshakeTimeout != 2*time.Second {
+		t.Errorf("Unexpected transport settings: %d, %s, %s", built.MaxIdleConnsPerHost, built.ResponseHeaderTimeout, built.TLSHandshakeTimeout)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("built")); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Requests(http.MethodPost, "/addresses-log/_doc")) != 1 {
+		t.Error("Expected the document to be sent by the built client")
+	}
+
+	if _, err := NewElasticHookWithOptions(es8.New(client), WithAddresses(f.server.URL), WithIndex("addresses-log")); err == nil {
+		t.Error("Expected an error for a client with WithAddresses")
+	}
+	if _, err := NewElasticHookWithOptions(es8.New(client), WithIndex("addresses-log"), WithKeepAlive(time.Second)); err == nil {
+		t.Error("Expected an error for connection options without WithAddresses")
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_doc") {
+			time.Sleep(200 * time.Millisecond)
+		}
+		return false
+	}
+	hook, err := NewElasticHookWithOptions(es8.New(client), WithIndex("timeout-log"), WithRequestTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+
+	start := time.Now()
+	if err := hook.Submit(NewDocument().SetMessage("slow")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the request to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("Expected the request to be aborted, took %s", elapsed)
+	}
+}
+
+func TestHostname(t *testing.T) {
+	t.Setenv(HostEnv, "")
+	if host, _ := os.Hostname(); host != "" && Hostname() != host {
+		t.Errorf("Expected the host %q, got %q", host, Hostname())
+	}
+
+	t.Setenv(HostEnv, "web-2")
+	if host := Hostname(); host != "web-2" {
+		t.Errorf("Expected the host from the environment, got %q", host)
+	}
+	_, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client), WithIndex("host-log"))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if hook.host != "web-2" {
+		t.Errorf("Expected the default host from the environment, got %q", hook.host)
+	}
+}
+
+type bulkRecorder struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (r *bulkRecorder) Send(ctx context.Context, index string, doc []byte) error {
+	return errors.New("unexpected single document")
+}
+
+func (r *bulkRecorder) SendBulk(ctx context.Context, index string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, string(body))
+	return nil
+}
+
+func TestSenderClient(t *testing.T) {
+	r := &bulkRecorder{}
+	hook, err := NewElasticHookWithOptions(core.NewSenderClient(r),
+		WithIndex("sender-log"),
+		WithDeliveryMode(BulkDelivery),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+	if err := hook.Submit(NewDocument().SetMessage("sent")); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.bodies) != 1 || !strings.Contains(r.bodies[0], `"message":"sent"`) {
+		t.Errorf("Expected the document to be sent in a batch, got %q", r.bodies)
+	}
+}
@@ -0,0 +1,73 @@
+// Package otel traces the requests of elogrus hooks with OpenTelemetry client
+// spans, e.g.:
+//
+//	hook, err := elogrus.NewElasticHookWithOptions(client, elogrus.WithTracer(otel.New(nil)))
+//
+// Every index and bulk request gets a span with the name of the index, the
+// number of documents, the size of the payload and the HTTP status.
+package otel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// InstrumentationName identifies the tracer of the package.
+const InstrumentationName = "gopkg.in/go-extras/elogrus.v8/otel"
+
+// Attributes of the spans.
+const (
+	IndexKey     = attribute.Key("elogrus.index")
+	DocumentsKey = attribute.Key("elogrus.documents")
+)
+
+// Tracer implements core.Tracer with OpenTelemetry client spans.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+var _ core.Tracer = (*Tracer)(nil)
+
+// New returns a Tracer creating spans with provider, the global provider if nil.
+func New(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: provider.Tracer(InstrumentationName)}
+}
+
+// Start implements core.Tracer.
+func (t *Tracer) Start(ctx context.Context, op, index string, docs, bytes int) (context.Context, func(err error)) {
+	ctx, span := t.tracer.Start(ctx, "elasticsearch "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "elasticsearch"),
+			attribute.String("db.operation", op),
+			IndexKey.String(index),
+			DocumentsKey.Int(docs),
+			attribute.Int("http.request_content_length", bytes),
+		),
+	)
+	return ctx, func(err error) {
+		var e *core.ResponseError
+		switch {
+		case err == nil:
+			span.SetAttributes(attribute.Int("http.status_code", http.StatusOK))
+		case errors.As(err, &e):
+			span.SetAttributes(attribute.Int("http.status_code", e.StatusCode))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
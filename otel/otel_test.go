@@ -0,0 +1,64 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, end := tracer.Start(context.Background(), core.OpBulk, "app-log", 3, 300)
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		t.Error("Expected the context of the request to hold the span")
+	}
+	end(nil)
+	_, end = tracer.Start(context.Background(), core.OpIndex, "app-log", 1, 100)
+	end(&core.ResponseError{StatusCode: http.StatusTooManyRequests, Type: "es_rejected_execution_exception"})
+	_, end = tracer.Start(context.Background(), core.OpIndex, "app-log", 1, 100)
+	end(errors.New("connection refused"))
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	for i, want := range []struct {
+		name   string
+		docs   int64
+		status int64
+		code   codes.Code
+	}{
+		{"elasticsearch bulk", 3, http.StatusOK, codes.Unset},
+		{"elasticsearch index", 1, http.StatusTooManyRequests, codes.Error},
+		{"elasticsearch index", 1, 0, codes.Error},
+	} {
+		span := spans[i]
+		if span.Name() != want.name || span.SpanKind() != trace.SpanKindClient {
+			t.Errorf("Expected a client span %q, got a %s span %q", want.name, span.SpanKind(), span.Name())
+		}
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, kv := range span.Attributes() {
+			attrs[kv.Key] = kv.Value
+		}
+		if attrs[IndexKey].AsString() != "app-log" || attrs[DocumentsKey].AsInt64() != want.docs {
+			t.Errorf("Unexpected attributes of span %d: %v", i, span.Attributes())
+		}
+		if attrs["http.status_code"].AsInt64() != want.status {
+			t.Errorf("Expected status %d for span %d, got %v", want.status, i, attrs["http.status_code"])
+		}
+		if span.Status().Code != want.code {
+			t.Errorf("Expected status code %s for span %d, got %s", want.code, i, span.Status().Code)
+		}
+	}
+}
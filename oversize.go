@@ -0,0 +1,98 @@
+package elogrus
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// TruncatedKey is the data field set to true in the documents whose message
+// or field values were truncated, see Config.MaxMessageLength.
+const TruncatedKey = "truncated"
+
+// summarize creates the document shipped instead of an oversized one: the
+// message is truncated and the data replaced with the size of the original
+// document. It returns nil if even the summary does not fit in max bytes.
+func (hook *ElasticHook) summarize(entry *logrus.Entry, size, max int) []byte {
+	var msg interface{} = &Message{
+		Host:      hook.host,
+		Timestamp: hook.formatTime(entry.Time),
+		Message:   truncate(entry.Message, max/2),
+		Data: logrus.Fields{
+			TruncatedKey:    true,
+			"original_size": size,
+		},
+		Level:         hook.levelLabel(entry.Level),
+		SchemaVersion: hook.schemaVersion,
+	}
+	if hook.documentFormat == ECSFormat {
+		msg = &ECSMessage{
+			Timestamp: hook.formatTime(entry.Time),
+			Message:   truncate(entry.Message, max/2),
+			Log:       core.ECSLog{Level: hook.ecsLevel(entry.Level)},
+			Host:      hook.ecsHost(),
+			Labels: map[string]string{
+				TruncatedKey:    "true",
+				"original_size": strconv.Itoa(size),
+			},
+			ECS: core.ECSInfo{Version: core.ECSVersion},
+		}
+	}
+	data, _ := hook.marshal(msg)
+	if data == nil || len(data) > max {
+		return nil
+	}
+	return data
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// truncateFields truncates the string and error values of data longer than
+// max bytes, setting the TruncatedKey field if any is.
+func truncateFields(data logrus.Fields, max int) {
+	if max <= 0 {
+		return
+	}
+	truncated := false
+	for k, v := range data {
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case error:
+			s = v.Error()
+		default:
+			continue
+		}
+		if len(s) > max {
+			data[k] = truncate(s, max)
+			truncated = true
+		}
+	}
+	if truncated {
+		data[TruncatedKey] = true
+	}
+}
+
+// entryMessage returns the message of the entry truncated to the
+// MaxMessageLength, setting the TruncatedKey field of data if it is.
+func (hook *ElasticHook) entryMessage(entry *logrus.Entry, data logrus.Fields) string {
+	cfg := hook.config.Load()
+	if cfg == nil || cfg.MaxMessageLength <= 0 || len(entry.Message) <= cfg.MaxMessageLength {
+		return entry.Message
+	}
+	data[TruncatedKey] = true
+	return truncate(entry.Message, cfg.MaxMessageLength)
+}
@@ -0,0 +1,104 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestMaxDocumentSize(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "oversize-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	var oversized []string
+	hook.OversizedHandler = func(entry *logrus.Entry, data []byte) {
+		oversized = append(oversized, entry.Message[:3])
+	}
+	cfg := hook.Config()
+	cfg.MaxDocumentSize = 500
+	if err := hook.Reconfigure(cfg); err != nil {
+		t.Fatalf("Error reconfiguring the hook: %s", err)
+	}
+
+	if err := hook.Submit(NewDocument().SetMessage("small")); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	err = hook.Submit(NewDocument().SetMessage("one" + strings.Repeat("é", 300)))
+	if !errors.Is(err, ErrDocumentTooLarge) {
+		t.Fatalf("Expected ErrDocumentTooLarge, got %v", err)
+	}
+
+	cfg.SummarizeOversized = true
+	if err := hook.Reconfigure(cfg); err != nil {
+		t.Fatalf("Error reconfiguring the hook: %s", err)
+	}
+	if err := hook.Submit(NewDocument().SetMessage("two" + strings.Repeat("é", 300))); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if strings.Join(oversized, ",") != "one,two" {
+		t.Errorf("Unexpected oversized entries: %v", oversized)
+	}
+	docs := f.Requests(http.MethodPost, "/oversize-log/_doc")
+	if len(docs) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(docs))
+	}
+	var summary Message
+	if err := json.Unmarshal([]byte(docs[1].Body), &summary); err != nil {
+		t.Fatalf("Error parsing the summary: %s", err)
+	}
+	if len(docs[1].Body) > 500 || !strings.HasPrefix(summary.Message, "twoé") || summary.Data["truncated"] != true {
+		t.Errorf("Unexpected summary: %s", docs[1].Body)
+	}
+}
+
+func TestMaxLengths(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "truncate-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	cfg := hook.Config()
+	cfg.MaxMessageLength = 5
+	cfg.MaxFieldLength = 4
+	if err := hook.Reconfigure(cfg); err != nil {
+		t.Fatalf("Error reconfiguring the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	logger.WithField("short", "abc").Info("short")
+	logger.WithFields(logrus.Fields{"body": "abcdefgh", logrus.ErrorKey: errors.New("failure"), "n": 123456}).Info("ééé long")
+
+	docs := f.Requests(http.MethodPost, "/truncate-log/_doc")
+	if len(docs) != 2 {
+		t.Fatalf("Expected 2 documents, got %d", len(docs))
+	}
+	var short, long Message
+	if err := json.Unmarshal([]byte(docs[0].Body), &short); err != nil {
+		t.Fatalf("Error parsing the document: %s", err)
+	}
+	if err := json.Unmarshal([]byte(docs[1].Body), &long); err != nil {
+		t.Fatalf("Error parsing the document: %s", err)
+	}
+	if short.Message != "short" || short.Data["short"] != "abc" || short.Data[TruncatedKey] != nil {
+		t.Errorf("Unexpected document: %s", docs[0].Body)
+	}
+	if long.Message != "éé" || long.Data["body"] != "abcd" || long.Data[logrus.ErrorKey] != "fail" ||
+		long.Data["n"] != float64(123456) || long.Data[TruncatedKey] != true {
+		t.Errorf("Unexpected document: %s", docs[1].Body)
+	}
+
+	cfg.MaxFieldLength = -1
+	if err := hook.Reconfigure(cfg); err == nil {
+		t.Error("Expected an error for a negative length")
+	}
+}
@@ -0,0 +1,143 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+const (
+	// DefaultAsyncWorkers is the number of goroutines delivering the entries of an asynchronous hook.
+	DefaultAsyncWorkers = 4
+	// DefaultAsyncQueueSize is the number of entries an asynchronous hook queues for its workers.
+	DefaultAsyncQueueSize = 10000
+)
+
+// asyncJob is an entry queued for the workers of an asynchronous hook.
+type asyncJob struct {
+	// t is the time of the entry, see indexAt
+	t time.Time
+	// index is the index of the entry, see IndexKey
+	index string
+	// meta is the metadata of the document, see documentMeta
+	meta core.DocumentMeta
+	data []byte
+	// entry is a copy of the entry if the hook has a DeadLetterHandler or its
+	// Errors are read
+	entry *logrus.Entry
+}
+
+// workerPool delivers the entries of an asynchronous hook with a fixed number
+// of goroutines fed by a bounded queue. The workers are started by the first entry.
+type workerPool struct {
+	workers   int
+	queueSize int
+	once      sync.Once
+	jobs      chan asyncJob
+	// pending counts the entries queued or being delivered
+	pending core.InFlight
+	// dropOldest makes room in a full queue by dropping its oldest entry,
+	// dropped counts them, see FireDropOldest
+	dropOldest bool
+	dropped    atomic.Uint64
+}
+
+func newWorkerPool() *workerPool {
+	return &workerPool{workers: DefaultAsyncWorkers, queueSize: DefaultAsyncQueueSize}
+}
+
+// SetWorkerPool sets the number of goroutines delivering the entries of an
+// asynchronous hook and the number of entries queued for them, DefaultAsyncWorkers
+// and DefaultAsyncQueueSize by default. Fire blocks while the queue is full, see
+// SetQueueLimit to drop the entries instead. The other hooks return an error.
+func (hook *ElasticHook) SetWorkerPool(workers, queueSize int) error {
+	if hook.pool == nil {
+		return errors.New("only asynchronous hooks have a worker pool")
+	}
+	if workers <= 0 || queueSize <= 0 {
+		return errors.New("workers and queue size must be positive")
+	}
+	hook.pool.workers = workers
+	hook.pool.queueSize = queueSize
+	return nil
+}
+
+// enqueue queues a document for the workers of the hook, starting them if
+// needed. It fails if the hook is canceled while the queue is full, unless the
+// oldest queued document is dropped to make room, see FireDropOldest.
+func (hook *ElasticHook) enqueue(job asyncJob) error {
+	p := hook.pool
+	p.once.Do(func() {
+		p.jobs = make(chan asyncJob, p.queueSize)
+		for i := 0; i < p.workers; i++ {
+			go hook.work()
+		}
+	})
+	p.pending.Add(1)
+	for p.dropOldest {
+		select {
+		case p.jobs <- job:
+			hook.metrics.QueueDepth(p.pending.Len())
+			return nil
+		default:
+		}
+		select {
+		case old := <-p.jobs:
+			hook.release(len(old.data))
+			p.pending.Done(1)
+			p.dropped.Add(1)
+			hook.metrics.DocumentsDropped(1)
+		default:
+			// taken by a worker meanwhile
+		}
+	}
+	select {
+	case p.jobs <- job:
+		hook.metrics.QueueDepth(p.pending.Len())
+		return nil
+	case <-hook.ctx.Done():
+		p.pending.Done(1)
+		return hook.ctx.Err()
+	}
+}
+
+// work delivers the queued documents until the hook is canceled.
+func (hook *ElasticHook) work() {
+	pprof.SetGoroutineLabels(hook.labels)
+	for {
+		select {
+		case job := <-hook.pool.jobs:
+			if _, err := hook.indexDocument(context.Background(), hook.documentIndex(job.index, job.t), job.meta, job.data); err != nil {
+				hook.metrics.DocumentsFailed(1)
+				hook.failures.add(1, err)
+				hook.handleError(err, job.data)
+				hook.deadLetter(job.entry, job.data, err)
+			} else {
+				hook.metrics.DocumentsSent(1)
+			}
+			hook.release(len(job.data))
+			hook.pool.pending.Done(1)
+			hook.metrics.QueueDepth(hook.pool.pending.Len())
+		case <-hook.ctx.Done():
+			return
+		}
+	}
+}
+
+// release frees the room of a delivered document in the queue limit and the
+// memory budget of the hook.
+func (hook *ElasticHook) release(size int) {
+	if hook.limiter != nil {
+		hook.limiter.Release(1)
+	}
+	if hook.account != nil {
+		hook.account.Release(size)
+	}
+}
@@ -0,0 +1,95 @@
+package elogrus
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWorkerPool(t *testing.T) {
+	f, client := newFakeElastic(t)
+	var active, peak atomic.Int32
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_doc") {
+			n := active.Add(1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+			active.Add(-1)
+		}
+		return false
+	}
+	hook, err := NewAsyncElasticHook(client, "localhost", logrus.InfoLevel, "pool-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+	if err := hook.SetWorkerPool(2, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		if err := hook.Submit(NewDocument().SetMessage("entry")); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(f.Requests(http.MethodPost, "/pool-log/_doc")) < 20 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := len(f.Requests(http.MethodPost, "/pool-log/_doc")); n != 20 {
+		t.Errorf("Expected 20 documents, got %d", n)
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", p)
+	}
+
+	syncHook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "pool-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := syncHook.SetWorkerPool(2, 100); err == nil {
+		t.Error("Expected an error for a synchronous hook")
+	}
+}
+
+func TestWorkerPoolCanceled(t *testing.T) {
+	f, client := newFakeElastic(t)
+	release := make(chan struct{})
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_doc") {
+			<-release
+		}
+		return false
+	}
+	defer close(release)
+	hook, err := NewAsyncElasticHook(client, "localhost", logrus.InfoLevel, "pool-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetWorkerPool(1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// one entry in flight, one queued, the third one waits for room
+	for i := 0; i < 2; i++ {
+		if err := hook.Submit(NewDocument().SetMessage("entry")); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	done := make(chan error)
+	go func() { done <- hook.Submit(NewDocument().SetMessage("entry")) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Expected Fire to block while the queue is full, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	hook.Cancel()
+	if err := <-done; err == nil {
+		t.Error("Expected an error once the hook is canceled")
+	}
+}
@@ -0,0 +1,117 @@
+// Package prometheus exports the metrics of elogrus hooks to Prometheus, e.g.:
+//
+//	metrics, err := prometheus.New(prom.DefaultRegisterer, nil)
+//	if err != nil {
+//		return err
+//	}
+//	hook, err := elogrus.NewElasticHookWithOptions(client, elogrus.WithMetrics(metrics))
+//
+// The hooks of a process sharing a registerer tell their metrics apart with
+// constant labels, e.g. prom.Labels{"index": "app-log"}.
+package prometheus
+
+import (
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// Namespace prefixes the names of the metrics.
+const Namespace = "elogrus"
+
+// Metrics implements core.Metrics with Prometheus counters, a gauge and histograms.
+type Metrics struct {
+	entries   prom.Counter
+	sent      prom.Counter
+	bytes     prom.Counter
+	failed    prom.Counter
+	dropped   prom.Counter
+	retries   prom.Counter
+	queue     prom.Gauge
+	latency   prom.Histogram
+	batchSize prom.Histogram
+}
+
+var _ core.Metrics = (*Metrics)(nil)
+
+// New creates the metrics of a hook with the constLabels and registers them
+// with reg. It fails if they are registered already, i.e. if another hook uses
+// the same constLabels.
+func New(reg prom.Registerer, constLabels prom.Labels) (*Metrics, error) {
+	counter := func(name, help string) prom.Counter {
+		return prom.NewCounter(prom.CounterOpts{Namespace: Namespace, Name: name, Help: help, ConstLabels: constLabels})
+	}
+	m := &Metrics{
+		entries: counter("entries_fired_total", "Entries accepted for delivery."),
+		sent:    counter("documents_sent_total", "Documents written."),
+		bytes:   counter("sent_bytes_total", "Bytes of the requests sending documents."),
+		failed:  counter("documents_failed_total", "Documents that could not be written."),
+		dropped: counter("documents_dropped_total", "Documents dropped before they were sent."),
+		retries: counter("request_retries_total", "Requests sent again after a transient error."),
+		queue: prom.NewGauge(prom.GaugeOpts{
+			Namespace:   Namespace,
+			Name:        "queue_depth",
+			Help:        "Documents waiting for delivery.",
+			ConstLabels: constLabels,
+		}),
+		latency: prom.NewHistogram(prom.HistogramOpts{
+			Namespace:   Namespace,
+			Name:        "request_duration_seconds",
+			Help:        "Duration of the requests sending documents.",
+			ConstLabels: constLabels,
+			Buckets:     prom.ExponentialBuckets(0.005, 2, 12),
+		}),
+		batchSize: prom.NewHistogram(prom.HistogramOpts{
+			Namespace:   Namespace,
+			Name:        "request_documents",
+			Help:        "Documents of the requests sending documents, the size of the bulk batches.",
+			ConstLabels: constLabels,
+			Buckets:     prom.ExponentialBuckets(1, 4, 8),
+		}),
+	}
+	for _, c := range []prom.Collector{m.entries, m.sent, m.bytes, m.failed, m.dropped, m.retries, m.queue, m.latency, m.batchSize} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// EntryFired implements core.Metrics.
+func (m *Metrics) EntryFired() {
+	m.entries.Inc()
+}
+
+// DocumentsSent implements core.Metrics.
+func (m *Metrics) DocumentsSent(docs int) {
+	m.sent.Add(float64(docs))
+}
+
+// RequestSent implements core.Metrics.
+func (m *Metrics) RequestSent(docs, bytes int, took time.Duration) {
+	m.bytes.Add(float64(bytes))
+	m.latency.Observe(took.Seconds())
+	m.batchSize.Observe(float64(docs))
+}
+
+// DocumentsFailed implements core.Metrics.
+func (m *Metrics) DocumentsFailed(docs int) {
+	m.failed.Add(float64(docs))
+}
+
+// DocumentsDropped implements core.Metrics.
+func (m *Metrics) DocumentsDropped(docs int) {
+	m.dropped.Add(float64(docs))
+}
+
+// RequestRetried implements core.Metrics.
+func (m *Metrics) RequestRetried() {
+	m.retries.Inc()
+}
+
+// QueueDepth implements core.Metrics.
+func (m *Metrics) QueueDepth(docs int) {
+	m.queue.Set(float64(docs))
+}
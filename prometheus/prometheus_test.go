@@ -0,0 +1,49 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prom.NewRegistry()
+	m, err := New(reg, prom.Labels{"index": "app-log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.EntryFired()
+	m.EntryFired()
+	m.RequestSent(2, 300, 20*time.Millisecond)
+	m.RequestRetried()
+	m.DocumentsSent(1)
+	m.DocumentsFailed(1)
+	m.DocumentsDropped(3)
+	m.QueueDepth(5)
+
+	for c, want := range map[prom.Collector]float64{
+		m.entries: 2,
+		m.sent:    1,
+		m.bytes:   300,
+		m.failed:  1,
+		m.dropped: 3,
+		m.retries: 1,
+		m.queue:   5,
+	} {
+		if got := testutil.ToFloat64(c); got != want {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	}
+	if n := testutil.CollectAndCount(reg, "elogrus_request_duration_seconds", "elogrus_request_documents"); n != 2 {
+		t.Errorf("Expected the histograms to be registered, got %d metrics", n)
+	}
+
+	if _, err := New(reg, prom.Labels{"index": "app-log"}); err == nil {
+		t.Error("Expected the metrics of a second hook with the same labels to be rejected")
+	}
+	if _, err := New(reg, prom.Labels{"index": "audit-log"}); err != nil {
+		t.Errorf("Unexpected error registering the metrics of a second hook: %s", err)
+	}
+}
@@ -0,0 +1,210 @@
+package elogrus
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// ErrQueueFull is returned by Fire of a non-blocking hook whose queue is full.
+var ErrQueueFull = core.ErrQueueFull
+
+// ErrMemoryBudgetExceeded is returned by Fire when an entry does not fit in the
+// memory budget of the hook, see SetMemoryBudget.
+var ErrMemoryBudgetExceeded = core.ErrMemoryBudgetExceeded
+
+// MemoryBudget limits the memory taken by the queues of several hooks, see core.MemoryBudget.
+type MemoryBudget = core.MemoryBudget
+
+// NewMemoryBudget creates a new memory budget of size bytes to be shared by hooks.
+func NewMemoryBudget(size int64) *MemoryBudget {
+	return core.NewMemoryBudget(size)
+}
+
+// FireMode defines what Fire does when the queue of the hook is full.
+type FireMode int
+
+const (
+	// FireBlocking makes Fire wait until there is room in the queue, applying
+	// backpressure to the logging code. No entry is lost.
+	FireBlocking FireMode = iota
+	// FireNonBlocking makes Fire drop the entry and return ErrQueueFull, the
+	// logging code is never slowed down. Dropped entries are counted, see Dropped.
+	FireNonBlocking
+	// FireDropOldest makes Fire drop the oldest queued entry to make room, the
+	// logging code is never slowed down and the queue holds the most recent
+	// entries. Dropped entries are counted, see Dropped. Only the asynchronous
+	// hooks support it, see SetBufferLimit for the bulk processor hooks.
+	FireDropOldest
+)
+
+// SetQueueLimit bounds the number of entries that are accepted by Fire but not
+// delivered yet to size; mode defines what happens to the entries above the limit.
+// By default the queue of the bulk processor is unbounded, the asynchronous hook
+// blocks once the queue of its workers is full (see SetWorkerPool). The limit
+// applies to the asynchronous and the bulk processor hooks, the synchronous hook
+// has no queue and returns an error.
+func (hook *ElasticHook) SetQueueLimit(size int, mode FireMode) error {
+	if size <= 0 {
+		return errors.New("queue size must be positive")
+	}
+	if hook.batcher == nil && hook.pool == nil {
+		return errors.New("synchronous hooks have no queue")
+	}
+	if mode == FireDropOldest {
+		if hook.pool == nil {
+			return errors.New("only asynchronous hooks drop the oldest entries, see SetBufferLimit")
+		}
+		// the queue of the workers is the limit
+		hook.pool.queueSize = size
+		hook.pool.dropOldest = true
+		return nil
+	}
+	hook.limiter = core.NewLimiter(size, mode == FireBlocking)
+	if hook.pool != nil && hook.pool.queueSize < size {
+		// the limiter rather than the queue of the workers decides
+		hook.pool.queueSize = size
+	}
+	if hook.batcher != nil {
+		hook.batcher.SetLimiter(hook.limiter)
+	}
+	return nil
+}
+
+// ErrRateLimited is returned by Fire of a non-blocking hook when an entry is
+// above the rate limit, see SetRateLimit.
+var ErrRateLimited = core.ErrRateLimited
+
+// SetRateLimit bounds the rate of the entries the hook delivers to
+// docsPerSecond, with bursts of up to burst entries, to protect a shared
+// cluster from log storms; mode defines what happens to the entries above the
+// rate: FireBlocking makes Fire wait, FireNonBlocking drops them and makes Fire
+// return ErrRateLimited. The dropped entries are counted, see Dropped. The
+// limit applies to every hook, before the entries are queued.
+func (hook *ElasticHook) SetRateLimit(docsPerSecond float64, burst int, mode FireMode) error {
+	if docsPerSecond <= 0 || burst <= 0 {
+		return errors.New("rate limit must be positive")
+	}
+	if mode == FireDropOldest {
+		return errors.New("entries above the rate limit cannot drop the oldest ones")
+	}
+	hook.rateLimiter = core.NewRateLimiter(docsPerSecond, burst, mode == FireBlocking)
+	return nil
+}
+
+// SetMemoryBudget makes the hook take the memory of its queued documents from a
+// budget shared with other hooks. Entries that do not fit in the budget are dropped
+// (Fire returns ErrMemoryBudgetExceeded), the hooks taking the most memory first.
+// The budget applies to the asynchronous and the bulk processor hooks, the
+// synchronous hook has no queue and returns an error. Calling it again replaces
+// the budget, and Close gives the share of the hook back to the other hooks.
+func (hook *ElasticHook) SetMemoryBudget(budget *MemoryBudget) error {
+	if hook.batcher == nil && hook.pool == nil {
+		return errors.New("synchronous hooks have no queue")
+	}
+	if hook.account != nil {
+		// the budget replaces the previous one
+		hook.account.Close()
+	}
+	hook.account = budget.NewAccount()
+	if hook.batcher != nil {
+		hook.batcher.SetBudgetAccount(hook.account)
+	}
+	return nil
+}
+
+// SetExpectedThroughput preallocates the buffers of a bulk processor hook for the
+// expected number of documents per second and their average serialized size, so
+// the first traffic spike does not cause repeated buffer growth. It returns an
+// error for the other hooks, which do not buffer documents.
+func (hook *ElasticHook) SetExpectedThroughput(docsPerSecond, avgDocSize int) error {
+	if hook.batcher == nil {
+		return errors.New("only bulk processor hooks have buffers")
+	}
+	if docsPerSecond <= 0 || avgDocSize <= 0 {
+		return errors.New("expected throughput must be positive")
+	}
+	hook.batcher.Preallocate(docsPerSecond, avgDocSize)
+	return nil
+}
+
+// OverflowPolicy defines what happens to the entries that do not fit in the
+// buffer limit of a bulk processor hook, see SetBufferLimit.
+type OverflowPolicy = core.OverflowPolicy
+
+const (
+	// OverflowBlock makes Fire wait until a flush makes room for the entry.
+	OverflowBlock = core.OverflowBlock
+	// OverflowDropOldest drops the oldest buffered entries to make room.
+	OverflowDropOldest = core.OverflowDropOldest
+	// OverflowDropNewest drops the entry, Fire returns ErrBufferFull.
+	OverflowDropNewest = core.OverflowDropNewest
+)
+
+// ErrBufferFull is returned by Fire of a bulk processor hook when an entry does
+// not fit in the buffer limit, see SetBufferLimit.
+var ErrBufferFull = core.ErrBufferFull
+
+// SetBufferLimit bounds the serialized documents a bulk processor hook buffers
+// between two flushes to maxBytes, so the buffer cannot grow without limit while
+// the cluster is slow; policy defines what happens to the entries above it. The
+// dropped entries are counted, see Dropped. The other hooks return an error.
+func (hook *ElasticHook) SetBufferLimit(maxBytes int, policy OverflowPolicy) error {
+	if hook.batcher == nil {
+		return errors.New("only bulk processor hooks have a buffer")
+	}
+	if maxBytes <= 0 {
+		return errors.New("buffer limit must be positive")
+	}
+	hook.batcher.SetBufferLimit(maxBytes, policy)
+	return nil
+}
+
+// DefaultFlushConcurrency and DefaultFlushLatencyTarget are the default flush
+// concurrency of the bulk processor hooks, see SetFlushConcurrency.
+const (
+	DefaultFlushConcurrency   = core.DefaultMaxConcurrentFlushes
+	DefaultFlushLatencyTarget = core.DefaultFlushLatencyTarget
+)
+
+// SetFlushConcurrency sets the maximum number of bulk requests a bulk processor
+// hook sends at the same time, DefaultFlushConcurrency by default. The buffer
+// keeps accepting entries while they are in flight. With a latencyTarget, the
+// number of requests starts at one and adapts to the cluster: it grows while
+// the requests complete within the target and halves when one is slower or
+// throttled. Without it, up to max requests are always sent at the same time.
+// The other hooks return an error.
+func (hook *ElasticHook) SetFlushConcurrency(max int, latencyTarget time.Duration) error {
+	if hook.batcher == nil {
+		return errors.New("only bulk processor hooks flush")
+	}
+	if max <= 0 || latencyTarget < 0 {
+		return errors.New("flush concurrency must be positive")
+	}
+	hook.batcher.SetConcurrency(max, latencyTarget)
+	return nil
+}
+
+// Dropped returns the number of entries dropped because the queue was full,
+// the memory budget was exceeded, the buffer limit was reached or they were
+// above the rate limit, including the oldest entries dropped to make room.
+func (hook *ElasticHook) Dropped() uint64 {
+	var dropped uint64
+	if hook.limiter != nil {
+		dropped += hook.limiter.Dropped()
+	}
+	if hook.rateLimiter != nil {
+		dropped += hook.rateLimiter.Dropped()
+	}
+	if hook.account != nil {
+		dropped += hook.account.Dropped()
+	}
+	if hook.batcher != nil {
+		dropped += hook.batcher.BufferDropped()
+	}
+	if hook.pool != nil {
+		dropped += hook.pool.dropped.Load()
+	}
+	return dropped
+}
@@ -0,0 +1,421 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSetQueueLimit(t *testing.T) {
+	_, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetQueueLimit(10, FireBlocking); err == nil {
+		t.Error("Expected an error for a synchronous hook")
+	}
+
+	hook, err = NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetQueueLimit(0, FireBlocking); err == nil {
+		t.Error("Expected an error for an invalid size")
+	}
+}
+
+func TestFireNonBlocking(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetQueueLimit(2, FireNonBlocking); err != nil {
+		t.Fatalf("Error setting the queue limit: %s", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err := hook.Submit(NewDocument().SetMessage("entry"))
+		if i < 2 && err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if i == 2 && !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("Expected ErrQueueFull, got %v", err)
+		}
+	}
+	if hook.Dropped() != 1 {
+		t.Errorf("Unexpected number of dropped entries: %d", hook.Dropped())
+	}
+
+	// The flushed entries free the queue.
+	if err := hook.batcher.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := hook.Submit(NewDocument().SetMessage("entry")); err != nil {
+		t.Errorf("Unexpected error after a flush: %s", err)
+	}
+	if reqs := f.Requests(http.MethodPost, "/queue-log/_bulk"); len(reqs) != 1 {
+		t.Errorf("Expected one bulk request, got %d", len(reqs))
+	}
+}
+
+func TestFireBlocking(t *testing.T) {
+	_, client := newFakeElastic(t)
+	hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetQueueLimit(1, FireBlocking); err != nil {
+		t.Fatalf("Error setting the queue limit: %s", err)
+	}
+	if err := hook.Submit(NewDocument()); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- hook.Submit(NewDocument()) }()
+	select {
+	case <-done:
+		t.Fatal("Expected Fire to block while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	// The bulk processor flushes every second by default.
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Fire was not unblocked by the flush")
+	}
+	if hook.Dropped() != 0 {
+		t.Errorf("Unexpected number of dropped entries: %d", hook.Dropped())
+	}
+}
+
+func TestSetBufferLimit(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetBufferLimit(1000, OverflowDropNewest); err == nil {
+		t.Error("Expected an error for a synchronous hook")
+	}
+
+	hook, err = NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetBufferLimit(0, OverflowDropNewest); err == nil {
+		t.Error("Expected an error for an invalid size")
+	}
+	if err := hook.SetBufferLimit(1000, OverflowDropNewest); err != nil {
+		t.Fatalf("Error setting the buffer limit: %s", err)
+	}
+
+	var err2 error
+	for i := 0; i < 100 && err2 == nil; i++ {
+		err2 = hook.Submit(NewDocument().SetMessage("entry"))
+	}
+	if !errors.Is(err2, ErrBufferFull) {
+		t.Fatalf("Expected ErrBufferFull, got %v", err2)
+	}
+	if hook.Dropped() != 1 {
+		t.Errorf("Unexpected number of dropped entries: %d", hook.Dropped())
+	}
+
+	if err := hook.batcher.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := hook.Submit(NewDocument().SetMessage("entry")); err != nil {
+		t.Errorf("Unexpected error after a flush: %s", err)
+	}
+	if reqs := f.Requests(http.MethodPost, "/queue-log/_bulk"); len(reqs) != 1 {
+		t.Errorf("Expected one bulk request, got %d", len(reqs))
+	}
+}
+
+func TestSetMemoryBudget(t *testing.T) {
+	_, client := newFakeElastic(t)
+	budget := NewMemoryBudget(1000)
+	hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetMemoryBudget(budget); err != nil {
+		t.Fatalf("Error setting the memory budget: %s", err)
+	}
+
+	var err2 error
+	for i := 0; i < 100 && err2 == nil; i++ {
+		err2 = hook.Submit(NewDocument().SetMessage("entry"))
+	}
+	if !errors.Is(err2, ErrMemoryBudgetExceeded) {
+		t.Fatalf("Expected ErrMemoryBudgetExceeded, got %v", err2)
+	}
+	if hook.Dropped() != 1 || budget.Used() == 0 || budget.Used() > 1000 {
+		t.Errorf("Unexpected state: %d dropped, %d bytes used", hook.Dropped(), budget.Used())
+	}
+
+	if err := hook.batcher.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if budget.Used() != 0 {
+		t.Errorf("Expected the budget to be released, %d bytes used", budget.Used())
+	}
+}
+
+func TestMemoryBudgetAccounts(t *testing.T) {
+	_, client := newFakeElastic(t)
+	budget := NewMemoryBudget(1000)
+	fill := func(hook *ElasticHook) {
+		t.Helper()
+		var err error
+		for i := 0; i < 100 && err == nil; i++ {
+			err = hook.Submit(NewDocument().SetMessage("entry"))
+		}
+		if !errors.Is(err, ErrMemoryBudgetExceeded) {
+			t.Fatalf("Expected ErrMemoryBudgetExceeded, got %v", err)
+		}
+	}
+	var hooks []*ElasticHook
+	for i := 0; i < 3; i++ {
+		hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+		if err != nil {
+			t.Fatalf("Error creating the hook: %s", err)
+		}
+		defer hook.Cancel()
+		if err := hook.SetMemoryBudget(budget); err != nil {
+			t.Fatal(err)
+		}
+		hooks = append(hooks, hook)
+	}
+	// Neither a replaced budget nor a closed hook takes a share any more.
+	if err := hooks[0].SetMemoryBudget(budget); err != nil {
+		t.Fatal(err)
+	}
+	if err := hooks[1].Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	fill(hooks[0])
+	used := budget.Used()
+	fill(hooks[2])
+	if share := budget.Used() - used; share <= 1000/3 {
+		t.Errorf("Expected the hook to get more than a third of the budget, got %d bytes", share)
+	}
+}
+
+func TestSetExpectedThroughput(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetExpectedThroughput(1000, 200); err == nil {
+		t.Error("Expected an error for a synchronous hook")
+	}
+
+	hook, err = NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetExpectedThroughput(0, 200); err == nil {
+		t.Error("Expected an error for an invalid throughput")
+	}
+	if err := hook.SetExpectedThroughput(1000, 200); err != nil {
+		t.Fatalf("Error setting the expected throughput: %s", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := hook.Submit(NewDocument().SetMessage("entry")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hook.batcher.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if reqs := f.Requests(http.MethodPost, "/queue-log/_bulk"); len(reqs) != 1 || strings.Count(reqs[0].Body, "\n") != 20 {
+		t.Errorf("Unexpected bulk requests: %+v", reqs)
+	}
+}
+
+func TestSetRateLimit(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "rate-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+	if err := hook.SetRateLimit(0, 1, FireNonBlocking); err == nil {
+		t.Error("Expected an error for a zero rate")
+	}
+	if err := hook.SetRateLimit(0.001, 2, FireNonBlocking); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err := hook.Submit(NewDocument().SetMessage("storm"))
+		if i < 2 && err != nil {
+			t.Errorf("Expected the burst to be delivered, got %s", err)
+		}
+		if i == 2 && !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected ErrRateLimited, got %v", err)
+		}
+	}
+	if n := len(f.Requests(http.MethodPost, "/rate-log/_doc")); n != 2 {
+		t.Errorf("Expected 2 documents, got %d", n)
+	}
+	if hook.Dropped() != 1 {
+		t.Errorf("Expected 1 dropped entry, got %d", hook.Dropped())
+	}
+}
+
+func TestSetFlushConcurrency(t *testing.T) {
+	f, client := newFakeElastic(t)
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	release := make(chan struct{})
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if !strings.HasSuffix(r.URL.Path, "/_bulk") {
+			return false
+		}
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		_, _ = io.WriteString(w, `{"errors":false,"items":[{"index":{"status":201}}]}`)
+		return true
+	}
+	hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "concurrency-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetFlushConcurrency(0, 0); err == nil {
+		t.Error("Expected an error for an invalid concurrency")
+	}
+	if err := hook.SetFlushConcurrency(3, 0); err != nil {
+		t.Fatalf("Error setting the flush concurrency: %s", err)
+	}
+
+	waitInFlight := func(want int) {
+		deadline := time.Now().Add(time.Second)
+		for {
+			mu.Lock()
+			n := inFlight
+			mu.Unlock()
+			if n == want || time.Now().After(deadline) {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	// a batch per flush, the next entry is submitted once the request is sent
+	for i := 1; i <= 3; i++ {
+		if err := hook.Submit(NewDocument().SetMessage("entry")); err != nil {
+			t.Fatal(err)
+		}
+		if err := hook.batcher.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		waitInFlight(i)
+	}
+	close(release)
+	if err := hook.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if maxInFlight != 3 {
+		t.Errorf("Expected 3 bulk requests in flight, got %d", maxInFlight)
+	}
+
+	hook, err = NewElasticHook(client, "localhost", logrus.InfoLevel, "concurrency-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetFlushConcurrency(3, 0); err == nil {
+		t.Error("Expected an error for a synchronous hook")
+	}
+}
+
+func TestFireDropOldest(t *testing.T) {
+	f, client := newFakeElastic(t)
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/_doc") {
+			return false
+		}
+		started <- struct{}{}
+		<-release
+		return false
+	}
+	hook, err := NewAsyncElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+	if err := hook.SetWorkerPool(1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.SetQueueLimit(2, FireDropOldest); err != nil {
+		t.Fatalf("Error setting the queue limit: %s", err)
+	}
+
+	if err := hook.Submit(NewDocument().SetMessage("entry 0")); err != nil {
+		t.Fatal(err)
+	}
+	// the worker is busy with the first entry
+	<-started
+	for i := 1; i <= 4; i++ {
+		if err := hook.Submit(NewDocument().SetMessage(fmt.Sprintf("entry %d", i))); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+	if hook.Dropped() != 2 {
+		t.Errorf("Unexpected number of dropped entries: %d", hook.Dropped())
+	}
+	close(release)
+	if err := hook.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, req := range f.Requests(http.MethodPost, "/queue-log/_doc") {
+		var msg Message
+		if err := json.Unmarshal([]byte(req.Body), &msg); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, msg.Message)
+	}
+	if strings.Join(messages, ",") != "entry 0,entry 3,entry 4" {
+		t.Errorf("Expected the oldest entries to be dropped, got %v", messages)
+	}
+
+	bulk, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "queue-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer bulk.Cancel()
+	if err := bulk.SetQueueLimit(2, FireDropOldest); err == nil {
+		t.Error("Expected an error for a bulk processor hook")
+	}
+}
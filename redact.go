@@ -0,0 +1,156 @@
+package elogrus
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RedactedValue replaces the values of the fields redacted with RedactReplace.
+const RedactedValue = "[REDACTED]"
+
+// RedactionMode defines how the values of the redacted fields are replaced.
+type RedactionMode int
+
+const (
+	// RedactReplace replaces the values with RedactedValue.
+	RedactReplace RedactionMode = iota
+	// RedactHash replaces the values with the hex SHA-256 hash of their text,
+	// prefixed with "sha256:", so the entries with the same value can still
+	// be correlated. Short or guessable values, e.g. emails, can be found back
+	// from their hash by trying candidates, prefer RedactReplace for them.
+	RedactHash
+)
+
+// FieldMatcher reports whether a data field is redacted, see SetRedaction.
+type FieldMatcher func(key string) bool
+
+// RedactFields returns a FieldMatcher matching the fields with the names,
+// ignoring the case, e.g. RedactFields("password", "token", "email").
+func RedactFields(names ...string) FieldMatcher {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = true
+	}
+	return func(key string) bool {
+		return set[strings.ToLower(key)]
+	}
+}
+
+// maxRedactDepth bounds the nesting of the maps redacted, like the encoder
+// does, so a map holding itself does not recurse forever.
+const maxRedactDepth = 20
+
+// SetRedaction makes the hook replace the values of the data fields matched by
+// match before serializing the documents, so secrets and personal data never
+// reach the cluster; mode defines their replacement. The fields are matched by
+// their names in the entries, before SetFieldRenames applies, at any depth of
+// the maps and lists of maps in the values (e.g. the "password" key of a map
+// field), and the static fields of the configuration are redacted too. The
+// message of the entries, the fields of structs and the documents of the types
+// set with SetDocumentType are not. A nil match turns redaction off.
+func (hook *ElasticHook) SetRedaction(match FieldMatcher, mode RedactionMode) {
+	hook.redactMatch = match
+	hook.redactMode = mode
+}
+
+// redact replaces the values of the redacted fields of data and of the maps
+// nested in its values. The nested maps belong to the entry, the ones holding
+// redacted fields are replaced with redacted copies.
+func (hook *ElasticHook) redact(data logrus.Fields) {
+	if hook.redactMatch == nil {
+		return
+	}
+	for k, v := range data {
+		if hook.redactMatch(k) {
+			data[k] = hook.redactedValue(v)
+		} else if r, ok := hook.redactNested(v, 1); ok {
+			data[k] = r
+		}
+	}
+}
+
+// redactedValue returns the replacement of the value of a redacted field.
+func (hook *ElasticHook) redactedValue(v interface{}) interface{} {
+	if hook.redactMode == RedactHash {
+		sum := sha256.Sum256([]byte(fmt.Sprint(v)))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return RedactedValue
+}
+
+// redactNested returns a redacted copy of v and true if v is a map, or a list
+// of maps, holding redacted fields at any depth.
+func (hook *ElasticHook) redactNested(v interface{}, depth int) (interface{}, bool) {
+	if depth > maxRedactDepth {
+		return v, false
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return hook.redactMap(v, depth)
+	case logrus.Fields:
+		if r, ok := hook.redactMap(v, depth); ok {
+			return logrus.Fields(r), true
+		}
+	case map[string]string:
+		var r map[string]string
+		for k, s := range v {
+			if !hook.redactMatch(k) {
+				continue
+			}
+			if r == nil {
+				r = make(map[string]string, len(v))
+				for key, value := range v {
+					r[key] = value
+				}
+			}
+			r[k] = hook.redactedValue(s).(string)
+		}
+		if r != nil {
+			return r, true
+		}
+	case []interface{}:
+		var r []interface{}
+		for i, e := range v {
+			re, ok := hook.redactNested(e, depth+1)
+			if !ok {
+				continue
+			}
+			if r == nil {
+				r = append([]interface{}(nil), v...)
+			}
+			r[i] = re
+		}
+		if r != nil {
+			return r, true
+		}
+	}
+	return v, false
+}
+
+// redactMap returns a redacted copy of m and true if m holds redacted fields
+// at any depth.
+func (hook *ElasticHook) redactMap(m map[string]interface{}, depth int) (map[string]interface{}, bool) {
+	var r map[string]interface{}
+	for k, v := range m {
+		var rv interface{}
+		if hook.redactMatch(k) {
+			rv = hook.redactedValue(v)
+		} else if nested, ok := hook.redactNested(v, depth+1); ok {
+			rv = nested
+		} else {
+			continue
+		}
+		if r == nil {
+			r = make(map[string]interface{}, len(m))
+			for key, value := range m {
+				r[key] = value
+			}
+		}
+		r[k] = rv
+	}
+	return r, r != nil
+}
@@ -0,0 +1,93 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestSetRedaction(t *testing.T) {
+	for _, tt := range []struct {
+		mode  RedactionMode
+		token string
+	}{
+		{RedactReplace, RedactedValue},
+		// sha256 of "s3cret"
+		{RedactHash, "sha256:1ec1c26b50d5d3c58d9583181af8076655fe00756bf7285940ba3670f99fcba0"},
+	} {
+		f, client := newFakeElastic(t)
+		hook, err := NewElasticHookWithOptions(es8.New(client),
+			WithIndex("redacted-log"),
+			WithRedaction(RedactFields("Password", "token"), tt.mode),
+			WithFieldRenames(map[string]string{"token": "auth.token"}),
+		)
+		if err != nil {
+			t.Fatalf("Error creating the hook: %s", err)
+		}
+		doc := NewDocument().SetMessage("login").
+			AddField("password", "s3cret").
+			AddField("token", "s3cret").
+			AddField("user", "alice")
+		if err := hook.Submit(doc); err != nil {
+			t.Fatal(err)
+		}
+
+		reqs := f.Requests(http.MethodPost, "/redacted-log/_doc")
+		if len(reqs) != 1 {
+			t.Fatalf("Expected one document, got %d", len(reqs))
+		}
+		if strings.Contains(reqs[0].Body, `"s3cret"`) {
+			t.Errorf("Expected the secrets to be redacted: %s", reqs[0].Body)
+		}
+		var msg struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(reqs[0].Body), &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Data["password"] != tt.token || msg.Data["auth.token"] != tt.token || msg.Data["user"] != "alice" {
+			t.Errorf("Expected the secrets to be replaced with %s, got %v", tt.token, msg.Data)
+		}
+	}
+}
+
+func TestSetRedactionNested(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("redacted-log"),
+		WithRedaction(RedactFields("password"), RedactReplace),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	req := map[string]interface{}{
+		"user":  "alice",
+		"login": logrus.Fields{"password": "s3cret"},
+		"attempts": []interface{}{
+			map[string]interface{}{"password": "s3cret"},
+			"plain",
+		},
+		"headers": map[string]string{"password": "s3cret", "accept": "*/*"},
+	}
+	if err := hook.Submit(NewDocument().SetMessage("login").AddField("req", req)); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := f.Requests(http.MethodPost, "/redacted-log/_doc")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one document, got %d", len(reqs))
+	}
+	if strings.Contains(reqs[0].Body, `"s3cret"`) || strings.Count(reqs[0].Body, RedactedValue) != 3 ||
+		!strings.Contains(reqs[0].Body, `"accept":"*/*"`) || !strings.Contains(reqs[0].Body, `"plain"`) {
+		t.Errorf("Expected the nested secrets to be redacted: %s", reqs[0].Body)
+	}
+	// the maps of the entry are left as they are
+	if req["login"].(logrus.Fields)["password"] != "s3cret" || req["headers"].(map[string]string)["password"] != "s3cret" {
+		t.Errorf("Expected the fields of the entry to be kept: %v", req)
+	}
+}
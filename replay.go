@@ -0,0 +1,151 @@
+package elogrus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// DefaultReplayBatchSize is the number of documents sent per bulk request by Replay
+// when ReplayOptions.BatchSize is not set.
+const DefaultReplayBatchSize = 500
+
+// ReplayOptions configures Replay.
+type ReplayOptions struct {
+	// Index is the default index, used for documents that do not come with
+	// their own bulk action line (or whose action line has no _index).
+	Index string
+	// BatchSize is the maximum number of documents per bulk request.
+	BatchSize int
+	// Rate limits the number of documents sent per second, 0 means unlimited.
+	Rate float64
+	// Progress, if set, is called after every bulk request.
+	Progress func(stats ReplayStats)
+}
+
+// ReplayStats describes the progress of a Replay call.
+type ReplayStats struct {
+	Documents int   // documents sent
+	Failed    int   // documents rejected by Elasticsearch
+	Batches   int   // bulk requests made
+	Bytes     int64 // bytes sent
+}
+
+// Replay reads NDJSON from r and bulk-ingests it. Every line is either a document
+// or a bulk action line ({"index":{...}} or {"create":{...}}) followed by its document.
+// The segment files of a spill directory (see SetSpill) hold action lines naming
+// their index, the output of a FallbackWriter holds documents only and needs
+// ReplayOptions.Index.
+// Documents rejected by Elasticsearch are counted as failed; transport errors stop the replay.
+func Replay(ctx context.Context, client core.Client, r io.Reader, opts ReplayOptions) (ReplayStats, error) {
+	var stats ReplayStats
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultReplayBatchSize
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+
+	var buf bytes.Buffer
+	docs := 0
+	start := time.Now()
+	flush := func() error {
+		if docs == 0 {
+			return nil
+		}
+		failed, err := replayBulk(ctx, client, opts.Index, buf.Bytes())
+		if err != nil {
+			return err
+		}
+		stats.Documents += docs
+		stats.Failed += failed
+		stats.Batches++
+		stats.Bytes += int64(buf.Len())
+		buf.Reset()
+		docs = 0
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+		if opts.Rate > 0 {
+			due := start.Add(time.Duration(float64(stats.Documents) / opts.Rate * float64(time.Second)))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Until(due)):
+			}
+		}
+		return nil
+	}
+
+	var action []byte
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if action == nil && isBulkAction(line) {
+			action = append([]byte(nil), line...)
+			continue
+		}
+		if action == nil {
+			if opts.Index == "" {
+				return stats, fmt.Errorf("document without a bulk action line and no index configured")
+			}
+			action = []byte(`{"index":{}}`)
+		}
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(line)
+		buf.WriteByte('\n')
+		action = nil
+		docs++
+		if docs >= opts.BatchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, err
+	}
+	if action != nil {
+		return stats, fmt.Errorf("bulk action line without a document")
+	}
+	return stats, flush()
+}
+
+// isBulkAction reports whether a line is an index or create action line, i.e.
+// an object with the metadata object of the operation as its only field. Other
+// lines, even a document like {"index":"x"}, are documents.
+func isBulkAction(line []byte) bool {
+	var action map[string]json.RawMessage
+	if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+		return false
+	}
+	for op, meta := range action {
+		return (op == "index" || op == "create") && bytes.HasPrefix(meta, []byte("{"))
+	}
+	return false
+}
+
+func replayBulk(ctx context.Context, client core.Client, index string, data []byte) (int, error) {
+	res, err := client.Bulk(ctx, index, data)
+	if err != nil {
+		return 0, err
+	}
+	failed := 0
+	for _, item := range res.Items {
+		for _, result := range item {
+			if result.Status >= 300 {
+				failed++
+			}
+		}
+	}
+	return failed, nil
+}
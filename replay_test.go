@@ -0,0 +1,95 @@
+package elogrus
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestReplay(t *testing.T) {
+	f, client := newFakeElastic(t)
+
+	input := strings.Join([]string{
+		`{"message":"first"}`,
+		`{"create":{"_index":"other-log"}}`,
+		`{"message":"second"}`,
+		``,
+		`{"index":{"_id":"3"}}`,
+		`{"message":"third"}`,
+	}, "\n")
+
+	var progress []ReplayStats
+	stats, err := Replay(context.Background(), es8.New(client), strings.NewReader(input), ReplayOptions{
+		Index:     "replay-log",
+		BatchSize: 2,
+		Progress:  func(s ReplayStats) { progress = append(progress, s) },
+	})
+	if err != nil {
+		t.Fatalf("Error replaying: %s", err)
+	}
+	if stats.Documents != 3 || stats.Batches != 2 || stats.Failed != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if len(progress) != 2 {
+		t.Errorf("Expected 2 progress calls, got %d", len(progress))
+	}
+
+	reqs := f.Requests(http.MethodPost, "/replay-log/_bulk")
+	if len(reqs) != 2 {
+		t.Fatalf("Expected 2 bulk requests, got %d", len(reqs))
+	}
+	expected := `{"index":{}}` + "\n" + `{"message":"first"}` + "\n" +
+		`{"create":{"_index":"other-log"}}` + "\n" + `{"message":"second"}` + "\n"
+	if reqs[0].Body != expected {
+		t.Errorf("Unexpected first batch: %q", reqs[0].Body)
+	}
+	if reqs[1].Body != `{"index":{"_id":"3"}}`+"\n"+`{"message":"third"}`+"\n" {
+		t.Errorf("Unexpected second batch: %q", reqs[1].Body)
+	}
+}
+
+func TestReplayWithoutIndex(t *testing.T) {
+	_, client := newFakeElastic(t)
+
+	_, err := Replay(context.Background(), es8.New(client), strings.NewReader(`{"message":"first"}`), ReplayOptions{})
+	if err == nil {
+		t.Error("Expected an error for a document without an index")
+	}
+}
+
+func TestReplayDocumentsLikeActions(t *testing.T) {
+	f, client := newFakeElastic(t)
+
+	// Only an object under index or create is an action line.
+	input := strings.Join([]string{
+		`{"index":"x"}`,
+		`{"create":1}`,
+		`{"index":{},"message":"two keys"}`,
+		`{"index": {"_id":"4"}}`,
+		`{"index":null}`,
+	}, "\n")
+
+	stats, err := Replay(context.Background(), es8.New(client), strings.NewReader(input), ReplayOptions{Index: "replay-log"})
+	if err != nil {
+		t.Fatalf("Error replaying: %s", err)
+	}
+	if stats.Documents != 4 {
+		t.Errorf("Expected 4 documents, got %+v", stats)
+	}
+	reqs := f.Requests(http.MethodPost, "/replay-log/_bulk")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected 1 bulk request, got %d", len(reqs))
+	}
+	expected := strings.Join([]string{
+		`{"index":{}}`, `{"index":"x"}`,
+		`{"index":{}}`, `{"create":1}`,
+		`{"index":{}}`, `{"index":{},"message":"two keys"}`,
+		`{"index": {"_id":"4"}}`, `{"index":null}`,
+	}, "\n") + "\n"
+	if reqs[0].Body != expected {
+		t.Errorf("Unexpected batch: %q", reqs[0].Body)
+	}
+}
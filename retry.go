@@ -0,0 +1,109 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+const (
+	// DefaultRetryInitialInterval is the pause before the first retry of a RetryPolicy.
+	DefaultRetryInitialInterval = 100 * time.Millisecond
+	// DefaultRetryMaxInterval is the longest pause between two retries of a RetryPolicy.
+	DefaultRetryMaxInterval = 5 * time.Second
+)
+
+// RetryPolicy makes a synchronous hook resend the documents that failed with a
+// transient error (connection problems, throttling and server errors), so the
+// entries survive a cluster that is briefly unavailable. The pause between two
+// attempts doubles, with a random jitter of ±50%, up to the MaxInterval.
+// The zero value sends every document once.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, the first one included.
+	MaxAttempts int
+	// InitialInterval is the pause before the first retry, DefaultRetryInitialInterval if 0.
+	InitialInterval time.Duration
+	// MaxInterval is the longest pause between two attempts, DefaultRetryMaxInterval if 0.
+	MaxInterval time.Duration
+	// MaxElapsedTime, if positive, bounds the time since the first attempt:
+	// no retry is made that would start later.
+	MaxElapsedTime time.Duration
+}
+
+// SetRetryPolicy sets how the synchronous hook retries the documents failing
+// with a transient error. Fire returns the last error once the policy is
+// exhausted. The other hooks return an error, the bulk processor retries the
+// batches on its own.
+func (hook *ElasticHook) SetRetryPolicy(policy RetryPolicy) error {
+	if hook.batcher != nil || hook.pool != nil {
+		return errors.New("only synchronous hooks have a retry policy")
+	}
+	if policy.MaxAttempts < 0 || policy.InitialInterval < 0 || policy.MaxInterval < 0 || policy.MaxElapsedTime < 0 {
+		return errors.New("retry policy must not be negative")
+	}
+	if policy.InitialInterval == 0 {
+		policy.InitialInterval = DefaultRetryInitialInterval
+	}
+	if policy.MaxInterval == 0 {
+		policy.MaxInterval = DefaultRetryMaxInterval
+	}
+	hook.retryPolicy = policy
+	return nil
+}
+
+// SetRequeue makes a bulk processor hook add the documents the cluster rejected
+// one by one with a transient status (429 Too Many Requests or a server error),
+// while accepting the rest of their batch, back to its queue. They are sent with
+// the next batch, up to maxRequeues times, and are reported as failed only once
+// they are given up on. The other hooks return an error.
+func (hook *ElasticHook) SetRequeue(maxRequeues int) error {
+	if hook.batcher == nil {
+		return errors.New("only bulk processor hooks requeue documents")
+	}
+	if maxRequeues <= 0 {
+		return errors.New("requeues must be positive")
+	}
+	hook.batcher.SetRequeue(maxRequeues)
+	return nil
+}
+
+// indexWithRetries indexes a single document according to the retry policy of
+// the hook. The retries stop once ctx is done.
+func (hook *ElasticHook) indexWithRetries(ctx context.Context, index string, meta core.DocumentMeta, data []byte) (*core.IndexResponse, error) {
+	policy := hook.retryPolicy
+	start := time.Now()
+	interval := policy.InitialInterval
+	for attempt := 1; ; attempt++ {
+		res, err := hook.indexDocument(ctx, index, meta, data)
+		if err == nil || attempt >= policy.MaxAttempts || !core.IsRetryable(err) || errors.Is(err, core.ErrCircuitOpen) {
+			return res, err
+		}
+		delay := jitter(interval)
+		if policy.MaxElapsedTime > 0 && time.Since(start)+delay > policy.MaxElapsedTime {
+			return res, err
+		}
+		hook.debugf("retrying document for index %s in %s after attempt %d: %v", index, delay, attempt, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return res, err
+		case <-hook.ctx.Done():
+			timer.Stop()
+			return res, err
+		}
+		if interval *= 2; interval > policy.MaxInterval {
+			interval = policy.MaxInterval
+		}
+		hook.metrics.RequestRetried()
+	}
+}
+
+// jitter returns a random duration between 50% and 150% of d.
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d)+1))
+}
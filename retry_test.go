@@ -0,0 +1,101 @@
+package elogrus
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	f, client := newFakeElastic(t)
+	var failures, status atomic.Int32
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_doc") && failures.Add(-1) >= 0 {
+			w.WriteHeader(int(status.Load()))
+			_, _ = io.WriteString(w, `{"error":{"type":"some_exception","reason":"failed"}}`)
+			return true
+		}
+		return false
+	}
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "retry-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	// two transient failures are absorbed
+	failures.Store(2)
+	status.Store(http.StatusTooManyRequests)
+	if err := hook.Submit(NewDocument().SetMessage("first")); err != nil {
+		t.Errorf("Expected the document to be retried, got %s", err)
+	}
+	if n := len(f.Requests(http.MethodPost, "/retry-log/_doc")); n != 3 {
+		t.Errorf("Expected 3 attempts, got %d", n)
+	}
+
+	// the last error is returned once the attempts are exhausted
+	failures.Store(5)
+	if err := hook.Submit(NewDocument().SetMessage("second")); err == nil {
+		t.Error("Expected an error after 3 attempts")
+	}
+	if n := len(f.Requests(http.MethodPost, "/retry-log/_doc")); n != 6 {
+		t.Errorf("Expected 3 more attempts, got %d", n-3)
+	}
+
+	// other errors are not retried
+	failures.Store(1)
+	status.Store(http.StatusBadRequest)
+	var e *ResponseError
+	if err := hook.Submit(NewDocument().SetMessage("third")); !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected the bad request error, got %v", err)
+	}
+	if n := len(f.Requests(http.MethodPost, "/retry-log/_doc")); n != 7 {
+		t.Errorf("Expected a single attempt, got %d", n-6)
+	}
+}
+
+func TestRetryPolicyMaxElapsedTime(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_doc") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		return false
+	}
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "retry-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	policy := RetryPolicy{MaxAttempts: 100, InitialInterval: 10 * time.Millisecond, MaxElapsedTime: 50 * time.Millisecond}
+	if err := hook.SetRetryPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := hook.Submit(NewDocument().SetMessage("lost")); err == nil {
+		t.Error("Expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the retries to stop after the max elapsed time, took %s", elapsed)
+	}
+	if n := len(f.Requests(http.MethodPost, "/retry-log/_doc")); n < 2 || n > 10 {
+		t.Errorf("Unexpected number of attempts: %d", n)
+	}
+
+	async, err := NewAsyncElasticHook(client, "localhost", logrus.InfoLevel, "retry-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := async.SetRetryPolicy(policy); err == nil {
+		t.Error("Expected an error for an asynchronous hook")
+	}
+}
@@ -0,0 +1,64 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"time"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// RolloverResponse is the result of Rollover.
+type RolloverResponse = core.RolloverResponse
+
+// Rollover rolls the write alias or data stream of the hook over to a new backing
+// index, e.g. after fixing the mappings in the index template. With zero conditions
+// the rollover is forced, otherwise it only happens if one of them is met.
+// The hook keeps writing to the alias, the documents queued before the rollover
+// may end up in either index.
+func (hook *ElasticHook) Rollover(ctx context.Context, conditions RolloverConditions) (*RolloverResponse, error) {
+	res, err := hook.client.Rollover(ctx, hook.index(), conditions)
+	if err == nil && res.RolledOver {
+		hook.indexEvent(IndexEvent{Kind: IndexRolledOver, Index: res.NewIndex, OldIndex: res.OldIndex})
+	}
+	return res, err
+}
+
+// SetAutoRollover makes the hook call Rollover with conditions in the background
+// every interval, until it is closed, so the write alias of the hook rolls over
+// by age or size on clusters without ILM (or ISM), e.g. an alias bootstrapped with
+// WithBootstrap on a cluster where the lifecycle policies are disabled. Searches
+// keep using the alias while the backing indices rotate. At least one condition
+// must be set, the errors go to the ErrorHandler.
+func (hook *ElasticHook) SetAutoRollover(interval time.Duration, conditions RolloverConditions) error {
+	if interval <= 0 {
+		return errors.New("rollover interval must be positive")
+	}
+	if conditions == (RolloverConditions{}) {
+		return errors.New("automatic rollover needs at least one condition")
+	}
+	go func() {
+		pprof.SetGoroutineLabels(hook.labels)
+		for {
+			select {
+			case <-hook.ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			ctx, cancel := hook.requestContextFrom(hook.ctx)
+			res, err := hook.Rollover(ctx, conditions)
+			cancel()
+			switch {
+			case hook.ctx.Err() != nil:
+				return
+			case err != nil:
+				hook.handleError(fmt.Errorf("cannot roll over %s: %w", hook.index(), err), nil)
+			case res.RolledOver:
+				hook.debugf("rolled %s over from %s to %s", hook.index(), res.OldIndex, res.NewIndex)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,74 @@
+package elogrus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestRollover(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.URL.Path != "/rollover-log/_rollover" {
+			return false
+		}
+		_, _ = io.WriteString(w, `{"old_index":"rollover-log-000001","new_index":"rollover-log-000002","rolled_over":true,"conditions":{}}`)
+		return true
+	}
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "rollover-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+
+	res, err := hook.Rollover(context.Background(), RolloverConditions{})
+	if err != nil {
+		t.Fatalf("Error rolling over: %s", err)
+	}
+	if !res.RolledOver || res.OldIndex != "rollover-log-000001" || res.NewIndex != "rollover-log-000002" {
+		t.Errorf("Unexpected response: %+v", res)
+	}
+	if reqs := f.Requests(http.MethodPost, "/rollover-log/_rollover"); len(reqs) != 1 || reqs[0].Body != `{"conditions":{}}` {
+		t.Errorf("Unexpected rollover requests: %+v", reqs)
+	}
+}
+
+func TestSetAutoRollover(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.URL.Path != "/auto-rollover-log/_rollover" {
+			return false
+		}
+		_, _ = io.WriteString(w, `{"old_index":"auto-rollover-log-000001","new_index":"auto-rollover-log-000002","rolled_over":true,"conditions":{}}`)
+		return true
+	}
+	events := make(chan IndexEvent, 10)
+	hook, err := NewElasticHookWithOptions(es8.New(client), WithIndex("auto-rollover-log"))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+	hook.IndexEventHandler = func(event IndexEvent) { events <- event }
+	if err := hook.SetAutoRollover(time.Second, RolloverConditions{}); err == nil {
+		t.Error("Expected an error without conditions")
+	}
+	if err := hook.SetAutoRollover(10*time.Millisecond, RolloverConditions{MaxAge: "1d"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if event.Kind != IndexRolledOver || event.Index != "auto-rollover-log-000002" {
+			t.Errorf("Unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the alias to roll over")
+	}
+	if reqs := f.Requests(http.MethodPost, "/auto-rollover-log/_rollover"); len(reqs) == 0 || reqs[0].Body != `{"conditions":{"max_age":"1d"}}` {
+		t.Errorf("Unexpected rollover requests: %+v", reqs)
+	}
+}
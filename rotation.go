@@ -0,0 +1,114 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DailyIndexLayout is the time layout of the daily indices, e.g. "mylog-2022.03.04".
+	DailyIndexLayout = "2006.01.02"
+	// MonthlyIndexLayout is the time layout of the monthly indices, e.g. "mylog-2022.03".
+	MonthlyIndexLayout = "2006.01"
+)
+
+// IndexRotation is a time-based index naming scheme: the index name is Prefix
+// followed by the current UTC time formatted with Layout. Its Name method can be
+// used as the IndexNameFunc of a hook:
+//
+//	rotation := elogrus.IndexRotation{Prefix: "mylog-"}
+//	hook, err := elogrus.NewBulkProcessorElasticHookWithClient(client, "localhost", logrus.InfoLevel, rotation.Name)
+//	...
+//	hook.SetIndexRotation(rotation)
+type IndexRotation struct {
+	Prefix string
+	// Layout defaults to DailyIndexLayout.
+	Layout string
+	// Weekly names the indices after the Monday of the ISO week of the time,
+	// e.g. "mylog-2022.02.28" for all of the week with the default layout.
+	Weekly bool
+}
+
+// Name returns the name of the current index.
+func (r IndexRotation) Name() string {
+	return r.NameAt(time.Now())
+}
+
+// NameAt returns the name of the index for the time t.
+func (r IndexRotation) NameAt(t time.Time) string {
+	t = t.UTC()
+	if r.Weekly {
+		// time.Monday is 1, Sunday ends the ISO week
+		t = t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	}
+	return r.Prefix + t.Format(r.layout())
+}
+
+func (r IndexRotation) layout() string {
+	if r.Layout == "" {
+		return DailyIndexLayout
+	}
+	return r.Layout
+}
+
+// parse returns the time of an index named by the rotation.
+func (r IndexRotation) parse(index string) (time.Time, bool) {
+	if !strings.HasPrefix(index, r.Prefix) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(r.layout(), index[len(r.Prefix):])
+	return t, err == nil
+}
+
+// ErrNoIndexRotation is returned by PruneIndices and CloseIndices of a hook without an index rotation.
+var ErrNoIndexRotation = errors.New("the hook has no index rotation")
+
+// SetIndexRotation tells the hook the naming scheme of its indices. The entries
+// are then written to the index matching their time rather than the current one,
+// so delayed or replayed entries land in the right index; PruneIndices and
+// CloseIndices use it to find the old indices. The hook should be created with
+// rotation.Name as its IndexNameFunc.
+func (hook *ElasticHook) SetIndexRotation(rotation IndexRotation) {
+	hook.rotation = &rotation
+}
+
+// PruneIndices deletes the indices of the index rotation of the hook whose time is
+// more than olderThan ago, e.g. for clusters without ILM. The current index is
+// never deleted, other indices matching the prefix are left alone. It returns the
+// deleted indices.
+func (hook *ElasticHook) PruneIndices(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	return hook.expire(ctx, olderThan, hook.client.DeleteIndex)
+}
+
+// CloseIndices is like PruneIndices, but it closes the old indices instead of
+// deleting them, so they can be reopened if needed.
+func (hook *ElasticHook) CloseIndices(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	return hook.expire(ctx, olderThan, hook.client.CloseIndex)
+}
+
+func (hook *ElasticHook) expire(ctx context.Context, olderThan time.Duration, action func(ctx context.Context, index string) error) ([]string, error) {
+	if hook.rotation == nil {
+		return nil, ErrNoIndexRotation
+	}
+	indices, err := hook.client.ListIndices(ctx, hook.rotation.Prefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	current := hook.index()
+	cutoff := time.Now().Add(-olderThan)
+	var expired []string
+	for _, index := range indices {
+		t, ok := hook.rotation.parse(index)
+		if !ok || index == current || !t.Before(cutoff) {
+			continue
+		}
+		if err := action(ctx, index); err != nil {
+			return expired, fmt.Errorf("cannot expire index %s: %w", index, err)
+		}
+		expired = append(expired, index)
+	}
+	return expired, nil
+}
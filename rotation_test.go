@@ -0,0 +1,135 @@
+package elogrus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestPruneIndices(t *testing.T) {
+	f, client := newFakeElastic(t)
+	rotation := IndexRotation{Prefix: "rotated-"}
+	day := func(days int) string {
+		return "rotated-" + time.Now().UTC().AddDate(0, 0, -days).Format(DailyIndexLayout)
+	}
+	for _, index := range []string{day(40), day(31), day(5), "rotated-archive", "other-2020.01.01"} {
+		f.indices[index] = "{}"
+	}
+	hook, err := NewElasticHookWithClient(es8.New(client), "localhost", logrus.InfoLevel, rotation.Name)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if _, err := hook.PruneIndices(context.Background(), 30*24*time.Hour); !errors.Is(err, ErrNoIndexRotation) {
+		t.Errorf("Expected ErrNoIndexRotation, got %v", err)
+	}
+	hook.SetIndexRotation(rotation)
+
+	closed, err := hook.CloseIndices(context.Background(), 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("Error closing indices: %s", err)
+	}
+	if !reflect.DeepEqual(closed, []string{day(40), day(31)}) {
+		t.Errorf("Unexpected closed indices: %v", closed)
+	}
+	if len(f.Requests(http.MethodPost, "/"+day(40)+"/_close")) != 1 {
+		t.Errorf("Index %s not closed", day(40))
+	}
+
+	pruned, err := hook.PruneIndices(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Error pruning indices: %s", err)
+	}
+	if !reflect.DeepEqual(pruned, []string{day(40), day(31), day(5)}) {
+		t.Errorf("Unexpected pruned indices: %v", pruned)
+	}
+	for _, index := range []string{rotation.Name(), "rotated-archive", "other-2020.01.01"} {
+		if _, ok := f.indices[index]; !ok {
+			t.Errorf("Index %s should have been kept", index)
+		}
+	}
+}
+
+func TestEntryTimeIndex(t *testing.T) {
+	f, client := newFakeElastic(t)
+	rotation := IndexRotation{Prefix: "dated-"}
+	hook, err := NewBulkProcessorElasticHookWithClient(es8.New(client), "localhost", logrus.InfoLevel, rotation.Name)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	hook.SetIndexRotation(rotation)
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	yesterday := time.Now().AddDate(0, 0, -1)
+	logger.WithTime(yesterday).Info("delayed")
+	logger.Info("current")
+	if err := hook.batcher.Flush(); err != nil {
+		t.Fatalf("Error flushing: %s", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if len(f.Requests(http.MethodPut, "/"+rotation.NameAt(yesterday))) != 1 {
+		t.Errorf("Index %s not created", rotation.NameAt(yesterday))
+	}
+	var body string
+	for _, r := range f.Requests(http.MethodPost, "") {
+		body += r.Body
+	}
+	for _, index := range []string{rotation.NameAt(yesterday), rotation.Name()} {
+		if !strings.Contains(body, `{"index":{"_index":"`+index+`"}}`) {
+			t.Errorf("No document sent to %s: %s", index, body)
+		}
+	}
+}
+
+func TestIndexRotationPeriods(t *testing.T) {
+	at := time.Date(2022, 3, 6, 23, 0, 0, 0, time.UTC) // a Sunday
+	for _, tc := range []struct {
+		rotation IndexRotation
+		expected string
+	}{
+		{IndexRotation{Prefix: "daily-"}, "daily-2022.03.06"},
+		{IndexRotation{Prefix: "monthly-", Layout: MonthlyIndexLayout}, "monthly-2022.03"},
+		{IndexRotation{Prefix: "weekly-", Weekly: true}, "weekly-2022.02.28"},
+	} {
+		if name := tc.rotation.NameAt(at); name != tc.expected {
+			t.Errorf("Expected %s, got %s", tc.expected, name)
+		}
+	}
+	if name := (IndexRotation{Prefix: "weekly-", Weekly: true}).NameAt(at.AddDate(0, 0, 1)); name != "weekly-2022.03.07" {
+		t.Errorf("Expected a new index on Monday, got %s", name)
+	}
+}
+
+func TestWithTimeBasedIndex(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHookWithOptions(es8.New(client), WithTimeBasedIndex("monthly-", MonthlyIndexLayout))
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	now := time.Now().UTC()
+	lastMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Add(-time.Hour)
+	logger.WithTime(lastMonth).Info("delayed")
+	logger.Info("current")
+	for _, index := range []string{"monthly-" + lastMonth.Format(MonthlyIndexLayout), "monthly-" + now.Format(MonthlyIndexLayout)} {
+		if len(f.Requests(http.MethodPut, "/"+index)) != 1 {
+			t.Errorf("Index %s not created", index)
+		}
+		if len(f.Requests(http.MethodPost, "/"+index+"/_doc")) != 1 {
+			t.Errorf("No document sent to %s", index)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package elogrus
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sampler decides whether an entry is shipped, given the sample rate of its
+// level, see Config.SampleRate and Config.LevelSampleRates. It must be safe for
+// concurrent use.
+type Sampler interface {
+	Sample(entry *logrus.Entry, rate float64) bool
+}
+
+// SamplerFunc is a function implementing Sampler.
+type SamplerFunc func(entry *logrus.Entry, rate float64) bool
+
+// Sample implements Sampler.
+func (f SamplerFunc) Sample(entry *logrus.Entry, rate float64) bool {
+	return f(entry, rate)
+}
+
+// RandomSampler ships every entry with a probability of its sample rate, it is
+// the default Sampler.
+var RandomSampler Sampler = SamplerFunc(func(_ *logrus.Entry, rate float64) bool {
+	return rand.Float64() < rate
+})
+
+// HashSampler returns a deterministic Sampler, shipping the entries whose key
+// hashes to a value below their sample rate. The entries with the same key are
+// all shipped or all left out, e.g. all the entries of a request with
+//
+//	elogrus.HashSampler(func(entry *logrus.Entry) string {
+//		id, _ := entry.Data["request_id"].(string)
+//		return id
+//	})
+func HashSampler(key func(entry *logrus.Entry) string) Sampler {
+	return SamplerFunc(func(entry *logrus.Entry, rate float64) bool {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(key(entry)))
+		return float64(h.Sum64()) < rate*math.MaxUint64
+	})
+}
+
+// sample reports whether the entry is shipped according to the sample rates of
+// the configuration.
+func (cfg *Config) sample(entry *logrus.Entry) bool {
+	rate, ok := cfg.LevelSampleRates[entry.Level]
+	if !ok {
+		if cfg.SampleRate <= 0 || cfg.SampleRate >= 1 {
+			return true
+		}
+		rate = cfg.SampleRate
+	}
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	}
+	sampler := cfg.Sampler
+	if sampler == nil {
+		sampler = RandomSampler
+	}
+	return sampler.Sample(entry, rate)
+}
+
+func copySampleRates(rates map[logrus.Level]float64) map[logrus.Level]float64 {
+	if rates == nil {
+		return nil
+	}
+	c := make(map[logrus.Level]float64, len(rates))
+	for level, rate := range rates {
+		c[level] = rate
+	}
+	return c
+}
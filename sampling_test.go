@@ -0,0 +1,72 @@
+package elogrus
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLevelSampleRates(t *testing.T) {
+	cfg := &Config{
+		Level:            logrus.DebugLevel,
+		SampleRate:       0.5,
+		LevelSampleRates: map[logrus.Level]float64{logrus.ErrorLevel: 1, logrus.DebugLevel: 0},
+		// ships the entries sampled at less than 50%
+		Sampler: SamplerFunc(func(_ *logrus.Entry, rate float64) bool { return rate < 0.5 }),
+	}
+	for level, want := range map[logrus.Level]bool{
+		logrus.ErrorLevel: true,
+		logrus.InfoLevel:  false,
+		logrus.DebugLevel: false,
+	} {
+		if got := cfg.enabled(&logrus.Entry{Level: level}); got != want {
+			t.Errorf("Expected %s entries to be shipped: %v, got %v", level, want, got)
+		}
+	}
+	cfg.LevelSampleRates[logrus.DebugLevel] = 0.05
+	if !cfg.enabled(&logrus.Entry{Level: logrus.DebugLevel}) {
+		t.Error("Expected the debug entries to be sampled by the Sampler")
+	}
+
+	_, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "sampling-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.Reconfigure(Config{LevelSampleRates: map[logrus.Level]float64{logrus.InfoLevel: 1.5}}); err == nil {
+		t.Error("Expected an error for a sample rate above 1")
+	}
+	path := filepath.Join(t.TempDir(), "elogrus.json")
+	if err := os.WriteFile(path, []byte(`{"level_sample_rates":{"debug":0.05,"error":1}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hook.loadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if rates := hook.Config().LevelSampleRates; len(rates) != 2 || rates[logrus.DebugLevel] != 0.05 {
+		t.Errorf("Unexpected sample rates: %v", rates)
+	}
+}
+
+func TestHashSampler(t *testing.T) {
+	sampler := HashSampler(func(entry *logrus.Entry) string {
+		return fmt.Sprint(entry.Data["request_id"])
+	})
+	shipped := 0
+	for i := 0; i < 10000; i++ {
+		entry := &logrus.Entry{Data: logrus.Fields{"request_id": i}}
+		ok := sampler.Sample(entry, 0.1)
+		if ok {
+			shipped++
+		}
+		if sampler.Sample(entry, 0.1) != ok {
+			t.Fatalf("Expected the same decision for request %d", i)
+		}
+	}
+	if shipped < 800 || shipped > 1200 {
+		t.Errorf("Expected about 10%% of the requests to be shipped, got %d", shipped)
+	}
+}
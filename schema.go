@@ -0,0 +1,88 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// DefaultSchemaVersion is the document schema version stamped by hooks
+// that were not given an explicit version with SetSchemaVersion.
+const DefaultSchemaVersion = 1
+
+// SchemaMigrationFunc is called by SetSchemaVersion when the schema version
+// recorded in the index differs from the configured one. from is 0 when the
+// index carries no version information (e.g. it was created by an older release).
+// Returning an error aborts the version change.
+type SchemaMigrationFunc func(ctx context.Context, client core.Client, index string, from, to int) error
+
+type indexMeta struct {
+	SchemaVersion int `json:"schema_version"`
+}
+
+// SetSchemaVersion sets the schema version stamped into every document.
+// The version recorded in the index mapping metadata is compared with the new one,
+// and if they differ, migrate (if not nil) is called before the new version is recorded.
+// The indices managed externally (see WithExternalIndices) are left as is, only
+// the documents get the new version.
+// It should be called before the hook is added to a logger.
+func (hook *ElasticHook) SetSchemaVersion(version int, migrate SchemaMigrationFunc) error {
+	if hook.externalIndices {
+		hook.schemaVersion = version
+		return nil
+	}
+	index := hook.index()
+	current, err := hook.indexSchemaVersion(index)
+	if err != nil {
+		return err
+	}
+	if current != version {
+		if migrate != nil {
+			if err := migrate(hook.ctx, hook.client, index, current, version); err != nil {
+				return err
+			}
+		}
+		if err := hook.putIndexSchemaVersion(index, version); err != nil {
+			return err
+		}
+	}
+	hook.schemaVersion = version
+	return nil
+}
+
+// SchemaVersion returns the schema version stamped into every document.
+func (hook *ElasticHook) SchemaVersion() int {
+	return hook.schemaVersion
+}
+
+func (hook *ElasticHook) indexSchemaVersion(index string) (int, error) {
+	mapping, err := hook.client.GetMapping(hook.ctx, index)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get mapping of %s: %w", index, err)
+	}
+	if mapping == nil {
+		return 0, nil
+	}
+	var m struct {
+		Meta indexMeta `json:"_meta"`
+	}
+	if err := json.Unmarshal(mapping, &m); err != nil {
+		return 0, fmt.Errorf("failure to parse mapping: %s", err.Error())
+	}
+	return m.Meta.SchemaVersion, nil
+}
+
+func (hook *ElasticHook) putIndexSchemaVersion(index string, version int) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"_meta": indexMeta{SchemaVersion: version},
+	})
+	if err != nil {
+		return err
+	}
+	if err := hook.client.PutMapping(hook.ctx, index, body); err != nil {
+		return fmt.Errorf("cannot put mapping of %s: %w", index, err)
+	}
+	return nil
+}
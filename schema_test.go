@@ -0,0 +1,95 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+func TestSetSchemaVersion(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.URL.Path == "/schema-log/_mapping" && r.Method == http.MethodGet {
+			_, _ = io.WriteString(w, `{"schema-log-000001":{"mappings":{"_meta":{"schema_version":1}}}}`)
+			return true
+		}
+		if r.URL.Path == "/schema-log/_mapping" && r.Method == http.MethodPut {
+			_, _ = io.WriteString(w, `{"acknowledged":true}`)
+			return true
+		}
+		return false
+	}
+
+	hook, err := NewElasticHook(client, "localhost", logrus.DebugLevel, "schema-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if hook.SchemaVersion() != DefaultSchemaVersion {
+		t.Fatalf("Unexpected default schema version: %d", hook.SchemaVersion())
+	}
+
+	var from, to int
+	err = hook.SetSchemaVersion(2, func(ctx context.Context, client core.Client, index string, f, t int) error {
+		from, to = f, t
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error setting the schema version: %s", err)
+	}
+	if from != 1 || to != 2 {
+		t.Errorf("Unexpected migration call: from %d to %d", from, to)
+	}
+	if puts := f.Requests(http.MethodPut, "/schema-log/_mapping"); len(puts) != 1 {
+		t.Errorf("Expected the new version to be recorded once, got %d requests", len(puts))
+	}
+
+	doc, _ := createMessage(logrus.NewEntry(logrus.New()), hook)
+	msg := doc.(*Message)
+	if msg.SchemaVersion != 2 {
+		t.Errorf("Unexpected document schema version: %d", msg.SchemaVersion)
+	}
+}
+
+func TestSetSchemaVersionUnchanged(t *testing.T) {
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.DebugLevel, "schema-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+
+	created := f.Requests(http.MethodPut, "/schema-log")
+	if len(created) != 1 {
+		t.Fatalf("Expected the index to be created")
+	}
+	var body struct {
+		Mappings struct {
+			Meta indexMeta `json:"_meta"`
+		} `json:"mappings"`
+	}
+	if err := json.Unmarshal([]byte(created[0].Body), &body); err != nil {
+		t.Fatalf("Error parsing the create index body: %s", err)
+	}
+	f.handle = func(w http.ResponseWriter, r *http.Request, _ string) bool {
+		if r.URL.Path == "/schema-log/_mapping" && r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"schema-log": map[string]interface{}{"mappings": body.Mappings},
+			})
+			return true
+		}
+		return false
+	}
+
+	err = hook.SetSchemaVersion(DefaultSchemaVersion, func(context.Context, core.Client, string, int, int) error {
+		t.Error("Unexpected migration call")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error setting the schema version: %s", err)
+	}
+}
@@ -0,0 +1,95 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSearchSize is the number of documents returned by Search by default.
+const DefaultSearchSize = 10
+
+// QueryOptions select the documents returned by Search. Empty options match all documents.
+type QueryOptions struct {
+	// Levels of the documents, all levels if empty.
+	Levels []logrus.Level
+	// Fields the data of the documents must contain, with exactly these values.
+	Fields logrus.Fields
+	// Message words the message of the documents must contain.
+	Message string
+	// Since excludes the documents older than this time.
+	Since time.Time
+	// Size is the maximum number of documents, DefaultSearchSize if 0.
+	Size int
+}
+
+// query builds the query DSL for the options, with the level labels and the
+// timestamp format of the hook.
+func (opts QueryOptions) query(hook *ElasticHook) map[string]interface{} {
+	var filter []interface{}
+	if len(opts.Levels) > 0 {
+		levels := make([]string, len(opts.Levels))
+		for i, level := range opts.Levels {
+			levels[i] = hook.levelLabel(level)
+		}
+		filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{hook.fieldName("level"): levels}})
+	}
+	for k, v := range opts.Fields {
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{hook.dataField(k): v}})
+	}
+	if opts.Message != "" {
+		filter = append(filter, map[string]interface{}{
+			"match": map[string]interface{}{hook.fieldName("message"): map[string]interface{}{"query": opts.Message, "operator": "and"}},
+		})
+	}
+	if !opts.Since.IsZero() {
+		filter = append(filter, map[string]interface{}{
+			"range": map[string]interface{}{hook.timestampField(): map[string]interface{}{"gte": hook.formatTime(opts.Since)}},
+		})
+	}
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultSearchSize
+	}
+	return map[string]interface{}{
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filter}},
+		"sort":  []interface{}{map[string]interface{}{hook.timestampField(): "desc"}},
+		"size":  size,
+	}
+}
+
+// Search returns the most recent documents of the index of the hook matching
+// the options, the newest first. It is meant for admin endpoints and smoke tests
+// and expects the default document format.
+func (hook *ElasticHook) Search(ctx context.Context, opts QueryOptions) ([]*Document, error) {
+	body, err := json.Marshal(opts.query(hook))
+	if err != nil {
+		return nil, err
+	}
+	res, err := hook.client.Search(ctx, hook.index(), body)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]*Document, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var msg Message
+		if err := hook.unmarshalMessage(hit.Source, &msg); err != nil {
+			return nil, fmt.Errorf("cannot parse document %s: %w", hit.ID, err)
+		}
+		doc := NewDocument().SetMessage(msg.Message)
+		if level, ok := hook.parseLevelLabel(msg.Level); ok {
+			doc.SetLevel(level)
+		}
+		if ts, err := hook.parseTime(msg.Timestamp); err == nil {
+			doc.SetTimestamp(ts)
+		}
+		for k, v := range msg.Data {
+			doc.AddField(k, v)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
@@ -0,0 +1,73 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSearch(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.URL.Path != "/search-log/_search" {
+			return false
+		}
+		_, _ = io.WriteString(w, `{"took":1,"hits":{"hits":[{"_index":"search-log","_id":"1","_source":`+
+			`{"@timestamp":"2022-03-04T05:06:07Z","message":"payment failed","level":"ERROR","data":{"user":"joe"}}}]}}`)
+		return true
+	}
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "search-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+
+	since := time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC)
+	docs, err := hook.Search(context.Background(), QueryOptions{
+		Levels:  []logrus.Level{logrus.ErrorLevel, logrus.WarnLevel},
+		Fields:  logrus.Fields{"user": "joe"},
+		Message: "failed",
+		Since:   since,
+	})
+	if err != nil {
+		t.Fatalf("Error searching: %s", err)
+	}
+	if len(docs) != 1 || docs[0].Message() != "payment failed" || docs[0].Level() != logrus.ErrorLevel ||
+		!docs[0].Timestamp().Equal(time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)) || docs[0].Fields()["user"] != "joe" {
+		t.Errorf("Unexpected documents: %+v", docs)
+	}
+
+	reqs := f.Requests(http.MethodPost, "/search-log/_search")
+	if len(reqs) != 1 {
+		t.Fatalf("Expected one search request, got %d", len(reqs))
+	}
+	var query struct {
+		Query struct {
+			Bool struct {
+				Filter []map[string]json.RawMessage `json:"filter"`
+			} `json:"bool"`
+		} `json:"query"`
+		Size int `json:"size"`
+	}
+	if err := json.Unmarshal([]byte(reqs[0].Body), &query); err != nil {
+		t.Fatalf("Error parsing the query: %s", err)
+	}
+	expected := []string{
+		`{"terms":{"level":["ERROR","WARNING"]}}`,
+		`{"term":{"data.user":"joe"}}`,
+		`{"match":{"message":{"operator":"and","query":"failed"}}}`,
+		`{"range":{"@timestamp":{"gte":"2022-03-04T00:00:00Z"}}}`,
+	}
+	if len(query.Query.Bool.Filter) != len(expected) || query.Size != DefaultSearchSize {
+		t.Fatalf("Unexpected query: %s", reqs[0].Body)
+	}
+	for i, filter := range query.Query.Bool.Filter {
+		if data, _ := json.Marshal(filter); string(data) != expected[i] {
+			t.Errorf("Unexpected filter %d: %s", i, data)
+		}
+	}
+}
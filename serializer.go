@@ -0,0 +1,154 @@
+package elogrus
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// DefaultSerializer is the name of the serializer used by default.
+const DefaultSerializer = "json"
+
+// Serializer encodes the documents sent to the cluster, e.g. as CBOR or SMILE,
+// which Elasticsearch accepts as well as JSON and which take less CPU and bandwidth.
+type Serializer interface {
+	// ContentType of the encoded documents, e.g. "application/cbor".
+	ContentType() string
+	// Marshal encodes a document. It may return data together with an error if
+	// the document was encoded with some values replaced, as the JSON serializer
+	// does with an EncodeError. If it fails on a document in the DefaultFormat,
+	// the data fields it cannot encode are replaced with placeholders and the
+	// document is encoded again.
+	Marshal(v interface{}) ([]byte, error)
+}
+
+// jsonSerializer is the default serializer.
+type jsonSerializer struct{}
+
+func (jsonSerializer) ContentType() string {
+	return "application/json"
+}
+
+func (jsonSerializer) Marshal(v interface{}) ([]byte, error) {
+	return core.Encode(v)
+}
+
+var (
+	serializersMu sync.RWMutex
+	serializers   = map[string]Serializer{DefaultSerializer: jsonSerializer{}}
+)
+
+// RegisterSerializer makes a serializer available under a name, e.g. "cbor"
+// with a CBOR library:
+//
+//	type cborSerializer struct{}
+//
+//	func (cborSerializer) ContentType() string                   { return "application/cbor" }
+//	func (cborSerializer) Marshal(v interface{}) ([]byte, error) { return cbor.Marshal(v) }
+//
+//	elogrus.RegisterSerializer("cbor", cborSerializer{})
+func RegisterSerializer(name string, s Serializer) {
+	serializersMu.Lock()
+	defer serializersMu.Unlock()
+	serializers[name] = s
+}
+
+// lookupSerializer returns the serializer registered under a name.
+func lookupSerializer(name string) (Serializer, bool) {
+	serializersMu.RLock()
+	defer serializersMu.RUnlock()
+	s, ok := serializers[name]
+	return s, ok
+}
+
+// SetSerializer makes the hook encode its documents with a registered serializer,
+// DefaultSerializer by default. The bulk API only accepts JSON, so the bulk
+// processor hooks and the hooks with a mirror can only use the JSON serializer.
+// UseLoggerFormatter has no effect with another serializer.
+func (hook *ElasticHook) SetSerializer(name string) error {
+	s, ok := lookupSerializer(name)
+	if !ok {
+		return fmt.Errorf("unknown serializer: %s", name)
+	}
+	if _, isJSON := s.(jsonSerializer); isJSON {
+		hook.serializer = nil
+		return nil
+	}
+	if hook.batcher != nil || hook.mirror != nil {
+		return errors.New("bulk requests can only be sent as JSON")
+	}
+	if hook.timestampFormat.Field != "" {
+		return errors.New("only JSON documents can have another timestamp field")
+	}
+	if hook.flatFields {
+		return errors.New("only JSON documents can be flat")
+	}
+	hook.serializer = s
+	return nil
+}
+
+// marshal encodes a document with the serializer of the hook.
+func (hook *ElasticHook) marshal(v interface{}) ([]byte, error) {
+	if msg, ok := v.(*Message); ok && hook.flatFields {
+		return hook.marshalFlat(msg)
+	}
+	v = hook.renameFields(v)
+	if hook.serializer == nil {
+		return core.Encode(v)
+	}
+	data, err := safeSerialize(hook.serializer, v)
+	if msg, ok := v.(*Message); ok && data == nil && err != nil {
+		return hook.marshalFields(msg, err)
+	}
+	return data, err
+}
+
+// marshalFields encodes a document in the DefaultFormat that the serializer of
+// the hook failed to encode with err, replacing the data fields it cannot encode
+// with placeholders as the JSON serializer does, so the entry is still shipped.
+func (hook *ElasticHook) marshalFields(msg *Message, err error) ([]byte, error) {
+	fields := make(logrus.Fields, len(msg.Data))
+	var replaced []string
+	for k, v := range msg.Data {
+		if _, ferr := safeSerialize(hook.serializer, v); ferr != nil && v != nil {
+			v = "[unencodable " + reflect.TypeOf(v).String() + "]"
+			replaced = append(replaced, hook.dataField(k))
+		}
+		fields[k] = v
+	}
+	if len(replaced) == 0 {
+		return nil, err
+	}
+	sanitized := *msg
+	sanitized.Data = fields
+	data, serr := safeSerialize(hook.serializer, &sanitized)
+	if serr != nil {
+		return nil, err
+	}
+	sort.Strings(replaced)
+	return data, &EncodeError{Err: err, Replaced: replaced}
+}
+
+// safeSerialize is s.Marshal that converts panics into errors.
+func safeSerialize(s Serializer, v interface{}) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			data, err = nil, fmt.Errorf("panic while encoding: %v", r)
+		}
+	}()
+	return s.Marshal(v)
+}
+
+// contentType returns the content type of the documents of the hook, empty for JSON.
+func (hook *ElasticHook) contentType() string {
+	if hook.serializer == nil {
+		return ""
+	}
+	return hook.serializer.ContentType()
+}
@@ -0,0 +1,123 @@
+package elogrus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type textSerializer struct{}
+
+func (textSerializer) ContentType() string {
+	return "text/plain"
+}
+
+func (textSerializer) Marshal(v interface{}) ([]byte, error) {
+	return []byte(fmt.Sprintf("%s", v.(*Message).Message)), nil
+}
+
+func TestSerializer(t *testing.T) {
+	RegisterSerializer("text", textSerializer{})
+	f, client := newFakeElastic(t)
+	var contentType, body string
+	f.handle = func(w http.ResponseWriter, r *http.Request, b string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_doc") {
+			contentType, body = r.Header.Get("Content-Type"), b
+		}
+		return false
+	}
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "serializer-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetSerializer("unknown"); err == nil {
+		t.Error("Expected an error for an unknown serializer")
+	}
+	if err := hook.SetSerializer("text"); err != nil {
+		t.Fatalf("Error setting the serializer: %s", err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+
+	logger.Info("plain")
+	if contentType != "text/plain" || body != "plain" {
+		t.Errorf("Unexpected document: %s %q", contentType, body)
+	}
+
+	bulkHook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "serializer-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := bulkHook.SetSerializer("text"); err == nil {
+		t.Error("Expected an error for a bulk processor hook")
+	}
+	if err := bulkHook.SetSerializer(DefaultSerializer); err != nil {
+		t.Errorf("Error setting the JSON serializer: %s", err)
+	}
+}
+
+// strictSerializer fails on the documents json.Marshal cannot encode.
+type strictSerializer struct{}
+
+func (strictSerializer) ContentType() string {
+	return "application/vnd.strict+json"
+}
+
+func (strictSerializer) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+type strictNode struct{ Next *strictNode }
+
+func TestSerializerUnencodableFields(t *testing.T) {
+	RegisterSerializer("strict", strictSerializer{})
+	f, client := newFakeElastic(t)
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "strict-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetSerializer("strict"); err != nil {
+		t.Fatalf("Error setting the serializer: %s", err)
+	}
+	var handled error
+	hook.ErrorHandler = func(err error, data []byte) { handled = err }
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(hook)
+
+	cyclic := &strictNode{}
+	cyclic.Next = cyclic
+	logger.WithFields(logrus.Fields{"c": make(chan int), "cyclic": cyclic, "user": "joe"}).Info("partial")
+
+	docs := f.Requests(http.MethodPost, "/strict-log/_doc")
+	if len(docs) != 1 {
+		t.Fatalf("Expected the entry to be shipped, got %d documents", len(docs))
+	}
+	var doc struct {
+		Message string                 `json:"message"`
+		Data    map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(docs[0].Body), &doc); err != nil {
+		t.Fatalf("Invalid document %s: %s", docs[0].Body, err)
+	}
+	want := map[string]interface{}{
+		"c":      "[unencodable chan int]",
+		"cyclic": "[unencodable *elogrus.strictNode]",
+		"user":   "joe",
+	}
+	if doc.Message != "partial" || !reflect.DeepEqual(doc.Data, want) {
+		t.Errorf("Expected the fields %v, got %s", want, docs[0].Body)
+	}
+	var e *EncodeError
+	if !errors.As(handled, &e) || !reflect.DeepEqual(e.Replaced, []string{"data.c", "data.cyclic"}) {
+		t.Errorf("Expected an EncodeError for the replaced fields, got %v", handled)
+	}
+}
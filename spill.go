@@ -0,0 +1,158 @@
+package elogrus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+	"gopkg.in/go-extras/elogrus.v8/internal/spill"
+)
+
+// DefaultSpillSegmentSize is the size of the files of a spill directory, it
+// limits the size of a spilled batch.
+const DefaultSpillSegmentSize = 16 << 20
+
+// ErrSpillFull is reported when a batch does not fit in the spill directory.
+var ErrSpillFull = errors.New("spill directory is full")
+
+// spillReplayInterval is how often the spilled batches are replayed.
+var spillReplayInterval = 5 * time.Second
+
+// spiller keeps the batches of a bulk processor hook on disk while the cluster
+// is unreachable and replays them once it is back.
+type spiller struct {
+	// mu serializes the replays and closing the queue
+	mu      sync.Mutex
+	queue   *spill.Queue
+	maxSize int64
+}
+
+// SetSpill makes a bulk processor hook write the batches it cannot send because
+// of a transient error (e.g. the cluster is unreachable) as NDJSON to a bounded
+// queue in dir, instead of reporting them as failed. The spilled batches are
+// replayed in the background once the cluster is reachable again, in the order
+// they were spilled, and are kept across restarts of the process until the
+// cluster has acknowledged them. The action lines of the spilled batches name
+// the index they were sent to, so the segment files of dir can also be replayed
+// as they are, see Replay. The batches that do not fit in maxSize bytes are
+// reported as failed with ErrSpillFull. The other hooks return an error.
+func (hook *ElasticHook) SetSpill(dir string, maxSize int64) error {
+	if hook.batcher == nil {
+		return errors.New("only bulk processor hooks can spill")
+	}
+	if hook.spiller != nil {
+		return errors.New("the hook already spills")
+	}
+	if maxSize <= 0 {
+		return errors.New("spill size must be positive")
+	}
+	segmentSize := int64(DefaultSpillSegmentSize)
+	if segmentSize > maxSize {
+		segmentSize = maxSize
+	}
+	queue, err := spill.Open(dir, segmentSize)
+	if err != nil {
+		return err
+	}
+	hook.spiller = &spiller{queue: queue, maxSize: maxSize}
+	hook.batcher.SetSpillHandler(hook.spill)
+	interval := spillReplayInterval
+	go func() {
+		pprof.SetGoroutineLabels(hook.labels)
+		for {
+			select {
+			case <-hook.ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+			hook.replaySpilled()
+		}
+	}()
+	return nil
+}
+
+// Spilled returns the number of bytes of the batches waiting in the spill directory.
+func (hook *ElasticHook) Spilled() int64 {
+	if hook.spiller == nil {
+		return 0
+	}
+	return hook.spiller.queue.Len()
+}
+
+// spill writes a batch to the spill queue.
+func (hook *ElasticHook) spill(batch []byte) error {
+	s := hook.spiller
+	// the segment files take the bytes of their batches and nothing more
+	if s.queue.Len()+int64(len(batch)) > s.maxSize {
+		return ErrSpillFull
+	}
+	if err := s.queue.Write(batch); err != nil {
+		hook.handleError(fmt.Errorf("cannot spill batch: %w", err), batch)
+		return err
+	}
+	return nil
+}
+
+// replaySpilled sends the spilled batches, a segment at a time, until the queue
+// is empty or the cluster is still unreachable.
+func (hook *ElasticHook) replaySpilled() {
+	s := hook.spiller
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queue.Len() == 0 || hook.ctx.Err() != nil {
+		return
+	}
+	index := hook.targetIndex()
+	if !hook.externalIndices {
+		ctx, cancel := hook.requestContext()
+		_, err := hook.client.IndexExists(ctx, index)
+		cancel()
+		if err != nil {
+			// still unreachable
+			return
+		}
+	}
+	for {
+		buf, err := s.queue.Peek()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			hook.handleError(fmt.Errorf("cannot read spilled batches: %w", err), nil)
+			return
+		}
+		ctx, cancel := hook.requestContext()
+		res, err := hook.client.Bulk(ctx, index, buf.Bytes())
+		cancel()
+		if err != nil && core.IsRetryable(err) {
+			// keep them at the head of the queue for the next attempt
+			buf.Release()
+			return
+		}
+		if err != nil {
+			hook.handleError(fmt.Errorf("cannot replay spilled batches: %w", err), buf.Bytes())
+		} else if res.Errors {
+			hook.handleError(errors.New("some spilled documents were rejected"), nil)
+		}
+		buf.Release()
+		if err := s.queue.Remove(); err != nil {
+			hook.handleError(fmt.Errorf("cannot remove spilled batches: %w", err), nil)
+			return
+		}
+	}
+}
+
+// closeSpill stops spilling and closes the spill queue, keeping the batches
+// that were not replayed for the next run.
+func (hook *ElasticHook) closeSpill() {
+	s := hook.spiller
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.queue.Close(); err != nil {
+		hook.handleError(fmt.Errorf("cannot close spill queue: %w", err), nil)
+	}
+}
@@ -0,0 +1,217 @@
+package elogrus
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestSpill(t *testing.T) {
+	spillReplayInterval = 10 * time.Millisecond
+	f, client := newFakeElastic(t)
+	var down atomic.Bool
+	down.Store(true)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_bulk") && down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		return false
+	}
+	hook, err := NewBulkProcessorElasticHook(client, "localhost", logrus.InfoLevel, "spill-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetSpill(t.TempDir(), 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	var dead atomic.Int32
+	hook.DeadLetterHandler = func(*logrus.Entry, []byte, error) { dead.Add(1) }
+
+	for i := 0; i < 3; i++ {
+		if err := hook.Submit(NewDocument().SetMessage("during the outage")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hook.Flush(context.Background()); err != nil {
+		t.Fatalf("Expected the spilled entries not to be reported as failed, got %s", err)
+	}
+	if hook.Spilled() == 0 || dead.Load() != 0 {
+		t.Fatalf("Expected the batch to be spilled, %d bytes spilled, %d dead letters", hook.Spilled(), dead.Load())
+	}
+
+	down.Store(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for hook.Spilled() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if hook.Spilled() != 0 {
+		t.Fatalf("Expected the spilled batch to be replayed, %d bytes left", hook.Spilled())
+	}
+	reqs := f.Requests(http.MethodPost, "/spill-log/_bulk")
+	if last := reqs[len(reqs)-1]; strings.Count(last.Body, "during the outage") != 3 {
+		t.Errorf("Unexpected replayed batch: %s", last.Body)
+	}
+	if err := hook.Close(context.Background()); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+}
+
+func TestSpillReplay(t *testing.T) {
+	spillReplayInterval = 10 * time.Millisecond
+	f, client := newFakeElastic(t)
+	var down atomic.Bool
+	down.Store(true)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_bulk") && down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		return false
+	}
+	dir := t.TempDir()
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("old-log"),
+		WithDeliveryMode(BulkDelivery),
+		WithSpill(dir, 1<<20),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := hook.Submit(NewDocument().SetMessage("spilled under the old index")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hook.Close(context.Background()); err != nil {
+		t.Fatalf("Expected the entries to be spilled, got %s", err)
+	}
+
+	// the segment files replay as they are
+	down.Store(false)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	if len(files) == 0 {
+		t.Fatal("Expected spilled segment files")
+	}
+	var stats ReplayStats
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := Replay(context.Background(), es8.New(client), file, ReplayOptions{})
+		file.Close()
+		if err != nil {
+			t.Fatalf("Error replaying %s: %s", name, err)
+		}
+		stats.Documents += s.Documents
+	}
+	if stats.Documents != 3 {
+		t.Errorf("Expected 3 replayed documents, got %+v", stats)
+	}
+	for _, req := range f.Requests(http.MethodPost, "/_bulk") {
+		if strings.Count(req.Body, `{"index":{"_index":"old-log"}}`) != strings.Count(req.Body, "\n")/2 {
+			t.Errorf("Expected every document to keep its index: %s", req.Body)
+		}
+	}
+
+	// the hook replays them into the index they were spilled from as well
+	hook, err = NewElasticHookWithOptions(es8.New(client),
+		WithIndex("new-log"),
+		WithDeliveryMode(BulkDelivery),
+		WithSpill(dir, 1<<20),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Close(context.Background())
+	deadline := time.Now().Add(5 * time.Second)
+	for hook.Spilled() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	reqs := f.Requests(http.MethodPost, "/new-log/_bulk")
+	if len(reqs) == 0 || strings.Count(reqs[0].Body, `{"index":{"_index":"old-log"}}`) != 3 {
+		t.Errorf("Expected the spilled documents to be replayed into their index, got %+v", reqs)
+	}
+}
+
+func TestSpillReplayFailure(t *testing.T) {
+	spillReplayInterval = 10 * time.Millisecond
+	f, client := newFakeElastic(t)
+	var down atomic.Bool
+	down.Store(true)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_bulk") && down.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		return false
+	}
+	dir := t.TempDir()
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("spill-log"),
+		WithDeliveryMode(BulkDelivery),
+		WithSpill(dir, 1<<20),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	for _, msg := range []string{"first", "second"} {
+		if err := hook.Submit(NewDocument().SetMessage(msg)); err != nil {
+			t.Fatal(err)
+		}
+		_ = hook.Flush(context.Background())
+	}
+	spilled := hook.Spilled()
+
+	// The failed replays keep the batches, and so does a restart.
+	sent := len(f.Requests(http.MethodPost, "/spill-log/_bulk"))
+	deadline := time.Now().Add(5 * time.Second)
+	for len(f.Requests(http.MethodPost, "/spill-log/_bulk")) < sent+2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if hook.Spilled() != spilled {
+		t.Fatalf("Expected the failed replays to keep %d bytes, got %d", spilled, hook.Spilled())
+	}
+	if err := hook.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	down.Store(false)
+	hook, err = NewElasticHookWithOptions(es8.New(client),
+		WithIndex("spill-log"),
+		WithDeliveryMode(BulkDelivery),
+		WithSpill(dir, 1<<20),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Close(context.Background())
+	if hook.Spilled() != spilled {
+		t.Fatalf("Expected %d spilled bytes after a restart, got %d", spilled, hook.Spilled())
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for hook.Spilled() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	var replayed string
+	for _, req := range f.Requests(http.MethodPost, "/spill-log/_bulk")[sent:] {
+		if !strings.Contains(req.Body, `"_index"`) {
+			continue
+		}
+		replayed += req.Body
+	}
+	first, second := strings.LastIndex(replayed, `"first"`), strings.LastIndex(replayed, `"second"`)
+	if first < 0 || second < first {
+		t.Errorf("Expected the batches to be replayed in order, got %s", replayed)
+	}
+}
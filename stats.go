@@ -0,0 +1,112 @@
+package elogrus
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats describes the health of a hook, see Stats.
+type Stats struct {
+	// Delivered is the number of documents written.
+	Delivered uint64 `json:"delivered"`
+	// Failed is the number of documents that could not be written.
+	Failed uint64 `json:"failed"`
+	// Dropped is the number of documents dropped before they were sent.
+	Dropped uint64 `json:"dropped"`
+	// Buffered is the number of documents waiting for delivery.
+	Buffered int `json:"buffered"`
+	// LastError is the last error of the hook, see ErrorHandler, and
+	// LastErrorTime when it happened.
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time"`
+	// LastFlush is when the last batch of a bulk processor hook was sent or
+	// the last Flush returned.
+	LastFlush time.Time `json:"last_flush"`
+}
+
+// hookStats records the statistics of a hook from its measurements, which it
+// passes on to the metrics set with SetMetrics.
+type hookStats struct {
+	Metrics
+	delivered atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
+
+	mu            sync.Mutex
+	lastError     error
+	lastErrorTime time.Time
+	lastFlush     time.Time
+}
+
+// DocumentsSent implements Metrics.
+func (s *hookStats) DocumentsSent(docs int) {
+	s.delivered.Add(uint64(docs))
+	s.Metrics.DocumentsSent(docs)
+}
+
+// DocumentsFailed implements Metrics.
+func (s *hookStats) DocumentsFailed(docs int) {
+	s.failed.Add(uint64(docs))
+	s.Metrics.DocumentsFailed(docs)
+}
+
+// DocumentsDropped implements Metrics.
+func (s *hookStats) DocumentsDropped(docs int) {
+	s.dropped.Add(uint64(docs))
+	s.Metrics.DocumentsDropped(docs)
+}
+
+// setError records the last error.
+func (s *hookStats) setError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError = err
+	s.lastErrorTime = time.Now()
+}
+
+// flushed records the time of the last flush.
+func (s *hookStats) flushed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastFlush = time.Now()
+}
+
+// Stats returns the statistics of the hook since it was created.
+func (hook *ElasticHook) Stats() Stats {
+	s := &hook.stats
+	stats := Stats{
+		Delivered: s.delivered.Load(),
+		Failed:    s.failed.Load(),
+		Dropped:   s.dropped.Load(),
+	}
+	if hook.batcher != nil {
+		stats.Buffered = hook.batcher.Pending()
+	}
+	if hook.pool != nil {
+		stats.Buffered = hook.pool.pending.Len()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastError != nil {
+		stats.LastError = s.lastError.Error()
+	}
+	stats.LastErrorTime = s.lastErrorTime
+	stats.LastFlush = s.lastFlush
+	return stats
+}
+
+// PublishExpvar publishes the Stats of the hook as the expvar variable name, so
+// they are served by the /debug/vars endpoint of the process. It fails if the
+// name is already published, e.g. by another hook.
+func (hook *ElasticHook) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("expvar %s is already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return hook.Stats()
+	}))
+	return nil
+}
@@ -0,0 +1,75 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+func TestStats(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if strings.HasSuffix(r.URL.Path, "/_bulk") {
+			var items []string
+			lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+			for i := 1; i < len(lines); i += 2 {
+				if strings.Contains(lines[i], "rejected") {
+					items = append(items, `{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed"}}}`)
+				} else {
+					items = append(items, `{"index":{"status":201}}`)
+				}
+			}
+			_, _ = io.WriteString(w, `{"errors":true,"items":[`+strings.Join(items, ",")+`]}`)
+			return true
+		}
+		return false
+	}
+	// expvar names are global, tolerate -count
+	name := "elogrus-stats-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	hook, err := NewElasticHookWithOptions(es8.New(client),
+		WithIndex("stats-log"),
+		WithDeliveryMode(BulkDelivery),
+		WithExpvar(name),
+	)
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	defer hook.Cancel()
+
+	for _, msg := range []string{"first", "second", "rejected"} {
+		if err := hook.Submit(NewDocument().SetMessage(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := hook.Stats(); stats.Buffered != 3 {
+		t.Errorf("Expected 3 buffered documents, got %d", stats.Buffered)
+	}
+	// the rejected entry fails the flush
+	_ = hook.Flush(context.Background())
+
+	var stats Stats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Delivered != 2 || stats.Failed != 1 || stats.Buffered != 0 {
+		t.Errorf("Expected 2 delivered, 1 failed and no buffered documents, got %+v", stats)
+	}
+	if !strings.Contains(stats.LastError, "mapper_parsing_exception") || stats.LastErrorTime.IsZero() {
+		t.Errorf("Expected the rejection to be the last error, got %q at %s", stats.LastError, stats.LastErrorTime)
+	}
+	if stats.LastFlush.IsZero() {
+		t.Error("Expected the time of the last flush")
+	}
+
+	if err := hook.PublishExpvar(name); err == nil {
+		t.Error("Expected publishing the same name twice to fail")
+	}
+}
@@ -0,0 +1,94 @@
+package elogrus
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// DefaultTimestampField is the name of the timestamp field of the documents.
+const DefaultTimestampField = "@timestamp"
+
+// EpochMillis is the TimestampFormat layout of the timestamps written as the
+// number of milliseconds since the epoch, the epoch_millis date format of
+// Elasticsearch.
+const EpochMillis = "epoch_millis"
+
+// TimestampFormat defines the timestamp field of the documents, see SetTimestampFormat.
+// The zero value is the @timestamp field in RFC 3339 with nanoseconds, in UTC.
+type TimestampFormat struct {
+	// Field is the name of the field, DefaultTimestampField if empty.
+	Field string
+	// Layout is the time.Format layout of the values, time.RFC3339Nano if
+	// empty, or EpochMillis.
+	Layout string
+	// Local keeps the time zone of the entries instead of converting their
+	// time to UTC.
+	Local bool
+}
+
+// SetTimestampFormat changes the name, layout and time zone of the timestamp
+// field of the documents, e.g. to match the mappings of an existing index. The
+// mappings of the indices created by the hook are not changed, so a field
+// other than DefaultTimestampField or a layout Elasticsearch does not detect
+// as a date need mappings of their own. Only JSON documents can have another
+// field, data streams need the default one. Search uses the format too.
+func (hook *ElasticHook) SetTimestampFormat(format TimestampFormat) error {
+	if format.Field == DefaultTimestampField {
+		format.Field = ""
+	}
+	if format.Field != "" {
+		if hook.dataStream {
+			return errors.New("data stream documents need an @timestamp field")
+		}
+		if hook.serializer != nil {
+			return errors.New("only JSON documents can have another timestamp field")
+		}
+	}
+	t, err := messageType(hook.fieldNames, format.Field)
+	if err != nil {
+		return err
+	}
+	hook.timestampFormat, hook.messageType = format, t
+	return nil
+}
+
+// timestampField returns the name of the timestamp field of the documents.
+func (hook *ElasticHook) timestampField() string {
+	if hook.timestampFormat.Field == "" {
+		return DefaultTimestampField
+	}
+	return hook.timestampFormat.Field
+}
+
+// formatTime formats a timestamp according to the TimestampFormat of the hook.
+func (hook *ElasticHook) formatTime(t time.Time) string {
+	format := hook.timestampFormat
+	if !format.Local {
+		t = t.UTC()
+	}
+	switch format.Layout {
+	case "":
+		return t.Format(time.RFC3339Nano)
+	case EpochMillis:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	default:
+		return t.Format(format.Layout)
+	}
+}
+
+// parseTime parses a timestamp formatted by formatTime.
+func (hook *ElasticHook) parseTime(s string) (time.Time, error) {
+	switch layout := hook.timestampFormat.Layout; layout {
+	case "":
+		return time.Parse(time.RFC3339Nano, s)
+	case EpochMillis:
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(ms), nil
+	default:
+		return time.Parse(layout, s)
+	}
+}
@@ -0,0 +1,96 @@
+package elogrus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSetTimestampFormat(t *testing.T) {
+	f, client := newFakeElastic(t)
+	f.handle = func(w http.ResponseWriter, r *http.Request, body string) bool {
+		if r.URL.Path != "/timestamp-log/_search" {
+			return false
+		}
+		_, _ = io.WriteString(w, `{"took":1,"hits":{"hits":[{"_index":"timestamp-log","_id":"1","_source":`+
+			`{"ts":"1646370367000","message":"found","level":"INFO"}}]}}`)
+		return true
+	}
+	hook, err := NewElasticHook(client, "localhost", logrus.InfoLevel, "timestamp-log")
+	if err != nil {
+		t.Fatalf("Error creating the hook: %s", err)
+	}
+	if err := hook.SetTimestampFormat(TimestampFormat{Field: "ts", Layout: EpochMillis}); err != nil {
+		t.Fatal(err)
+	}
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.Hooks.Add(hook)
+
+	ts := time.Date(2022, 3, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600))
+	logger.WithTime(ts).WithField("@timestamp", "kept").Info("millis")
+	if err := hook.SetTimestampFormat(TimestampFormat{Layout: "2006-01-02 15:04:05 -0700", Local: true}); err != nil {
+		t.Fatal(err)
+	}
+	logger.WithTime(ts).Info("local")
+
+	// Only the documents the hook builds are renamed.
+	if err := hook.SetTimestampFormat(TimestampFormat{Field: "ts"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.SetFieldNames(FieldNames{Message: "msg"}); err != nil {
+		t.Fatal(err)
+	}
+	logger.WithTime(ts).Info("renamed")
+	hook.MessageModifierFunc = func(entry *logrus.Entry, msg *Message) interface{} {
+		return map[string]interface{}{"@timestamp": msg.Timestamp, "event": map[string]interface{}{"@timestamp": "nested"}}
+	}
+	logger.WithTime(ts).Info("modified")
+	hook.MessageModifierFunc = nil
+	if err := hook.SetFieldNames(FieldNames{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.SetFieldNames(FieldNames{Message: "ts"}); err == nil {
+		t.Error("Expected an error naming the message field like the timestamp field")
+	}
+
+	docs := f.Requests(http.MethodPost, "/timestamp-log/_doc")
+	if len(docs) != 4 {
+		t.Fatalf("Expected 4 documents, got %d", len(docs))
+	}
+	if !strings.HasPrefix(docs[2].Body, `{"host":"localhost","ts":"2022-03-04T04:06:07Z",`) ||
+		!strings.Contains(docs[2].Body, `"msg":"renamed"`) {
+		t.Errorf("Unexpected document: %s", docs[2].Body)
+	}
+	if docs[3].Body != `{"@timestamp":"2022-03-04T04:06:07Z","event":{"@timestamp":"nested"}}` {
+		t.Errorf("Unexpected document: %s", docs[3].Body)
+	}
+	if !strings.HasPrefix(docs[0].Body, `{"host":"localhost","ts":"1646366767000",`) ||
+		!strings.Contains(docs[0].Body, `"data":{"@timestamp":"kept"}`) {
+		t.Errorf("Unexpected document: %s", docs[0].Body)
+	}
+	if !strings.Contains(docs[1].Body, `"@timestamp":"2022-03-04 05:06:07 +0100"`) {
+		t.Errorf("Unexpected document: %s", docs[1].Body)
+	}
+
+	if err := hook.SetTimestampFormat(TimestampFormat{Field: "ts", Layout: EpochMillis}); err != nil {
+		t.Fatal(err)
+	}
+	found, err := hook.Search(context.Background(), QueryOptions{Since: time.UnixMilli(1646370000000)})
+	if err != nil {
+		t.Fatalf("Error searching: %s", err)
+	}
+	if len(found) != 1 || !found[0].Timestamp().Equal(time.UnixMilli(1646370367000)) {
+		t.Errorf("Unexpected documents: %+v", found)
+	}
+	reqs := f.Requests(http.MethodPost, "/timestamp-log/_search")
+	if len(reqs) != 1 || !strings.Contains(reqs[0].Body, `"range":{"ts":{"gte":"1646370000000"}}`) ||
+		!strings.Contains(reqs[0].Body, `"sort":[{"ts":"desc"}]`) {
+		t.Errorf("Unexpected search requests: %+v", reqs)
+	}
+}
@@ -0,0 +1,38 @@
+package elogrus
+
+import (
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+
+	"gopkg.in/go-extras/elogrus.v8/core"
+)
+
+// Tracer traces the requests of a hook, see SetTracer.
+type Tracer = core.Tracer
+
+// SetTracer makes the hook trace every request sending documents with t, e.g.
+// with the client spans of the otel package, to see the overhead of logging in
+// traces. The requests of the synchronous hook are traced in the context of
+// their entries (see logrus.WithContext), so their spans are children of the
+// spans of the callers. A nil t disables the tracing.
+func (hook *ElasticHook) SetTracer(t Tracer) {
+	hook.tracer = t
+	if hook.batcher != nil {
+		hook.batcher.SetTracer(t)
+	}
+}
+
+// entryTrace returns the trace.id and span.id fields of the document of an
+// entry logged in the context of an OpenTelemetry span (see
+// logrus.WithContext), so the entry can be correlated with its trace, nil
+// otherwise.
+func entryTrace(entry *logrus.Entry) (traceID, spanID *core.TraceID) {
+	if entry.Context == nil {
+		return nil, nil
+	}
+	sc := trace.SpanContextFromContext(entry.Context)
+	if !sc.IsValid() {
+		return nil, nil
+	}
+	return &core.TraceID{ID: sc.TraceID().String()}, &core.TraceID{ID: sc.SpanID().String()}
+}
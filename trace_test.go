@@ -0,0 +1,135 @@
+package elogrus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"gopkg.in/go-extras/elogrus.v8/es8"
+)
+
+type tracedRequest struct {
+	op, index  string
+	docs       int
+	ended      bool
+	fromCaller bool
+}
+
+// recordingTracer records the traced requests.
+type recordingTracer struct {
+	mu       sync.Mutex
+	requests []*tracedRequest
+}
+
+type callerKey struct{}
+
+func (t *recordingTracer) Start(ctx context.Context, op, index string, docs, _ int) (context.Context, func(error)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := &tracedRequest{op: op, index: index, docs: docs, fromCaller: ctx.Value(callerKey{}) != nil}
+	t.requests = append(t.requests, r)
+	return ctx, func(error) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		r.ended = true
+	}
+}
+
+func TestSetTracer(t *testing.T) {
+	for _, mode := range []DeliveryMode{SyncDelivery, BulkDelivery} {
+		t.Run(mode.String(), func(t *testing.T) {
+			_, client := newFakeElastic(t)
+			tracer := &recordingTracer{}
+			hook, err := NewElasticHookWithOptions(es8.New(client),
+				WithIndex("traced-log"),
+				WithDeliveryMode(mode),
+				WithTracer(tracer),
+			)
+			if err != nil {
+				t.Fatalf("Error creating the hook: %s", err)
+			}
+			defer hook.Cancel()
+
+			ctx := context.WithValue(context.Background(), callerKey{}, true)
+			for i := 0; i < 2; i++ {
+				if err := hook.Fire(NewDocument().SetMessage("entry").Entry().WithContext(ctx)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := hook.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			tracer.mu.Lock()
+			defer tracer.mu.Unlock()
+			want := tracedRequest{op: "index", index: "traced-log", docs: 1, ended: true, fromCaller: true}
+			count := 2
+			if mode == BulkDelivery {
+				want = tracedRequest{op: "bulk", index: "traced-log", docs: 2, ended: true}
+				count = 1
+			}
+			if len(tracer.requests) != count {
+				t.Fatalf("Expected %d traced requests, got %d", count, len(tracer.requests))
+			}
+			for _, r := range tracer.requests {
+				if *r != want {
+					t.Errorf("Expected %+v, got %+v", want, *r)
+				}
+			}
+		})
+	}
+}
+
+func TestEntryTrace(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	for _, format := range []DocumentFormat{DefaultFormat, ECSFormat} {
+		t.Run(format.String(), func(t *testing.T) {
+			f, client := newFakeElastic(t)
+			hook, err := NewElasticHookWithOptions(es8.New(client), WithIndex("trace-log"))
+			if err != nil {
+				t.Fatalf("Error creating the hook: %s", err)
+			}
+			defer hook.Cancel()
+			hook.SetDocumentFormat(format)
+
+			if err := hook.Fire(NewDocument().SetMessage("traced").Entry().WithContext(ctx)); err != nil {
+				t.Fatal(err)
+			}
+			if err := hook.Fire(NewDocument().SetMessage("untraced").Entry().WithContext(context.Background())); err != nil {
+				t.Fatal(err)
+			}
+			requests := f.Requests(http.MethodPost, "/trace-log/_doc")
+			if len(requests) != 2 {
+				t.Fatalf("Expected 2 documents, got %d", len(requests))
+			}
+			var doc map[string]interface{}
+			if err := json.Unmarshal([]byte(requests[0].Body), &doc); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]interface{}{
+				"trace": map[string]interface{}{"id": "4bf92f3577b34da6a3ce929d0e0e4736"},
+				"span":  map[string]interface{}{"id": "00f067aa0ba902b7"},
+			}
+			for k, v := range want {
+				if !reflect.DeepEqual(doc[k], v) {
+					t.Errorf("Expected %s to be %v, got %v", k, v, doc[k])
+				}
+			}
+			if strings.Contains(requests[1].Body, `"trace"`) {
+				t.Errorf("Expected no trace.id without a span, got %s", requests[1].Body)
+			}
+		})
+	}
+}